@@ -0,0 +1,480 @@
+// Package anomaly learns the usual readings of each site's metrics and
+// scores new readings against them. A site's baseline of a metric is the
+// median and median absolute deviation (MAD) of its recent values,
+// adjusted for the day of the week. A value's score is its robust z-score:
+// how many scaled MADs it lies from the median.
+//
+// Summed metrics, such as water, are learned and scored as daily totals
+// from the daily rollups, so that a day read once or several times is
+// judged alike. Averaged metrics, such as concentrations, are learned and
+// scored as single readings.
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/logging"
+	"utility-backend/metrics"
+	"utility-backend/models"
+	"utility-backend/repository"
+	"utility-backend/validation"
+	"utility-backend/webhooks"
+)
+
+// Baseline and scoring settings
+const (
+	WindowDays        = 56  // days of readings a baseline is learned from
+	MinSamples        = 14  // fewer readings than this are not enough to learn from
+	MinWeekdaySamples = 3   // a weekday with fewer readings keeps a seasonal factor of 1
+	Threshold         = 3.5 // values scoring this far from 0 are anomalous
+	DangerThreshold   = 7   // anomalies this far from 0 raise danger rather than warning alerts
+)
+
+// madScale turns a MAD into an estimate of the standard deviation of
+// normally distributed values
+const madScale = 1.4826
+
+var log = logging.For("anomaly")
+
+// Finding is a value of a reading that scored as anomalous
+type Finding struct {
+	Metric   models.Metric
+	Value    float64 // the day's total for summed metrics
+	Expected float64 // the baseline median for the reading's weekday
+	Score    float64
+	Daily    bool // Value is the total of the reading's date
+}
+
+// sample is a value learned from, with the weekday of its reading's date
+type sample struct {
+	weekday int
+	value   float64
+}
+
+// Learn relearns the baselines of a site, or of every site with a clientID
+// of 0, from the WindowDays before the site's local date at now: the daily
+// totals of summed metrics and the readings of averaged ones. Metrics with
+// fewer than MinSamples days or readings are left without a baseline. It
+// returns how many sites were learned.
+func Learn(db *gorm.DB, clientID uint, now time.Time) (int, error) {
+	clients := repository.NewClients(db)
+	var sites []models.Client
+	if clientID != 0 {
+		client, err := clients.Find(clientID)
+		if err != nil {
+			return 0, err
+		}
+		sites = []models.Client{client}
+	} else {
+		var err error
+		if sites, err = clients.List(); err != nil {
+			return 0, err
+		}
+	}
+
+	for i, site := range sites {
+		baselines, err := learnSite(db, site, now)
+		if err != nil {
+			return i, err
+		}
+		if err := repository.NewBaselines(db).Replace(site.ID, baselines); err != nil {
+			return i, err
+		}
+	}
+	return len(sites), nil
+}
+
+// learnSite learns the baselines of every metric a site recorded often enough
+func learnSite(db *gorm.DB, site models.Client, now time.Time) ([]models.Baseline, error) {
+	today := models.DateOf(now.In(validation.Location(site.Timezone)))
+	summed, err := summedMetrics(db)
+	if err != nil {
+		return nil, err
+	}
+
+	type row struct {
+		MetricCode string
+		Date       models.Date
+		Value      float64
+	}
+	var days, readings []row
+	err = db.Model(&models.DailyRollup{}).
+		Select("metric_code, date, total AS value").
+		Where("client_id = ? AND date >= ? AND date < ?", site.ID, today.AddDays(-WindowDays), today).
+		Scan(&days).Error
+	if err != nil {
+		return nil, err
+	}
+	err = db.Table("reading_values").
+		Select("reading_values.metric_code, utility_data.date, reading_values.value").
+		Joins("JOIN utility_data ON utility_data.id = reading_values.utility_data_id").
+		Where("utility_data.client_id = ? AND utility_data.date >= ? AND utility_data.date < ? AND utility_data.deleted_at IS NULL",
+			site.ID, today.AddDays(-WindowDays), today).
+		Scan(&readings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	samples := map[string][]sample{}
+	for _, r := range days {
+		if summed[r.MetricCode] {
+			samples[r.MetricCode] = append(samples[r.MetricCode], sample{int(r.Date.Weekday()), r.Value})
+		}
+	}
+	for _, r := range readings {
+		if !summed[r.MetricCode] {
+			samples[r.MetricCode] = append(samples[r.MetricCode], sample{int(r.Date.Weekday()), r.Value})
+		}
+	}
+	codes := make([]string, 0, len(samples))
+	for code := range samples {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	var baselines []models.Baseline
+	for _, code := range codes {
+		if len(samples[code]) < MinSamples {
+			continue
+		}
+		baselines = append(baselines, learnMetric(site.ID, code, samples[code], now)...)
+	}
+	return baselines, nil
+}
+
+// learnMetric returns a metric's baseline for each day of the week
+func learnMetric(clientID uint, code string, samples []sample, learnedAt time.Time) []models.Baseline {
+	all := make([]float64, len(samples))
+	var byWeekday [7][]float64
+	for i, s := range samples {
+		all[i] = s.value
+		byWeekday[s.weekday] = append(byWeekday[s.weekday], s.value)
+	}
+	overall := median(all)
+
+	// A weekday's factor is its median relative to that of every day
+	var factors [7]float64
+	for w := range factors {
+		factors[w] = 1
+		if overall > 0 && len(byWeekday[w]) >= MinWeekdaySamples {
+			if m := median(byWeekday[w]); m > 0 {
+				factors[w] = m / overall
+			}
+		}
+	}
+
+	// The median and spread are measured with the weekly pattern taken out
+	adjusted := make([]float64, len(samples))
+	for i, s := range samples {
+		adjusted[i] = s.value / factors[s.weekday]
+	}
+	center := median(adjusted)
+	deviations := make([]float64, len(adjusted))
+	for i, v := range adjusted {
+		deviations[i] = math.Abs(v - center)
+	}
+	mad := median(deviations)
+
+	baselines := make([]models.Baseline, len(factors))
+	for w, factor := range factors {
+		baselines[w] = models.Baseline{
+			ClientID:   clientID,
+			MetricCode: code,
+			Weekday:    w,
+			Median:     center * factor,
+			MAD:        mad * factor,
+			Factor:     factor,
+			Samples:    len(samples),
+			LearnedAt:  learnedAt,
+		}
+	}
+	return baselines
+}
+
+// summedMetrics returns which metrics of the catalog are summed per day, by code
+func summedMetrics(db *gorm.DB) (map[string]bool, error) {
+	catalog, err := metrics.All(db)
+	if err != nil {
+		return nil, err
+	}
+	summed := map[string]bool{}
+	for _, m := range catalog {
+		summed[m.Code] = m.Aggregation == metrics.AggregationSum
+	}
+	return summed, nil
+}
+
+// median returns the median of values, which it reorders
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 1 {
+		return values[mid]
+	}
+	return (values[mid-1] + values[mid]) / 2
+}
+
+// Score scores each value of a reading against its site's baseline for the
+// weekday of the reading's date, and stores the scores on the values.
+// Values of summed metrics are scored as the total of the reading's date so
+// far, read from the daily rollups, which must include the reading. Values
+// of metrics without a baseline, or whose values did not vary, are left
+// unscored. It returns the anomalous values, least usual first. A day's
+// total is only found too low once the day is over at the site.
+func Score(db *gorm.DB, reading *models.UtilityData) ([]Finding, error) {
+	return score(db, reading, time.Now())
+}
+
+// score scores a reading as Score does, telling whether its day is over by now
+func score(db *gorm.DB, reading *models.UtilityData, now time.Time) ([]Finding, error) {
+	baselines, err := repository.NewBaselines(db).ForWeekday(reading.ClientID, int(reading.Date.Weekday()))
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := metrics.All(db)
+	if err != nil {
+		return nil, err
+	}
+	byCode := map[string]models.Metric{}
+	for _, m := range catalog {
+		byCode[m.Code] = m
+	}
+
+	var totals []struct {
+		MetricCode string
+		Total      float64
+	}
+	err = db.Model(&models.DailyRollup{}).Select("metric_code, total").
+		Where("client_id = ? AND date = ?", reading.ClientID, reading.Date).
+		Scan(&totals).Error
+	if err != nil {
+		return nil, err
+	}
+	dayTotals := map[string]float64{}
+	for _, t := range totals {
+		dayTotals[t.MetricCode] = t.Total
+	}
+	today := models.DateOf(now.In(validation.Location(reading.Timezone)))
+	dayOver := reading.Date.Before(today.Time)
+
+	var findings []Finding
+	for i := range reading.Values {
+		v := &reading.Values[i]
+		v.Score, v.Expected = nil, nil
+		b, ok := baselines[v.MetricCode]
+		if !ok || b.MAD == 0 {
+			continue
+		}
+		m := byCode[v.MetricCode]
+		daily := m.Aggregation == metrics.AggregationSum
+		value := v.Value
+		if total, ok := dayTotals[v.MetricCode]; daily && ok {
+			value = total
+		}
+
+		score := math.Round((value-b.Median)/(madScale*b.MAD)*100) / 100
+		expected := b.Median
+		v.Score, v.Expected = &score, &expected
+		if math.Abs(score) >= Threshold && (score > 0 || !daily || dayOver) {
+			findings = append(findings, Finding{Metric: m, Value: value, Expected: expected, Score: score, Daily: daily})
+		}
+	}
+	if err := repository.NewReadings(db).SaveScores(*reading); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return math.Abs(findings[i].Score) > math.Abs(findings[j].Score)
+	})
+	return findings, nil
+}
+
+// Check scores a reading, as Score does, and raises an alert for it if any
+// of its values is anomalous and it has no open alert. Unusual daily totals
+// are only alerted once per date, by the first reading found to take them
+// out of the ordinary. The alert is published through hooks and returned;
+// nil is returned when none is raised. Failures are logged rather than
+// returned so that checking never fails the caller's request, the reading
+// being saved already.
+func Check(db *gorm.DB, hooks *webhooks.Dispatcher, reading *models.UtilityData) *models.Alert {
+	ctx := db.Statement.Context
+	findings, err := Score(db, reading)
+	if err != nil {
+		log.ErrorContext(ctx, "Failed to score reading", "readingId", reading.ID, "error", err)
+		return nil
+	}
+	if len(findings) == 0 {
+		return nil
+	}
+
+	alerts := repository.NewAlerts(db)
+	raised, err := alerts.ExistsForReading(reading.ID)
+	if err != nil {
+		log.ErrorContext(ctx, "Failed to look up alerts", "readingId", reading.ID, "error", err)
+		return nil
+	}
+	if raised {
+		return nil
+	}
+	dated, err := alerts.ExistsForDate(reading.ClientID, reading.Date, reading.ID)
+	if err != nil {
+		log.ErrorContext(ctx, "Failed to look up alerts", "readingId", reading.ID, "error", err)
+		return nil
+	}
+	if dated {
+		findings = readingFindings(findings)
+		if len(findings) == 0 {
+			return nil
+		}
+	}
+	client, err := repository.NewClients(db).Find(reading.ClientID)
+	if err != nil {
+		log.ErrorContext(ctx, "Failed to load site", "clientId", reading.ClientID, "error", err)
+		return nil
+	}
+
+	alert := alertFor(client, *reading, findings)
+	if err := alerts.Create(&alert); err != nil {
+		log.ErrorContext(ctx, "Failed to save alert", "readingId", reading.ID, "error", err)
+		return nil
+	}
+	log.InfoContext(ctx, "Unusual reading", "clientId", client.ID, "readingId", reading.ID, "alertId", alert.ID,
+		"metric", findings[0].Metric.Code, "score", findings[0].Score)
+	hooks.Publish(db, webhooks.EventAlertOpened, alert)
+	return &alert
+}
+
+// CheckDays raises alerts for the daily totals that turned out too low on
+// the day before now at each site, which Check cannot find while the day is
+// still going. The day's last reading is scored again with the day over,
+// and a date that already had an alert raised is left alone, so checking a
+// day again raises nothing new. It returns the alerts raised.
+func CheckDays(db *gorm.DB, hooks *webhooks.Dispatcher, now time.Time) ([]models.Alert, error) {
+	clients, err := repository.NewClients(db).List()
+	if err != nil {
+		return nil, err
+	}
+	alerts := repository.NewAlerts(db)
+	var raised []models.Alert
+	for _, client := range clients {
+		today := models.DateOf(now.In(validation.Location(client.Timezone)))
+		yesterday := today.AddDays(-1)
+		dated, err := alerts.ExistsForDate(client.ID, yesterday, 0)
+		if err != nil {
+			return raised, err
+		}
+		if dated {
+			continue
+		}
+		readings, err := repository.NewReadings(db).InRange(client.ID, yesterday, today)
+		if err != nil {
+			return raised, err
+		}
+		if len(readings) == 0 {
+			continue
+		}
+
+		last := readings[len(readings)-1]
+		findings, err := score(db, &last, now)
+		if err != nil {
+			return raised, err
+		}
+		findings = lowTotals(findings)
+		if len(findings) == 0 {
+			continue
+		}
+		alert := alertFor(client, last, findings)
+		if err := alerts.Create(&alert); err != nil {
+			return raised, err
+		}
+		log.Info("Unusual day", "clientId", client.ID, "date", yesterday, "alertId", alert.ID,
+			"metric", findings[0].Metric.Code, "score", findings[0].Score)
+		hooks.Publish(db, webhooks.EventAlertOpened, alert)
+		raised = append(raised, alert)
+	}
+	return raised, nil
+}
+
+// lowTotals returns the findings of daily totals below the usual ones
+func lowTotals(findings []Finding) []Finding {
+	var kept []Finding
+	for _, f := range findings {
+		if f.Daily && f.Score < 0 {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// readingFindings returns the findings of single readings, leaving out daily totals
+func readingFindings(findings []Finding) []Finding {
+	var kept []Finding
+	for _, f := range findings {
+		if !f.Daily {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// alertFor describes the anomalous values of a reading in an alert, titled
+// after the least usual one
+func alertFor(client models.Client, reading models.UtilityData, findings []Finding) models.Alert {
+	top := findings[0]
+	kind := "warning"
+	if math.Abs(top.Score) >= DangerThreshold {
+		kind = "danger"
+	}
+	title := "High " + top.Metric.Name
+	if top.Score < 0 {
+		title = "Low " + top.Metric.Name
+	}
+	if top.Metric.Aggregation == metrics.AggregationSum {
+		title += " Usage"
+	}
+
+	weekday := reading.Date.Weekday().String()
+	facts := make([]string, len(findings))
+	for i, f := range findings {
+		facts[i] = describe(f, weekday)
+	}
+	readAt := reading.ReadAt.In(validation.Location(reading.Timezone))
+
+	readingID := reading.ID
+	return models.Alert{
+		ClientID:      client.ID,
+		Type:          kind,
+		Title:         title,
+		Message:       fmt.Sprintf("%s, reading of %s: %s.", client.Name, readAt.Format("Mon 2 Jan 2006 15:04"), strings.Join(facts, "; ")),
+		Status:        repository.AlertOpen,
+		UtilityDataID: &readingID,
+	}
+}
+
+// describe states how far an anomalous value is from the usual one
+func describe(f Finding, weekday string) string {
+	m := f.Metric
+	text := fmt.Sprintf("%s was %.*f %s", m.Name, m.Precision, f.Value, m.Unit)
+	if f.Daily {
+		text = fmt.Sprintf("%s for the day was %.*f %s", m.Name, m.Precision, f.Value, m.Unit)
+	}
+	if f.Expected > 0 {
+		change, direction := (f.Value-f.Expected)/f.Expected*100, "above"
+		if change < 0 {
+			change, direction = -change, "below"
+		}
+		text += fmt.Sprintf(", %.0f%% %s the usual %.*f %s", change, direction, m.Precision, f.Expected, m.Unit)
+	} else {
+		text += fmt.Sprintf(", against a usual %.*f %s", m.Precision, f.Expected, m.Unit)
+	}
+	return text + fmt.Sprintf(" for a %s (score %.1f)", weekday, f.Score)
+}
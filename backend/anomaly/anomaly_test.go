@@ -0,0 +1,321 @@
+package anomaly
+
+import (
+	"math"
+	"net/url"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/config"
+	"utility-backend/database"
+	"utility-backend/models"
+	"utility-backend/repository"
+	"utility-backend/webhooks"
+)
+
+// openTestDB returns a migrated in-memory SQLite database of the test's own
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	cfg, err := config.Load("", map[string]string{
+		"DB_TYPE": "sqlite",
+		"DB_PATH": "file:" + url.PathEscape(t.Name()) + "?mode=memory&cache=shared",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// near reports whether two figures agree to within rounding
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// weekly returns samples of the given number of weeks, each weekday's value taken in turn
+// from weekdays and each weekend day's from weekends
+func weekly(weeks int, weekdays, weekends []float64) []sample {
+	var samples []sample
+	for week := 0; week < weeks; week++ {
+		for day := 0; day < 7; day++ {
+			values := weekdays
+			if day == int(time.Saturday) || day == int(time.Sunday) {
+				values = weekends
+			}
+			samples = append(samples, sample{day, values[week%len(values)]})
+		}
+	}
+	return samples
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"none", nil, 0},
+		{"one", []float64{7}, 7},
+		{"odd", []float64{9, 1, 5}, 5},
+		{"even", []float64{4, 1, 3, 2}, 2.5},
+		{"repeated", []float64{3, 3, 3, 8}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := median(tt.values); got != tt.want {
+				t.Errorf("median(%v) = %v, want %v", tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLearnMetric(t *testing.T) {
+	// expected is the baseline of a weekday
+	type expected struct {
+		median, mad, factor float64
+	}
+	var sundays []sample
+	for _, s := range weekly(3, []float64{100, 90, 110}, []float64{100, 90, 110}) {
+		if s.weekday != int(time.Sunday) {
+			sundays = append(sundays, s)
+		}
+	}
+	sundays = append(sundays, sample{int(time.Sunday), 40}, sample{int(time.Sunday), 40})
+
+	tests := []struct {
+		name    string
+		samples []sample
+		want    map[time.Weekday]expected
+	}{
+		{
+			// Weekdays read around 100 and weekends around 50. With the
+			// pattern taken out every day reads 81, 90 or 99, so the
+			// median is 90 and the MAD 9, scaled back by each day's factor.
+			name:    "weekly pattern",
+			samples: weekly(3, []float64{100, 90, 110}, []float64{50, 45, 55}),
+			want: map[time.Weekday]expected{
+				time.Monday:   {100, 10, 100.0 / 90},
+				time.Friday:   {100, 10, 100.0 / 90},
+				time.Saturday: {50, 5, 50.0 / 90},
+				time.Sunday:   {50, 5, 50.0 / 90},
+			},
+		},
+		{
+			// Sunday has two readings, too few for a factor of its own
+			name:    "weekday with few samples",
+			samples: sundays,
+			want: map[time.Weekday]expected{
+				time.Monday: {100, 10, 1},
+				time.Sunday: {100, 10, 1},
+			},
+		},
+		{
+			// Mostly the same value: the MAD is 0, so the metric cannot be scored
+			name:    "no spread",
+			samples: weekly(2, []float64{100, 100}, []float64{100, 130}),
+			want: map[time.Weekday]expected{
+				time.Monday:   {100, 0, 1},
+				time.Saturday: {100, 0, 1},
+			},
+		},
+	}
+	learnedAt := time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baselines := learnMetric(4, "water", tt.samples, learnedAt)
+			if len(baselines) != 7 {
+				t.Fatalf("%d baselines, want one per weekday", len(baselines))
+			}
+			for weekday, w := range tt.want {
+				b := baselines[weekday]
+				if b.Weekday != int(weekday) || b.ClientID != 4 || b.MetricCode != "water" || b.Samples != len(tt.samples) || !b.LearnedAt.Equal(learnedAt) {
+					t.Errorf("%s baseline = %+v", weekday, b)
+				}
+				if !near(b.Median, w.median) || !near(b.MAD, w.mad) || !near(b.Factor, w.factor) {
+					t.Errorf("%s = median %v, MAD %v, factor %v, want %v, %v, %v",
+						weekday, b.Median, b.MAD, b.Factor, w.median, w.mad, w.factor)
+				}
+			}
+		})
+	}
+}
+
+// learnedSite is a site whose baselines were learned on a Monday, today,
+// from three weeks of water around 100 on weekdays and 50 at weekends, the
+// same BOD every day and PAC on too few days to learn from
+type learnedSite struct {
+	db    *gorm.DB
+	site  models.Client
+	today models.Date
+}
+
+func newLearnedSite(t *testing.T) learnedSite {
+	t.Helper()
+	l := learnedSite{db: openTestDB(t), today: models.NewDate(2026, 10, 19)}
+	l.site = models.Client{Name: "Bang Na", PlotNumber: "A-1", Timezone: "Asia/Bangkok"}
+	if err := l.db.Create(&l.site).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 21; i++ {
+		date := l.today.AddDays(-21 + i)
+		water := []float64{100, 90, 110}[i/7]
+		if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+			water = []float64{50, 45, 55}[i/7]
+		}
+		values := map[string]float64{"water": water, "bod": 20}
+		if i < 5 {
+			values["pac"] = 2
+		}
+		l.create(t, date, values)
+	}
+	// Older than the window, left out of the baselines
+	l.create(t, l.today.AddDays(-WindowDays-7), map[string]float64{"water": 5000})
+
+	now := time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC)
+	if sites, err := Learn(l.db, l.site.ID, now); err != nil || sites != 1 {
+		t.Fatalf("Learn = %d, %v, want 1 site", sites, err)
+	}
+	return l
+}
+
+// create saves and rolls up a reading of the site
+func (l learnedSite) create(t *testing.T, date models.Date, values map[string]float64) models.UtilityData {
+	t.Helper()
+	reading := models.UtilityData{ClientID: l.site.ID, Date: date, ReadAt: date.Time.Add(2 * time.Hour), Timezone: l.site.Timezone}
+	for code, value := range values {
+		reading.Values = append(reading.Values, models.ReadingValue{MetricCode: code, Value: value})
+	}
+	if err := repository.NewReadings(l.db).Create(&reading); err != nil {
+		t.Fatal(err)
+	}
+	return reading
+}
+
+func TestLearnAndScore(t *testing.T) {
+	l := newLearnedSite(t)
+	db, today := l.db, l.today
+
+	monday, err := repository.NewBaselines(db).ForWeekday(l.site.ID, int(time.Monday))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := monday["water"]; !near(b.Median, 100) || !near(b.MAD, 10) || b.Samples != 21 {
+		t.Errorf("Monday water baseline = %+v, want median 100 and MAD 10 from 21 days", b)
+	}
+	if b, ok := monday["bod"]; !ok || b.MAD != 0 {
+		t.Errorf("Monday BOD baseline = %+v, %v, want one with a MAD of 0", b, ok)
+	}
+	if _, ok := monday["pac"]; ok {
+		t.Error("PAC was learned from fewer than MinSamples days")
+	}
+
+	tests := []struct {
+		name   string
+		date   models.Date
+		water  float64
+		scores map[string]float64 // of the scored values
+		found  []string           // metrics found anomalous
+	}{
+		// (160 - 100) / (1.4826 × 10)
+		{"high", today, 160, map[string]float64{"water": 4.05}, []string{"water"}},
+		{"usual", today.AddDays(-35), 105, map[string]float64{"water": 0.34}, nil},
+		// Low totals are found once the day is over
+		{"low", today.AddDays(-28), 40, map[string]float64{"water": -4.05}, []string{"water"}},
+		// A Saturday is judged against Saturdays
+		{"usual weekend", today.AddDays(-30), 52, map[string]float64{"water": 0.27}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reading := l.create(t, tt.date, map[string]float64{"water": tt.water, "bod": 500, "pac": 90})
+			findings, err := Score(db, &reading)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var found []string
+			for _, f := range findings {
+				found = append(found, f.Metric.Code)
+			}
+			if len(found) != len(tt.found) || (len(found) > 0 && found[0] != tt.found[0]) {
+				t.Errorf("found %v, want %v", found, tt.found)
+			}
+
+			// Scores are stored on the values; BOD, without spread, and PAC,
+			// without a baseline, are left unscored
+			saved, err := repository.NewReadings(db).Find(reading.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range saved.Values {
+				want, scored := tt.scores[v.MetricCode]
+				switch {
+				case !scored && v.Score != nil:
+					t.Errorf("%s scored %v, want it unscored", v.MetricCode, *v.Score)
+				case scored && v.Score == nil:
+					t.Errorf("%s unscored, want %v", v.MetricCode, want)
+				case scored && *v.Score != want:
+					t.Errorf("%s scored %v, want %v", v.MetricCode, *v.Score, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckDays(t *testing.T) {
+	l := newLearnedSite(t)
+	hooks := webhooks.NewDispatcher(l.db)
+	bangkok, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	morningAfter := func(date models.Date) time.Time {
+		return time.Date(date.Year(), date.Month(), date.Day()+1, 0, 30, 0, 0, bangkok)
+	}
+
+	// Read twice, adding up to a usual Monday
+	monday := l.today.AddDays(7)
+	l.create(t, monday, map[string]float64{"water": 30})
+	l.create(t, monday, map[string]float64{"water": 70})
+	// Read once, well short of a usual Tuesday
+	tuesday := l.today.AddDays(8)
+	low := l.create(t, tuesday, map[string]float64{"water": 40})
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want []uint // readings alerted
+	}{
+		{"usual day", morningAfter(monday), nil},
+		{"low day", morningAfter(tuesday), []uint{low.ID}},
+		{"low day again", morningAfter(tuesday).Add(time.Hour), nil},
+		{"day not read", morningAfter(tuesday.AddDays(1)), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alerts, err := CheckDays(l.db, hooks, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []uint
+			for _, a := range alerts {
+				got = append(got, *a.UtilityDataID)
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Fatalf("alerted readings %v, want %v", got, tt.want)
+			}
+			if len(alerts) > 0 && (alerts[0].Title != "Low Water Usage" || alerts[0].Type != "warning") {
+				t.Errorf("alert = %s %q, want a Low Water Usage warning", alerts[0].Type, alerts[0].Title)
+			}
+		})
+	}
+}
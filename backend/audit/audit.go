@@ -0,0 +1,201 @@
+package audit
+
+import (
+	"encoding/json"
+	"os/user"
+	"reflect"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"utility-backend/logging"
+	"utility-backend/models"
+)
+
+// entriesKey holds the audit entries recorded by the handler of the current request
+const entriesKey = "auditEntries"
+
+var log = logging.For("audit")
+
+// ignoredFields are excluded from diffs because they change on every write
+var ignoredFields = map[string]bool{
+	"UpdatedAt": true,
+	"updatedAt": true,
+}
+
+// Change is the before and after value of a single field
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Record adds an audit entry for an entity changed by the current request.
+// Before is nil for creations and after is nil for deletions. Entries are
+// written by Flush once the response status is known.
+func Record(c *fiber.Ctx, action, entityType string, entityID uint, before, after interface{}) {
+	entry := newEntry(c, action)
+	describe(&entry, entityType, entityID, before, after)
+
+	entries, _ := c.Locals(entriesKey).([]models.AuditEntry)
+	c.Locals(entriesKey, append(entries, entry))
+}
+
+// RecordCommand writes an audit entry for a change made from the command
+// line, where there is no request or signed-in user. The operating system
+// user running the command is recorded as the actor.
+func RecordCommand(db *gorm.DB, command, action, entityType string, entityID uint, before, after interface{}) error {
+	entry := models.AuditEntry{
+		Method: "CLI",
+		Path:   command,
+		Action: action,
+	}
+	if u, err := user.Current(); err == nil {
+		entry.Username = u.Username
+	}
+	describe(&entry, entityType, entityID, before, after)
+	return db.Create(&entry).Error
+}
+
+// RecordJob writes an audit entry for a change made by a scheduled job.
+// The job is recorded as the actor.
+func RecordJob(db *gorm.DB, job, action, entityType string, entityID uint, before, after interface{}) error {
+	entry := models.AuditEntry{
+		Method:   "JOB",
+		Path:     job,
+		Action:   action,
+		Username: "job:" + job,
+	}
+	describe(&entry, entityType, entityID, before, after)
+	return db.Create(&entry).Error
+}
+
+// describe fills in the entity and its before and after snapshots
+func describe(entry *models.AuditEntry, entityType string, entityID uint, before, after interface{}) {
+	entry.EntityType = entityType
+	if entityID != 0 {
+		entry.EntityID = &entityID
+	}
+
+	beforeMap := snapshot(before)
+	afterMap := snapshot(after)
+	entry.Before = encode(beforeMap)
+	entry.After = encode(afterMap)
+	// Creations and deletions are fully described by the after or before snapshot
+	if beforeMap != nil && afterMap != nil {
+		entry.Diff = encode(Diff(beforeMap, afterMap))
+	}
+}
+
+// Flush writes the entries recorded during the request. Mutating requests
+// that recorded nothing get a generic entry so every change is accounted for.
+// Failures are logged rather than returned so that auditing never fails the request.
+func Flush(db *gorm.DB, c *fiber.Ctx, handlerErr error) {
+	status := c.Response().StatusCode()
+	if handlerErr != nil {
+		status = fiber.StatusInternalServerError
+		if e, ok := handlerErr.(*fiber.Error); ok {
+			status = e.Code
+		}
+	}
+
+	entries, _ := c.Locals(entriesKey).([]models.AuditEntry)
+	if len(entries) == 0 {
+		if !isMutating(c.Method()) {
+			return
+		}
+		entries = append(entries, newEntry(c, c.Method()+" "+c.Route().Path))
+	}
+
+	for _, entry := range entries {
+		entry.Status = status
+		if err := db.Create(&entry).Error; err != nil {
+			log.ErrorContext(c.UserContext(), "Failed to write audit entry", "action", entry.Action, "error", err)
+		}
+	}
+}
+
+// isMutating reports whether an HTTP method changes data
+func isMutating(method string) bool {
+	switch method {
+	case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// newEntry fills in the actor and request details of an audit entry
+func newEntry(c *fiber.Ctx, action string) models.AuditEntry {
+	entry := models.AuditEntry{
+		IP:     c.IP(),
+		Method: c.Method(),
+		Path:   c.Path(),
+		Action: action,
+	}
+
+	if userID, ok := c.Locals("userID").(uint); ok && userID != 0 {
+		entry.UserID = &userID
+	}
+	entry.Username, _ = c.Locals("username").(string)
+	entry.Role, _ = c.Locals("role").(string)
+
+	return entry
+}
+
+// snapshot converts a value to a generic JSON object for diffing
+func snapshot(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+	return m
+}
+
+// encode marshals a snapshot or diff, leaving empty values as SQL-friendly empty text
+func encode(v interface{}) models.JSONText {
+	if rv := reflect.ValueOf(v); !rv.IsValid() || rv.Len() == 0 {
+		return ""
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return models.JSONText(data)
+}
+
+// Diff returns the top-level fields whose values differ between two snapshots
+func Diff(before, after map[string]interface{}) map[string]Change {
+	changes := map[string]Change{}
+
+	for key, from := range before {
+		if ignoredFields[key] {
+			continue
+		}
+		to, ok := after[key]
+		if !ok || !reflect.DeepEqual(from, to) {
+			changes[key] = Change{From: from, To: to}
+		}
+	}
+	for key, to := range after {
+		if ignoredFields[key] {
+			continue
+		}
+		if _, ok := before[key]; !ok {
+			changes[key] = Change{From: nil, To: to}
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"utility-backend/anomaly"
+	"utility-backend/config"
+	"utility-backend/database"
+	"utility-backend/repository"
+)
+
+// runLearnBaselines handles the learn-baselines subcommand:
+//
+//	learn-baselines [-client id]
+func runLearnBaselines(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("learn-baselines", flag.ContinueOnError)
+	clientID := flags.Uint("client", 0, "only learn this site's baselines")
+	flags.Usage = usageFunc(flags, "learn-baselines [-client id]")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+	if *clientID > 0 {
+		if _, err := repository.NewClients(db).Find(*clientID); err != nil {
+			return fmt.Errorf("site %d not found", *clientID)
+		}
+	}
+
+	sites, err := anomaly.Learn(db, *clientID, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Learned the baselines of %d sites\n", sites)
+	return nil
+}
@@ -0,0 +1,187 @@
+// Package cache keeps rendered API responses so that pages loaded by many
+// users are not rebuilt from the database every time. Entries are tagged
+// with the sites they cover and dropped when those sites' data changes.
+package cache
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TagAllSites marks responses covering every site, which any site's changes invalidate
+const TagAllSites = "sites"
+
+// SiteTag returns the tag of responses covering a single site
+func SiteTag(clientID uint) string {
+	return "site:" + strconv.FormatUint(uint64(clientID), 10)
+}
+
+// Entry is a cached response
+type Entry struct {
+	Body        []byte
+	ContentType string
+	ETag        string
+	Tags        []string
+
+	// Generation is the Generation of the tags read before the response was
+	// built. An entry whose tags were invalidated since is not stored, as
+	// it may predate the change.
+	Generation uint64
+}
+
+// Store holds cached responses. Memory is the built-in store; a shared
+// store, e.g. backed by Redis, can take its place for several replicas.
+type Store interface {
+	// Get returns the live entry stored under key
+	Get(key string) (Entry, bool)
+	// Set stores an entry under key for ttl, unless its tags were
+	// invalidated since its Generation
+	Set(key string, entry Entry, ttl time.Duration)
+	// Generation returns a number that changes whenever any of the tags is
+	// invalidated or the store cleared
+	Generation(tags ...string) uint64
+	// Invalidate drops the entries carrying any of the tags
+	Invalidate(tags ...string)
+	// Clear drops every entry
+	Clear()
+}
+
+// Invalidate drops the cached responses covering a site, including those
+// covering every site. It does nothing on a nil store.
+func Invalidate(store Store, clientID uint) {
+	if store == nil {
+		return
+	}
+	store.Invalidate(SiteTag(clientID), TagAllSites)
+}
+
+// Clear drops every cached response. It does nothing on a nil store.
+func Clear(store Store) {
+	if store == nil {
+		return
+	}
+	store.Clear()
+}
+
+// memoryItem is an entry in the recency list of a Memory store
+type memoryItem struct {
+	key     string
+	entry   Entry
+	expires time.Time
+}
+
+// Memory is a Store in the process's memory. It holds at most a fixed
+// number of entries, dropping the least recently used one to make room.
+type Memory struct {
+	mu          sync.Mutex
+	maxEntries  int
+	items       map[string]*list.Element
+	recent      *list.List        // front is the most recently used
+	generations map[string]uint64 // invalidations of each tag
+	clears      uint64
+}
+
+// NewMemory returns an empty in-memory store of at most maxEntries entries
+func NewMemory(maxEntries int) *Memory {
+	return &Memory{
+		maxEntries:  maxEntries,
+		items:       map[string]*list.Element{},
+		recent:      list.New(),
+		generations: map[string]uint64{},
+	}
+}
+
+// Get implements Store
+func (m *Memory) Get(key string) (Entry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.items[key]
+	if !ok {
+		return Entry{}, false
+	}
+	item := el.Value.(*memoryItem)
+	if time.Now().After(item.expires) {
+		m.remove(el)
+		return Entry{}, false
+	}
+	m.recent.MoveToFront(el)
+	return item.entry, true
+}
+
+// Set implements Store
+func (m *Memory) Set(key string, entry Entry, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.generation(entry.Tags) != entry.Generation {
+		return
+	}
+	if el, ok := m.items[key]; ok {
+		m.remove(el)
+	}
+	for m.recent.Len() >= m.maxEntries && m.recent.Len() > 0 {
+		m.remove(m.recent.Back())
+	}
+	m.items[key] = m.recent.PushFront(&memoryItem{key: key, entry: entry, expires: time.Now().Add(ttl)})
+}
+
+// Generation implements Store
+func (m *Memory) Generation(tags ...string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.generation(tags)
+}
+
+// generation adds up the invalidations of the tags and the clears, which
+// only ever grow. m.mu must be held.
+func (m *Memory) generation(tags []string) uint64 {
+	sum := m.clears
+	for _, tag := range tags {
+		sum += m.generations[tag]
+	}
+	return sum
+}
+
+// Invalidate implements Store
+func (m *Memory) Invalidate(tags ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tag := range tags {
+		m.generations[tag]++
+	}
+	for el := m.recent.Front(); el != nil; {
+		next := el.Next()
+		if hasAny(el.Value.(*memoryItem).entry.Tags, tags) {
+			m.remove(el)
+		}
+		el = next
+	}
+}
+
+// Clear implements Store
+func (m *Memory) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clears++
+	m.items = map[string]*list.Element{}
+	m.recent.Init()
+}
+
+// remove drops an entry. m.mu must be held.
+func (m *Memory) remove(el *list.Element) {
+	delete(m.items, el.Value.(*memoryItem).key)
+	m.recent.Remove(el)
+}
+
+// hasAny reports whether tags includes any of wanted
+func hasAny(tags, wanted []string) bool {
+	for _, tag := range tags {
+		for _, w := range wanted {
+			if tag == w {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"utility-backend/config"
+)
+
+// command is a subcommand of the backend binary
+type command struct {
+	name    string
+	summary string
+	run     func(cfg *config.Config, args []string) error
+}
+
+// commands lists the subcommands; serve runs when none is given. All of them
+// share the configuration loaded before the command runs.
+var commands = []command{
+	{"serve", "start the API server (default)", runServe},
+	{"migrate", "apply, revert or list schema migrations", runMigrate},
+	{"seed", "seed accounts and generated sample data", runSeed},
+	{"user", "create users and reset passwords", runUser},
+	{"import", "import readings from a CSV file", runImport},
+	{"export", "export readings as CSV", runExport},
+	{"recompute-status", "recompute site statuses from alerts and flagged readings", runRecomputeStatus},
+	{"rebuild-rollups", "rebuild the daily and monthly rollups from the readings", runRebuildRollups},
+	{"learn-baselines", "relearn the sites' baselines from their recent readings", runLearnBaselines},
+	{"config", "check the configuration and print it with secrets redacted", runConfig},
+}
+
+// findCommand returns the subcommand with the given name
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// usage lists the subcommands and the settings flags
+func usage(global *flag.FlagSet) {
+	fmt.Fprintf(os.Stderr, "usage: %s [settings] <command> [arguments]\n\ncommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-17s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun %s <command> -h for a command's flags.\n\nsettings:\n", os.Args[0])
+	global.SetOutput(os.Stderr)
+	global.PrintDefaults()
+}
+
+// runConfig handles the config subcommand. Loading the configuration has
+// already validated it.
+func runConfig(cfg *config.Config, args []string) error {
+	if len(args) > 0 {
+		return errors.New("usage: config")
+	}
+	if cfg.File != "" {
+		fmt.Printf("# loaded from %s and the environment\n", cfg.File)
+	}
+	cfg.Print(os.Stdout)
+	return nil
+}
+
+// parseFlags parses flags that may come before, between or after positional
+// arguments, which the flag package stops at, and returns the positional ones
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// usageFunc prints a command's synopsis followed by its flags
+func usageFunc(flags *flag.FlagSet, synopsis ...string) func() {
+	return func() {
+		fmt.Fprintf(flags.Output(), "usage: %s\n", strings.Join(synopsis, "\n       "))
+		fmt.Fprintln(flags.Output(), "\nflags:")
+		flags.PrintDefaults()
+	}
+}
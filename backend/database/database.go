@@ -1,171 +1,151 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 
-	"utility-backend/models"
+	"utility-backend/config"
 )
 
-var DB *gorm.DB
-
-// InitDB initializes the database connection based on environment variables
-func InitDB() error {
-	var err error
-	dbType := os.Getenv("DB_TYPE")
+const (
+	// connectBackoff is the delay before the first connection retry; it doubles on every retry
+	connectBackoff = time.Second
+	// maxConnectBackoff caps the delay between connection retries
+	maxConnectBackoff = 30 * time.Second
+)
 
-	switch dbType {
+// Connect opens the configured database without touching the schema. The
+// connection is retried cfg.ConnectRetries times, so the backend can start
+// before its database is reachable.
+func Connect(cfg config.Database) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch cfg.Type {
 	case "postgres":
-		// PostgreSQL connection
-		dsn := fmt.Sprintf(
-			"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-			os.Getenv("DB_HOST"),
-			os.Getenv("DB_PORT"),
-			os.Getenv("DB_USER"),
-			os.Getenv("DB_PASSWORD"),
-			os.Getenv("DB_NAME"),
-		)
-		DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-			Logger: logger.Default.LogMode(logger.Info),
-		})
-	case "sqlite", "":
-		// Default to SQLite for development or if not specified
-		DB, err = gorm.Open(sqlite.Open("utility.db"), &gorm.Config{
-			Logger: logger.Default.LogMode(logger.Info),
-		})
+		dsn, err := postgresDSN(cfg)
+		if err != nil {
+			return nil, err
+		}
+		dialector = postgres.Open(dsn)
+	case "sqlite":
+		dialector = sqlite.Open(cfg.Path)
 	default:
-		return fmt.Errorf("unsupported database type: %s", dbType)
+		return nil, fmt.Errorf("unsupported database type: %s", cfg.Type)
 	}
 
+	// Opening does not connect; the ping below does, so that it can be retried
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger:               queryLogger{slow: cfg.SlowQuery},
+		DisableAutomaticPing: true,
+		TranslateError:       true, // unique violations come back as gorm.ErrDuplicatedKey
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	// Auto-migrate database models
-	err = DB.AutoMigrate(
-		&models.User{},
-		&models.Client{},
-		&models.UtilityData{},
-	)
+	sqlDB, err := db.DB()
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	// Seed initial data if database is empty
-	var count int64
-	DB.Model(&models.User{}).Count(&count)
-	if count == 0 {
-		log.Println("Seeding initial data...")
-		seedData()
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	delay := connectBackoff
+	for attempt := 1; ; attempt++ {
+		err = sqlDB.Ping()
+		if err == nil {
+			return db, nil
+		}
+		if attempt > cfg.ConnectRetries {
+			sqlDB.Close()
+			return nil, fmt.Errorf("connecting to the database: %w", err)
+		}
+		log.Warn("Database is not reachable, retrying",
+			"attempt", attempt, "attempts", cfg.ConnectRetries+1, "retryIn", delay.String(), "error", err)
+		time.Sleep(delay)
+		if delay *= 2; delay > maxConnectBackoff {
+			delay = maxConnectBackoff
+		}
 	}
-
-	return nil
 }
 
-// seedData creates initial data for the application
-func seedData() {
-	// Create admin and operator users
-	users := []models.User{
-		{
-			Username: "admin",
-			Password: "admin",
-			Role:     "admin",
-		},
-		{
-			Username: "operator",
-			Password: "operator",
-			Role:     "operator",
-		},
-		{
-			Username: "line_user",
-			Password: "line_password",
-			Role:     "operator",
-		},
+// postgresDSN returns the connection URL of the PostgreSQL database, from
+// DATABASE_URL or the separate settings. An sslmode in DATABASE_URL is kept
+// unless DB_SSLMODE is set; without either, SSL is preferred.
+func postgresDSN(cfg config.Database) (string, error) {
+	u := &url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(cfg.User, cfg.Password),
+		Host:   net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Path:   "/" + cfg.Name,
 	}
-
-	for _, user := range users {
-		// Hash password in a real application
-		user.HashPassword()
-		DB.Create(&user)
+	if cfg.URL != "" {
+		parsed, err := url.Parse(cfg.URL)
+		if err != nil {
+			// The URL is not shown, it holds the password
+			return "", errors.New("invalid DATABASE_URL")
+		}
+		u = parsed
 	}
 
-	// Create sample clients
-	clients := []models.Client{
-		{
-			Name:        "Site A (Manufacturing)",
-			PlotNumber:  "A-101",
-			Industry:    "Manufacturing",
-			Status:      "good",
-			Latitude:    13.736717,
-			Longitude:   100.523186,
-			ContactName: "John Smith",
-			Email:       "john.smith@example.com",
-			Phone:       "+66 2 123 4567",
-		},
-		{
-			Name:        "Site B (Storage)",
-			PlotNumber:  "B-201",
-			Industry:    "Logistics",
-			Status:      "warning",
-			Latitude:    13.740061,
-			Longitude:   100.529794,
-			ContactName: "Jane Doe",
-			Email:       "jane.doe@example.com",
-			Phone:       "+66 2 234 5678",
-		},
-		{
-			Name:        "Site C (Assembly)",
-			PlotNumber:  "C-301",
-			Industry:    "Electronics",
-			Status:      "good",
-			Latitude:    13.731690,
-			Longitude:   100.521126,
-			ContactName: "Bob Johnson",
-			Email:       "bob.johnson@example.com",
-			Phone:       "+66 2 345 6789",
-		},
-		{
-			Name:        "Site D (Office)",
-			PlotNumber:  "D-401",
-			Industry:    "Services",
-			Status:      "danger",
-			Latitude:    13.746262,
-			Longitude:   100.535211,
-			ContactName: "Sarah Williams",
-			Email:       "sarah.williams@example.com",
-			Phone:       "+66 2 456 7890",
-		},
+	query := u.Query()
+	switch {
+	case cfg.SSLMode != "":
+		query.Set("sslmode", cfg.SSLMode)
+	case query.Get("sslmode") == "":
+		query.Set("sslmode", "prefer")
 	}
-
-	for _, client := range clients {
-		DB.Create(&client)
+	if cfg.SSLRootCert != "" {
+		query.Set("sslrootcert", cfg.SSLRootCert)
 	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
 
-	// Create sample utility data
-	for clientID := uint(1); clientID <= 4; clientID++ {
-		for day := 1; day <= 30; day++ {
-			waterUsage := 150 + (day % 10) * 20 // Simulate some variation
-			pacUsage := 5 + (day % 5)
-			polymerUsage := 2 + (day % 3)
-			chlorineUsage := 1 + (day % 2)
+// Ping checks that the database answers before ctx is done
+func Ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
 
-			utilityData := models.UtilityData{
-				ClientID:      clientID,
-				Date:          fmt.Sprintf("2023-03-%02d", day),
-				WaterUsage:    float64(waterUsage),
-				PacUsage:      float64(pacUsage),
-				PolymerUsage:  float64(polymerUsage),
-				ChlorineUsage: float64(chlorineUsage),
-				Notes:         "",
-			}
+// InitDB connects to the database and brings its schema up to date. Pending
+// migrations are applied unless AutoMigrate is off, in which case the schema
+// must already be current. A schema newer than this build is refused.
+func InitDB(cfg config.Database) (*gorm.DB, error) {
+	db, err := Connect(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-			DB.Create(&utilityData)
+	if cfg.AutoMigrate {
+		applied, err := MigrateUp(db)
+		if err != nil {
+			return nil, err
 		}
+		if applied > 0 {
+			log.Info("Applied migrations", "migrations", applied)
+		}
+	}
+	if err := CheckSchema(db); err != nil {
+		return nil, err
+	}
+
+	// Make sure the metric catalog exists
+	if err := seedMetrics(db); err != nil {
+		return nil, err
 	}
-} 
\ No newline at end of file
+
+	return db, nil
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/models"
+)
+
+// legacyDateLayouts are the formats reading dates were stored in while the
+// date column was free text, tried in order
+var legacyDateLayouts = []string{
+	models.DateLayout,
+	"2006-1-2",
+	"2006/01/02",
+	"2006/1/2",
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+}
+
+// parseLegacyDate parses a reading date stored as text
+func parseLegacyDate(value string) (models.Date, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range legacyDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return models.DateOf(t), true
+		}
+	}
+	return models.Date{}, false
+}
+
+// migrateDateColumn converts utility_data.date from text to a DATE column.
+// Dates in other recognisable formats are rewritten as YYYY-MM-DD, and dates
+// that cannot be parsed are taken from the reading's time where there is one.
+// Any left over are reported and the migration stops so they can be fixed by
+// hand; nothing is converted until every row has a valid date.
+func migrateDateColumn(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&legacyUtilityData{}) {
+		return nil
+	}
+
+	columnTypes, err := migrator.ColumnTypes(&legacyUtilityData{})
+	if err != nil {
+		return err
+	}
+	for _, column := range columnTypes {
+		if column.Name() == "date" && strings.EqualFold(column.DatabaseTypeName(), "date") {
+			return nil
+		}
+	}
+
+	// Read times and site timezones may predate this conversion or not
+	columns := "utility_data.id, utility_data.date"
+	if migrator.HasColumn(&legacyUtilityData{}, "read_at") && migrator.HasColumn(&legacyClient{}, "timezone") {
+		columns += ", utility_data.read_at, clients.timezone"
+	}
+	var rows []struct {
+		ID       uint
+		Date     string
+		Timezone string
+		ReadAt   *time.Time
+	}
+	err = db.Table("utility_data").
+		Select(columns).
+		Joins("LEFT JOIN clients ON clients.id = utility_data.client_id").
+		Order("utility_data.id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	fixes := map[uint]string{}
+	var unparseable []string
+	for _, row := range rows {
+		date, ok := parseLegacyDate(row.Date)
+		if !ok && row.ReadAt != nil {
+			date, ok = models.DateOf(row.ReadAt.In(siteLocation(row.Timezone))), true
+			log.Warn("Reading has an unparseable date, using the date of its read time", "reading", row.ID, "date", row.Date, "readDate", date.String())
+		}
+		if !ok {
+			unparseable = append(unparseable, fmt.Sprintf("reading %d: %q", row.ID, row.Date))
+			continue
+		}
+		if date.String() != row.Date {
+			fixes[row.ID] = date.String()
+		}
+	}
+
+	if len(unparseable) > 0 {
+		for _, entry := range unparseable {
+			log.Error("Cannot convert date", "reading", entry)
+		}
+		return fmt.Errorf("%d readings have dates that cannot be parsed (%s); set them to YYYY-MM-DD and restart",
+			len(unparseable), strings.Join(unparseable, ", "))
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for id, date := range fixes {
+			if err := tx.Table("utility_data").Where("id = ?", id).Update("date", date).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// PostgreSQL needs to be told how to convert the text; SQLite rebuilds the table
+	if db.Dialector.Name() == "postgres" {
+		err = db.Exec("ALTER TABLE utility_data ALTER COLUMN date TYPE date USING date::date").Error
+	} else {
+		err = migrator.AlterColumn(&legacyUtilityData{}, "Date")
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Info("Converted utility_data.date to a DATE column", "rewritten", len(fixes), "readings", len(rows))
+	return nil
+}
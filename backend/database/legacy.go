@@ -0,0 +1,271 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/models"
+)
+
+// The types below freeze the models as they were when the schema was managed
+// by AutoMigrate. They bring databases created before versioned migrations up
+// to the baseline schema and must not change; later schema changes belong in
+// migrations.
+
+type legacyUser struct {
+	gorm.Model
+	Username string `gorm:"uniqueIndex;not null"`
+	Password string `gorm:"not null"`
+	Role     string `gorm:"not null;default:'operator'"`
+}
+
+func (legacyUser) TableName() string { return "users" }
+
+type legacyClient struct {
+	gorm.Model
+	Name        string `gorm:"not null"`
+	PlotNumber  string `gorm:"not null"`
+	Industry    string
+	Status      string `gorm:"default:'good'"`
+	Latitude    float64
+	Longitude   float64
+	ContactName string
+	Position    string
+	Email       string
+	Phone       string
+	Notes       string
+	Timezone    string `gorm:"not null;default:'Asia/Bangkok'"`
+
+	UtilityData []legacyUtilityData `gorm:"foreignKey:ClientID"`
+}
+
+func (legacyClient) TableName() string { return "clients" }
+
+type legacyUtilityData struct {
+	gorm.Model
+	ClientID         uint        `gorm:"not null;index:idx_utility_data_client_date,priority:1"`
+	Date             models.Date `gorm:"not null;index:idx_utility_data_client_date,priority:2"`
+	ReadAt           time.Time   `gorm:"index"`
+	Timezone         string
+	Notes            string
+	RecordedBy       *uint `gorm:"index"`
+	Corrected        bool  `gorm:"not null;default:false"`
+	Confirmed        bool  `gorm:"not null;default:false"`
+	SuspiciousFields string
+
+	Values   []legacyReadingValue `gorm:"foreignKey:UtilityDataID"`
+	Recorder *legacyUser          `gorm:"foreignKey:RecordedBy"`
+}
+
+func (legacyUtilityData) TableName() string { return "utility_data" }
+
+type legacyAlert struct {
+	gorm.Model
+	ClientID   uint   `gorm:"not null;index"`
+	Type       string `gorm:"not null"`
+	Title      string `gorm:"not null"`
+	Message    string
+	Status     string `gorm:"not null;default:'open'"`
+	ResolvedAt *time.Time
+}
+
+func (legacyAlert) TableName() string { return "alerts" }
+
+type legacyDigestSubscription struct {
+	gorm.Model
+	ClientID         uint   `gorm:"not null;index"`
+	Email            string `gorm:"not null"`
+	Name             string
+	SendHour         int    `gorm:"not null;default:7"`
+	Timezone         string `gorm:"not null;default:'Asia/Bangkok'"`
+	Active           bool   `gorm:"not null;default:true"`
+	UnsubscribeToken string `gorm:"uniqueIndex;not null"`
+	LastSentAt       *time.Time
+	UnsubscribedAt   *time.Time
+
+	Client legacyClient `gorm:"foreignKey:ClientID"`
+}
+
+func (legacyDigestSubscription) TableName() string { return "digest_subscriptions" }
+
+type legacyEmailLog struct {
+	gorm.Model
+	SubscriptionID *uint  `gorm:"index"`
+	Recipient      string `gorm:"not null"`
+	Subject        string
+	Kind           string `gorm:"not null"`
+	Status         string `gorm:"not null"`
+	Error          string
+	SentAt         time.Time
+}
+
+func (legacyEmailLog) TableName() string { return "email_logs" }
+
+type legacyWebhookSubscription struct {
+	gorm.Model
+	URL         string `gorm:"not null"`
+	Secret      string `gorm:"not null"`
+	Events      string `gorm:"not null"`
+	Description string
+	Active      bool `gorm:"not null;default:true"`
+}
+
+func (legacyWebhookSubscription) TableName() string { return "webhook_subscriptions" }
+
+type legacyWebhookDelivery struct {
+	gorm.Model
+	SubscriptionID uint      `gorm:"not null;index"`
+	Event          string    `gorm:"not null"`
+	Payload        string    `gorm:"not null"`
+	Status         string    `gorm:"not null;default:'pending';index"`
+	Attempts       int       `gorm:"not null;default:0"`
+	NextAttemptAt  time.Time `gorm:"index"`
+	LastError      string
+	ResponseStatus int
+	DeliveredAt    *time.Time
+}
+
+func (legacyWebhookDelivery) TableName() string { return "webhook_deliveries" }
+
+type legacyAuditEntry struct {
+	ID         uint      `gorm:"primaryKey"`
+	CreatedAt  time.Time `gorm:"index"`
+	UserID     *uint     `gorm:"index"`
+	Username   string    `gorm:"index"`
+	Role       string
+	IP         string
+	Method     string
+	Path       string
+	Status     int
+	Action     string `gorm:"not null;index"`
+	EntityType string `gorm:"index"`
+	EntityID   *uint  `gorm:"index"`
+	Before     string
+	After      string
+	Diff       string
+}
+
+func (legacyAuditEntry) TableName() string { return "audit_entries" }
+
+type legacyReadingCorrection struct {
+	gorm.Model
+	UtilityDataID uint   `gorm:"not null;index"`
+	Kind          string `gorm:"not null"`
+	Status        string `gorm:"not null;default:'pending';index"`
+	Reason        string
+	Original      string
+	Proposed      string
+	RequestedBy   *uint `gorm:"index"`
+	ReviewedBy    *uint
+	ReviewedAt    *time.Time
+	ReviewNote    string
+
+	UtilityData legacyUtilityData `gorm:"foreignKey:UtilityDataID"`
+}
+
+func (legacyReadingCorrection) TableName() string { return "reading_corrections" }
+
+type legacyValidationRule struct {
+	gorm.Model
+	ClientID *uint  `gorm:"index"`
+	Metric   string `gorm:"not null"`
+	Min      *float64
+	Max      *float64
+}
+
+func (legacyValidationRule) TableName() string { return "validation_rules" }
+
+type legacyMetric struct {
+	gorm.Model
+	Code        string `gorm:"size:64;uniqueIndex;not null"`
+	Name        string `gorm:"not null"`
+	Unit        string `gorm:"not null"`
+	Category    string `gorm:"not null"`
+	Aggregation string `gorm:"not null;default:'sum'"`
+	Precision   int    `gorm:"not null"`
+	SortOrder   int    `gorm:"not null"`
+	Min         *float64
+	Max         *float64
+	Density     *float64
+	Active      bool `gorm:"not null"`
+}
+
+func (legacyMetric) TableName() string { return "metrics" }
+
+type legacyReadingValue struct {
+	ID            uint    `gorm:"primarykey"`
+	UtilityDataID uint    `gorm:"not null;uniqueIndex:idx_reading_metric"`
+	MetricCode    string  `gorm:"size:64;not null;uniqueIndex:idx_reading_metric;index"`
+	Value         float64 `gorm:"not null"`
+	Unit          string
+	InputValue    *float64
+	InputUnit     string
+
+	Metric *legacyMetric `gorm:"foreignKey:MetricCode;references:Code"`
+}
+
+func (legacyReadingValue) TableName() string { return "reading_values" }
+
+// adoptLegacySchema upgrades a database created by AutoMigrate, from any
+// earlier release, to the baseline schema: it converts text dates, adds
+// missing tables and columns, moves the old usage columns into
+// reading_values and drops them, then records the baseline as applied
+func adoptLegacySchema(db *gorm.DB) error {
+	if err := migrateDateColumn(db); err != nil {
+		return err
+	}
+
+	// Catalogs from before densities get the defaults once the column is added
+	addDensities := db.Migrator().HasTable(&legacyMetric{}) && !db.Migrator().HasColumn(&legacyMetric{}, "density")
+
+	err := db.AutoMigrate(
+		&legacyUser{},
+		&legacyClient{},
+		&legacyUtilityData{},
+		&legacyAlert{},
+		&legacyDigestSubscription{},
+		&legacyEmailLog{},
+		&legacyWebhookSubscription{},
+		&legacyWebhookDelivery{},
+		&legacyAuditEntry{},
+		&legacyReadingCorrection{},
+		&legacyValidationRule{},
+		&legacyMetric{},
+		&legacyReadingValue{},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Older readings need the metric catalog, values and read times
+	if err := seedMetrics(db); err != nil {
+		return err
+	}
+	if addDensities {
+		if err := seedDensities(db); err != nil {
+			return err
+		}
+	}
+	if err := backfillReadingValues(db); err != nil {
+		return err
+	}
+	if err := backfillReadTimes(db); err != nil {
+		return err
+	}
+
+	// AutoMigrate never dropped the usage columns replaced by reading_values
+	for _, legacy := range legacyColumns {
+		if db.Migrator().HasColumn(&legacyUtilityData{}, legacy.column) {
+			if err := db.Migrator().DropColumn(&legacyUtilityData{}, legacy.column); err != nil {
+				return err
+			}
+			log.Info("Dropped legacy column", "column", "utility_data."+legacy.column)
+		}
+	}
+
+	if err := ensureMigrationsTable(db); err != nil {
+		return err
+	}
+	return recordMigration(db, Migration{Version: 1, Name: "baseline"})
+}
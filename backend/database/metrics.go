@@ -0,0 +1,102 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"utility-backend/models"
+)
+
+// defaultMetrics is the metric catalog every installation starts with
+var defaultMetrics = []models.Metric{
+	{Code: "water", Name: "Water", Unit: "m³", Category: "water", Aggregation: "sum", Precision: 1, SortOrder: 10, Min: floatPtr(0), Max: floatPtr(50000)},
+	{Code: "pac", Name: "PAC", Unit: "kg", Category: "chemical", Aggregation: "sum", Precision: 2, SortOrder: 20, Min: floatPtr(0), Max: floatPtr(5000), Density: floatPtr(1.2)},
+	{Code: "polymer", Name: "Polymer", Unit: "kg", Category: "chemical", Aggregation: "sum", Precision: 2, SortOrder: 30, Min: floatPtr(0), Max: floatPtr(2000), Density: floatPtr(1.0)},
+	{Code: "chlorine", Name: "Chlorine", Unit: "kg", Category: "chemical", Aggregation: "sum", Precision: 2, SortOrder: 40, Min: floatPtr(0), Max: floatPtr(2000), Density: floatPtr(1.2)},
+	{Code: "caustic_soda", Name: "Caustic Soda", Unit: "kg", Category: "chemical", Aggregation: "sum", Precision: 2, SortOrder: 50, Min: floatPtr(0), Max: floatPtr(5000), Density: floatPtr(1.52)},
+	{Code: "electricity", Name: "Electricity", Unit: "kWh", Category: "energy", Aggregation: "sum", Precision: 0, SortOrder: 60, Min: floatPtr(0), Max: floatPtr(1000000)},
+	{Code: "steam", Name: "Steam", Unit: "t", Category: "energy", Aggregation: "sum", Precision: 2, SortOrder: 70, Min: floatPtr(0), Max: floatPtr(5000)},
+	{Code: "bod", Name: "Wastewater BOD", Unit: "mg/L", Category: "effluent", Aggregation: "avg", Precision: 1, SortOrder: 80, Min: floatPtr(0), Max: floatPtr(10000)},
+	{Code: "cod", Name: "Wastewater COD", Unit: "mg/L", Category: "effluent", Aggregation: "avg", Precision: 1, SortOrder: 90, Min: floatPtr(0), Max: floatPtr(50000)},
+}
+
+// legacyColumns lists the metrics that used to be columns of utility_data and those columns
+var legacyColumns = []struct{ code, column string }{
+	{"water", "water_usage"},
+	{"pac", "pac_usage"},
+	{"polymer", "polymer_usage"},
+	{"chlorine", "chlorine_usage"},
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+// seedMetrics adds any default metric missing from the catalog. Existing
+// metrics are left as an admin configured them.
+func seedMetrics(db *gorm.DB) error {
+	for _, metric := range defaultMetrics {
+		var count int64
+		if err := db.Table("metrics").Where("code = ?", metric.Code).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		// Only baseline columns are written, as this also runs while adopting an old schema
+		metric.Active = true
+		err := db.Select("CreatedAt", "UpdatedAt", "Code", "Name", "Unit", "Category", "Aggregation",
+			"Precision", "SortOrder", "Min", "Max", "Density", "Active").Create(&metric).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedDensities gives the default metrics of a catalog created before
+// densities existed their default density. It runs once, when the density
+// column is added, so that a density an admin cleared later stays cleared.
+func seedDensities(db *gorm.DB) error {
+	for _, metric := range defaultMetrics {
+		if metric.Density == nil {
+			continue
+		}
+		err := db.Table("metrics").Where("code = ? AND density IS NULL", metric.Code).
+			Update("density", *metric.Density).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillReadingValues copies the values of readings stored before the
+// metric catalog from their legacy columns into reading_values, and fills in
+// the unit of values stored before units were recorded
+func backfillReadingValues(db *gorm.DB) error {
+	for _, legacy := range legacyColumns {
+		code, column := legacy.code, legacy.column
+		if !db.Migrator().HasColumn(&legacyUtilityData{}, column) {
+			continue
+		}
+
+		result := db.Exec(fmt.Sprintf(`INSERT INTO reading_values (utility_data_id, metric_code, value, unit)
+			SELECT id, ?, %[1]s, (SELECT unit FROM metrics WHERE code = ?) FROM utility_data
+			WHERE %[1]s IS NOT NULL AND NOT EXISTS (
+				SELECT 1 FROM reading_values rv WHERE rv.utility_data_id = utility_data.id AND rv.metric_code = ?
+			)`, column), code, code, code)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			log.Info("Copied values into reading_values", "metric", code, "values", result.RowsAffected)
+		}
+	}
+
+	// Values recorded before units were stored are in their metric's unit
+	return db.Exec(`UPDATE reading_values SET unit = (SELECT unit FROM metrics WHERE metrics.code = reading_values.metric_code)
+		WHERE unit IS NULL OR unit = ''`).Error
+}
@@ -0,0 +1,374 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationFiles holds the schema migrations, one directory per dialect.
+// Each version has a NNNN_name.up.sql and a NNNN_name.down.sql script.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationFilePattern matches migration script names
+var migrationFilePattern = regexp.MustCompile(`^(\d{4})_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// ErrSchemaTooNew is returned when the database has migrations this build does not know
+var ErrSchemaTooNew = errors.New("database schema is newer than this build")
+
+// ErrPendingMigrations is returned when the database is behind this build
+var ErrPendingMigrations = errors.New("database schema has pending migrations")
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// AppliedMigration is a migration recorded in schema_migrations
+type AppliedMigration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+// MigrationStatus describes how the database schema compares with this build
+type MigrationStatus struct {
+	Current int  // highest applied version, 0 for an empty database
+	Latest  int  // highest version this build knows
+	Legacy  bool // created by AutoMigrate and not yet adopted
+	Applied []AppliedMigration
+	Pending []Migration
+	Unknown []int // applied versions this build does not know
+}
+
+// dialect returns the migrations directory for the connected database
+func dialect(db *gorm.DB) string {
+	if db.Dialector.Name() == "postgres" {
+		return "postgres"
+	}
+	return "sqlite"
+}
+
+// Migrations returns the migrations for the connected database in version order
+func Migrations(db *gorm.DB) ([]Migration, error) {
+	dir := "migrations/" + dialect(db)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s/%s", dir, entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		script, err := fs.ReadFile(migrationFiles, dir+"/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %04d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(script)
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// ensureMigrationsTable creates the schema version table
+func ensureMigrationsTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`).Error
+}
+
+// isLegacy reports whether the database was created by AutoMigrate before
+// versioned migrations and has not been adopted yet
+func isLegacy(db *gorm.DB) bool {
+	migrator := db.Migrator()
+	return !migrator.HasTable("schema_migrations") && migrator.HasTable("users")
+}
+
+// Status compares the database schema with the migrations of this build
+func Status(db *gorm.DB) (MigrationStatus, error) {
+	var status MigrationStatus
+	migrations, err := Migrations(db)
+	if err != nil {
+		return status, err
+	}
+	if len(migrations) > 0 {
+		status.Latest = migrations[len(migrations)-1].Version
+	}
+
+	if isLegacy(db) {
+		status.Legacy = true
+		status.Pending = migrations
+		return status, nil
+	}
+	if db.Migrator().HasTable("schema_migrations") {
+		err := db.Table("schema_migrations").Order("version").Scan(&status.Applied).Error
+		if err != nil {
+			return status, err
+		}
+	}
+
+	applied := map[int]bool{}
+	for _, m := range status.Applied {
+		applied[m.Version] = true
+		if m.Version > status.Current {
+			status.Current = m.Version
+		}
+	}
+	known := map[int]bool{}
+	for _, m := range migrations {
+		known[m.Version] = true
+		if !applied[m.Version] {
+			status.Pending = append(status.Pending, m)
+		}
+	}
+	for _, m := range status.Applied {
+		if !known[m.Version] {
+			status.Unknown = append(status.Unknown, m.Version)
+		}
+	}
+	return status, nil
+}
+
+// CheckSchema returns an error unless the database is exactly at the version this build expects
+func CheckSchema(db *gorm.DB) error {
+	status, err := Status(db)
+	if err != nil {
+		return err
+	}
+	if len(status.Unknown) > 0 {
+		return fmt.Errorf("%w: version %d is applied but this build only knows up to %d; upgrade the application",
+			ErrSchemaTooNew, status.Unknown[len(status.Unknown)-1], status.Latest)
+	}
+	if len(status.Pending) > 0 {
+		return fmt.Errorf("%w: at version %d, this build needs %d; run the migrate command",
+			ErrPendingMigrations, status.Current, status.Latest)
+	}
+	return nil
+}
+
+// migrationLockKey is the key of the PostgreSQL advisory lock held while migrating
+var migrationLockKey = func() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("utility-backend migrations"))
+	return int64(h.Sum64())
+}()
+
+// withMigrationLock runs fn while holding the migration lock, so that of
+// several instances starting at once against the same database only one
+// migrates it and the others then find nothing pending. On PostgreSQL the
+// lock is a session-level advisory lock, waited for on a connection of its
+// own that fn is given to run on; the server releases it if the process
+// dies. SQLite already lets a single writer in at a time.
+func withMigrationLock(db *gorm.DB, fn func(db *gorm.DB) error) error {
+	if db.Dialector.Name() != "postgres" {
+		return fn(db)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("taking the migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey); err != nil {
+			log.Error("Failed to release the migration lock", "error", err)
+		}
+	}()
+
+	locked := db.Session(&gorm.Session{NewDB: true, Context: ctx})
+	locked.Statement.ConnPool = conn
+	return fn(locked)
+}
+
+// MigrateUp applies every pending migration in order and returns how many
+// were applied. A database created by AutoMigrate is first brought up to the
+// baseline schema and recorded as version 1.
+func MigrateUp(db *gorm.DB) (int, error) {
+	var applied int
+	err := withMigrationLock(db, func(db *gorm.DB) error {
+		var err error
+		applied, err = migrateUp(db)
+		return err
+	})
+	return applied, err
+}
+
+// migrateUp applies the pending migrations while the migration lock is held
+func migrateUp(db *gorm.DB) (int, error) {
+	status, err := Status(db)
+	if err != nil {
+		return 0, err
+	}
+	if len(status.Unknown) > 0 {
+		return 0, fmt.Errorf("%w: version %d is applied but this build only knows up to %d",
+			ErrSchemaTooNew, status.Unknown[len(status.Unknown)-1], status.Latest)
+	}
+
+	if status.Legacy {
+		log.Info("Adopting a database created before versioned migrations")
+		if err := adoptLegacySchema(db); err != nil {
+			return 0, fmt.Errorf("adopting existing schema: %w", err)
+		}
+		if status, err = Status(db); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := ensureMigrationsTable(db); err != nil {
+		return 0, err
+	}
+	for i, m := range status.Pending {
+		log.Info("Applying migration", "version", m.Version, "name", m.Name)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := execScript(tx, m.Up); err != nil {
+				return err
+			}
+			return recordMigration(tx, m)
+		})
+		if err != nil {
+			return i, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+	return len(status.Pending), nil
+}
+
+// MigrateDown reverts the most recent steps migrations and returns how many were reverted
+func MigrateDown(db *gorm.DB, steps int) (int, error) {
+	var reverted int
+	err := withMigrationLock(db, func(db *gorm.DB) error {
+		var err error
+		reverted, err = migrateDown(db, steps)
+		return err
+	})
+	return reverted, err
+}
+
+// migrateDown reverts migrations while the migration lock is held
+func migrateDown(db *gorm.DB, steps int) (int, error) {
+	status, err := Status(db)
+	if err != nil {
+		return 0, err
+	}
+	if status.Legacy {
+		return 0, errors.New("the database predates versioned migrations; run migrate up first")
+	}
+	if len(status.Unknown) > 0 {
+		return 0, fmt.Errorf("%w: cannot revert version %d", ErrSchemaTooNew, status.Unknown[len(status.Unknown)-1])
+	}
+
+	migrations, err := Migrations(db)
+	if err != nil {
+		return 0, err
+	}
+	byVersion := map[int]Migration{}
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	reverted := 0
+	for i := len(status.Applied) - 1; i >= 0 && reverted < steps; i-- {
+		m := byVersion[status.Applied[i].Version]
+		log.Info("Reverting migration", "version", m.Version, "name", m.Name)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := execScript(tx, m.Down); err != nil {
+				return err
+			}
+			return tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version).Error
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		reverted++
+	}
+	return reverted, nil
+}
+
+// recordMigration marks a migration as applied
+func recordMigration(tx *gorm.DB, m Migration) error {
+	return tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		m.Version, m.Name, time.Now().UTC()).Error
+}
+
+// execScript runs each statement of a migration script
+func execScript(tx *gorm.DB, script string) error {
+	for _, stmt := range splitStatements(script) {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitStatements splits a script into statements at semicolons that end a
+// line, dropping comment lines. Scripts must not contain such semicolons
+// inside statements.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
@@ -0,0 +1,15 @@
+-- Drops every table of the baseline schema
+
+DROP TABLE IF EXISTS "reading_values";
+DROP TABLE IF EXISTS "metrics";
+DROP TABLE IF EXISTS "validation_rules";
+DROP TABLE IF EXISTS "reading_corrections";
+DROP TABLE IF EXISTS "audit_entries";
+DROP TABLE IF EXISTS "webhook_deliveries";
+DROP TABLE IF EXISTS "webhook_subscriptions";
+DROP TABLE IF EXISTS "email_logs";
+DROP TABLE IF EXISTS "digest_subscriptions";
+DROP TABLE IF EXISTS "alerts";
+DROP TABLE IF EXISTS "utility_data";
+DROP TABLE IF EXISTS "clients";
+DROP TABLE IF EXISTS "users";
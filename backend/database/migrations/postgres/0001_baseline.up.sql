@@ -0,0 +1,246 @@
+-- Baseline schema, matching databases created by AutoMigrate before versioned migrations
+
+CREATE TABLE "users" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "username" text NOT NULL,
+  "password" text NOT NULL,
+  "role" text NOT NULL DEFAULT 'operator',
+  PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_users_username" ON "users"("username");
+CREATE INDEX "idx_users_deleted_at" ON "users"("deleted_at");
+
+CREATE TABLE "clients" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "name" text NOT NULL,
+  "plot_number" text NOT NULL,
+  "industry" text,
+  "status" text DEFAULT 'good',
+  "latitude" decimal,
+  "longitude" decimal,
+  "contact_name" text,
+  "position" text,
+  "email" text,
+  "phone" text,
+  "notes" text,
+  "timezone" text NOT NULL DEFAULT 'Asia/Bangkok',
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_clients_deleted_at" ON "clients"("deleted_at");
+
+CREATE TABLE "utility_data" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "client_id" bigint NOT NULL,
+  "date" date NOT NULL,
+  "read_at" timestamptz,
+  "timezone" text,
+  "notes" text,
+  "recorded_by" bigint,
+  "corrected" boolean NOT NULL DEFAULT false,
+  "confirmed" boolean NOT NULL DEFAULT false,
+  "suspicious_fields" text,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_utility_data_recorder" FOREIGN KEY ("recorded_by") REFERENCES "users"("id"),
+  CONSTRAINT "fk_clients_utility_data" FOREIGN KEY ("client_id") REFERENCES "clients"("id")
+);
+CREATE INDEX "idx_utility_data_recorded_by" ON "utility_data"("recorded_by");
+CREATE INDEX "idx_utility_data_read_at" ON "utility_data"("read_at");
+CREATE INDEX "idx_utility_data_client_date" ON "utility_data"("client_id","date");
+CREATE INDEX "idx_utility_data_deleted_at" ON "utility_data"("deleted_at");
+
+CREATE TABLE "alerts" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "client_id" bigint NOT NULL,
+  "type" text NOT NULL,
+  "title" text NOT NULL,
+  "message" text,
+  "status" text NOT NULL DEFAULT 'open',
+  "resolved_at" timestamptz,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_alerts_client_id" ON "alerts"("client_id");
+CREATE INDEX "idx_alerts_deleted_at" ON "alerts"("deleted_at");
+
+CREATE TABLE "digest_subscriptions" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "client_id" bigint NOT NULL,
+  "email" text NOT NULL,
+  "name" text,
+  "send_hour" bigint NOT NULL DEFAULT 7,
+  "timezone" text NOT NULL DEFAULT 'Asia/Bangkok',
+  "active" boolean NOT NULL DEFAULT true,
+  "unsubscribe_token" text NOT NULL,
+  "last_sent_at" timestamptz,
+  "unsubscribed_at" timestamptz,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_digest_subscriptions_client" FOREIGN KEY ("client_id") REFERENCES "clients"("id")
+);
+CREATE UNIQUE INDEX "idx_digest_subscriptions_unsubscribe_token" ON "digest_subscriptions"("unsubscribe_token");
+CREATE INDEX "idx_digest_subscriptions_client_id" ON "digest_subscriptions"("client_id");
+CREATE INDEX "idx_digest_subscriptions_deleted_at" ON "digest_subscriptions"("deleted_at");
+
+CREATE TABLE "email_logs" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "subscription_id" bigint,
+  "recipient" text NOT NULL,
+  "subject" text,
+  "kind" text NOT NULL,
+  "status" text NOT NULL,
+  "error" text,
+  "sent_at" timestamptz,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_email_logs_subscription_id" ON "email_logs"("subscription_id");
+CREATE INDEX "idx_email_logs_deleted_at" ON "email_logs"("deleted_at");
+
+CREATE TABLE "webhook_subscriptions" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "url" text NOT NULL,
+  "secret" text NOT NULL,
+  "events" text NOT NULL,
+  "description" text,
+  "active" boolean NOT NULL DEFAULT true,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_webhook_subscriptions_deleted_at" ON "webhook_subscriptions"("deleted_at");
+
+CREATE TABLE "webhook_deliveries" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "subscription_id" bigint NOT NULL,
+  "event" text NOT NULL,
+  "payload" text NOT NULL,
+  "status" text NOT NULL DEFAULT 'pending',
+  "attempts" bigint NOT NULL DEFAULT 0,
+  "next_attempt_at" timestamptz,
+  "last_error" text,
+  "response_status" bigint,
+  "delivered_at" timestamptz,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_webhook_deliveries_next_attempt_at" ON "webhook_deliveries"("next_attempt_at");
+CREATE INDEX "idx_webhook_deliveries_status" ON "webhook_deliveries"("status");
+CREATE INDEX "idx_webhook_deliveries_subscription_id" ON "webhook_deliveries"("subscription_id");
+CREATE INDEX "idx_webhook_deliveries_deleted_at" ON "webhook_deliveries"("deleted_at");
+
+CREATE TABLE "audit_entries" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "user_id" bigint,
+  "username" text,
+  "role" text,
+  "ip" text,
+  "method" text,
+  "path" text,
+  "status" bigint,
+  "action" text NOT NULL,
+  "entity_type" text,
+  "entity_id" bigint,
+  "before" text,
+  "after" text,
+  "diff" text,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_audit_entries_entity_id" ON "audit_entries"("entity_id");
+CREATE INDEX "idx_audit_entries_entity_type" ON "audit_entries"("entity_type");
+CREATE INDEX "idx_audit_entries_action" ON "audit_entries"("action");
+CREATE INDEX "idx_audit_entries_username" ON "audit_entries"("username");
+CREATE INDEX "idx_audit_entries_user_id" ON "audit_entries"("user_id");
+CREATE INDEX "idx_audit_entries_created_at" ON "audit_entries"("created_at");
+
+CREATE TABLE "reading_corrections" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "utility_data_id" bigint NOT NULL,
+  "kind" text NOT NULL,
+  "status" text NOT NULL DEFAULT 'pending',
+  "reason" text,
+  "original" text,
+  "proposed" text,
+  "requested_by" bigint,
+  "reviewed_by" bigint,
+  "reviewed_at" timestamptz,
+  "review_note" text,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_reading_corrections_utility_data" FOREIGN KEY ("utility_data_id") REFERENCES "utility_data"("id")
+);
+CREATE INDEX "idx_reading_corrections_deleted_at" ON "reading_corrections"("deleted_at");
+CREATE INDEX "idx_reading_corrections_requested_by" ON "reading_corrections"("requested_by");
+CREATE INDEX "idx_reading_corrections_status" ON "reading_corrections"("status");
+CREATE INDEX "idx_reading_corrections_utility_data_id" ON "reading_corrections"("utility_data_id");
+
+CREATE TABLE "validation_rules" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "client_id" bigint,
+  "metric" text NOT NULL,
+  "min" decimal,
+  "max" decimal,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_validation_rules_client_id" ON "validation_rules"("client_id");
+CREATE INDEX "idx_validation_rules_deleted_at" ON "validation_rules"("deleted_at");
+
+CREATE TABLE "metrics" (
+  "id" bigserial,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  "code" varchar(64) NOT NULL,
+  "name" text NOT NULL,
+  "unit" text NOT NULL,
+  "category" text NOT NULL,
+  "aggregation" text NOT NULL DEFAULT 'sum',
+  "precision" bigint NOT NULL,
+  "sort_order" bigint NOT NULL,
+  "min" decimal,
+  "max" decimal,
+  "density" decimal,
+  "active" boolean NOT NULL,
+  PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_metrics_code" ON "metrics"("code");
+CREATE INDEX "idx_metrics_deleted_at" ON "metrics"("deleted_at");
+
+CREATE TABLE "reading_values" (
+  "id" bigserial,
+  "utility_data_id" bigint NOT NULL,
+  "metric_code" varchar(64) NOT NULL,
+  "value" decimal NOT NULL,
+  "unit" text,
+  "input_value" decimal,
+  "input_unit" text,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_utility_data_values" FOREIGN KEY ("utility_data_id") REFERENCES "utility_data"("id"),
+  CONSTRAINT "fk_reading_values_metric" FOREIGN KEY ("metric_code") REFERENCES "metrics"("code")
+);
+CREATE UNIQUE INDEX "idx_reading_metric" ON "reading_values"("utility_data_id","metric_code");
+CREATE INDEX "idx_reading_values_metric_code" ON "reading_values"("metric_code");
+
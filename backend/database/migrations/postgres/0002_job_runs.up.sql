@@ -0,0 +1,20 @@
+-- History of scheduled job runs. A scheduled slot is run at most once
+-- across replicas; manual runs have no slot.
+
+CREATE TABLE "job_runs" (
+  "id" bigserial,
+  "job" text NOT NULL,
+  "trigger" text NOT NULL,
+  "scheduled_at" timestamptz,
+  "triggered_by" text,
+  "instance" text,
+  "status" text NOT NULL,
+  "error" text,
+  "started_at" timestamptz NOT NULL,
+  "finished_at" timestamptz,
+  PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_job_runs_job_scheduled_at" ON "job_runs"("job","scheduled_at");
+CREATE INDEX "idx_job_runs_job" ON "job_runs"("job");
+CREATE INDEX "idx_job_runs_status" ON "job_runs"("status");
+CREATE INDEX "idx_job_runs_started_at" ON "job_runs"("started_at");
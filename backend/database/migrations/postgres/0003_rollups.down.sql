@@ -0,0 +1,4 @@
+-- Drops the rollups; the readings they were derived from are kept
+
+DROP TABLE IF EXISTS "monthly_rollups";
+DROP TABLE IF EXISTS "daily_rollups";
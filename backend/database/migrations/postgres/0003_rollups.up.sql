@@ -0,0 +1,37 @@
+-- Daily and monthly totals per site and metric, read by the dashboard
+-- instead of scanning every reading. Existing readings are rolled up here;
+-- later ones are rolled up as they are written.
+
+CREATE TABLE "daily_rollups" (
+  "client_id" bigint,
+  "date" date,
+  "metric_code" varchar(64),
+  "total" decimal NOT NULL,
+  "readings" bigint NOT NULL,
+  PRIMARY KEY ("client_id","date","metric_code")
+);
+CREATE INDEX "idx_daily_rollups_date" ON "daily_rollups"("date");
+
+CREATE TABLE "monthly_rollups" (
+  "client_id" bigint,
+  "month" date,
+  "metric_code" varchar(64),
+  "total" decimal NOT NULL,
+  "readings" bigint NOT NULL,
+  "days" bigint NOT NULL,
+  PRIMARY KEY ("client_id","month","metric_code")
+);
+CREATE INDEX "idx_monthly_rollups_month" ON "monthly_rollups"("month");
+
+INSERT INTO "daily_rollups" ("client_id", "date", "metric_code", "total", "readings")
+SELECT "utility_data"."client_id", "utility_data"."date", "reading_values"."metric_code",
+  SUM("reading_values"."value"), COUNT(*)
+FROM "utility_data"
+JOIN "reading_values" ON "reading_values"."utility_data_id" = "utility_data"."id"
+WHERE "utility_data"."deleted_at" IS NULL
+GROUP BY "utility_data"."client_id", "utility_data"."date", "reading_values"."metric_code";
+
+INSERT INTO "monthly_rollups" ("client_id", "month", "metric_code", "total", "readings", "days")
+SELECT "client_id", date_trunc('month', "date")::date, "metric_code", SUM("total"), SUM("readings"), COUNT(*)
+FROM "daily_rollups"
+GROUP BY "client_id", date_trunc('month', "date")::date, "metric_code";
@@ -0,0 +1,10 @@
+-- Drops the baselines and reading scores; alerts raised for unusual
+-- readings are kept
+
+DROP INDEX IF EXISTS "idx_alerts_utility_data_id";
+ALTER TABLE "alerts" DROP COLUMN "utility_data_id";
+
+ALTER TABLE "reading_values" DROP COLUMN "expected";
+ALTER TABLE "reading_values" DROP COLUMN "score";
+
+DROP TABLE IF EXISTS "baselines";
@@ -0,0 +1,21 @@
+-- Learned baselines of each site's metrics, and the scores of readings
+-- against them. Baselines are learned by the learn-baselines job; readings
+-- already stored are not scored.
+
+CREATE TABLE "baselines" (
+  "client_id" bigint,
+  "metric_code" varchar(64),
+  "weekday" bigint,
+  "median" decimal NOT NULL,
+  "mad" decimal NOT NULL,
+  "factor" decimal NOT NULL,
+  "samples" bigint NOT NULL,
+  "learned_at" timestamptz NOT NULL,
+  PRIMARY KEY ("client_id","metric_code","weekday")
+);
+
+ALTER TABLE "reading_values" ADD COLUMN "score" decimal;
+ALTER TABLE "reading_values" ADD COLUMN "expected" decimal;
+
+ALTER TABLE "alerts" ADD COLUMN "utility_data_id" bigint;
+CREATE INDEX "idx_alerts_utility_data_id" ON "alerts"("utility_data_id");
@@ -0,0 +1,4 @@
+-- Failed digests are retried with a growing delay rather than every minute
+
+ALTER TABLE "digest_subscriptions" ADD COLUMN "failures" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "digest_subscriptions" ADD COLUMN "retry_at" timestamptz;
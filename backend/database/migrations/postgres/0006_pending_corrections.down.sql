@@ -0,0 +1,3 @@
+-- Drops the one pending correction per reading index
+
+DROP INDEX IF EXISTS "idx_reading_corrections_pending";
@@ -0,0 +1,8 @@
+-- A reading has at most one correction awaiting review. Of any pending
+-- corrections requested for the same reading before, the latest is kept.
+
+UPDATE "reading_corrections" SET "status" = 'rejected', "review_note" = 'Superseded by a later correction'
+WHERE "status" = 'pending' AND "id" NOT IN (
+  SELECT MAX("id") FROM "reading_corrections" WHERE "status" = 'pending' GROUP BY "utility_data_id"
+);
+CREATE UNIQUE INDEX "idx_reading_corrections_pending" ON "reading_corrections"("utility_data_id") WHERE "status" = 'pending';
@@ -0,0 +1,245 @@
+-- Baseline schema, matching databases created by AutoMigrate before versioned migrations
+
+CREATE TABLE `users` (
+  `id` integer,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `username` text NOT NULL,
+  `password` text NOT NULL,
+  `role` text NOT NULL DEFAULT "operator",
+  PRIMARY KEY (`id`)
+);
+CREATE UNIQUE INDEX `idx_users_username` ON `users`(`username`);
+CREATE INDEX `idx_users_deleted_at` ON `users`(`deleted_at`);
+
+CREATE TABLE `clients` (
+  `id` integer,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `name` text NOT NULL,
+  `plot_number` text NOT NULL,
+  `industry` text,
+  `status` text DEFAULT "good",
+  `latitude` real,
+  `longitude` real,
+  `contact_name` text,
+  `position` text,
+  `email` text,
+  `phone` text,
+  `notes` text,
+  `timezone` text NOT NULL DEFAULT "Asia/Bangkok",
+  PRIMARY KEY (`id`)
+);
+CREATE INDEX `idx_clients_deleted_at` ON `clients`(`deleted_at`);
+
+CREATE TABLE `utility_data` (
+  `id` integer,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `client_id` integer NOT NULL,
+  `date` date NOT NULL,
+  `read_at` datetime,
+  `timezone` text,
+  `notes` text,
+  `recorded_by` integer,
+  `corrected` numeric NOT NULL DEFAULT false,
+  `confirmed` numeric NOT NULL DEFAULT false,
+  `suspicious_fields` text,
+  PRIMARY KEY (`id`),
+  CONSTRAINT `fk_utility_data_recorder` FOREIGN KEY (`recorded_by`) REFERENCES `users`(`id`),
+  CONSTRAINT `fk_clients_utility_data` FOREIGN KEY (`client_id`) REFERENCES `clients`(`id`)
+);
+CREATE INDEX `idx_utility_data_recorded_by` ON `utility_data`(`recorded_by`);
+CREATE INDEX `idx_utility_data_read_at` ON `utility_data`(`read_at`);
+CREATE INDEX `idx_utility_data_client_date` ON `utility_data`(`client_id`,`date`);
+CREATE INDEX `idx_utility_data_deleted_at` ON `utility_data`(`deleted_at`);
+
+CREATE TABLE `alerts` (
+  `id` integer,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `client_id` integer NOT NULL,
+  `type` text NOT NULL,
+  `title` text NOT NULL,
+  `message` text,
+  `status` text NOT NULL DEFAULT "open",
+  `resolved_at` datetime,
+  PRIMARY KEY (`id`)
+);
+CREATE INDEX `idx_alerts_client_id` ON `alerts`(`client_id`);
+CREATE INDEX `idx_alerts_deleted_at` ON `alerts`(`deleted_at`);
+
+CREATE TABLE `digest_subscriptions` (
+  `id` integer,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `client_id` integer NOT NULL,
+  `email` text NOT NULL,
+  `name` text,
+  `send_hour` integer NOT NULL DEFAULT 7,
+  `timezone` text NOT NULL DEFAULT "Asia/Bangkok",
+  `active` numeric NOT NULL DEFAULT true,
+  `unsubscribe_token` text NOT NULL,
+  `last_sent_at` datetime,
+  `unsubscribed_at` datetime,
+  PRIMARY KEY (`id`),
+  CONSTRAINT `fk_digest_subscriptions_client` FOREIGN KEY (`client_id`) REFERENCES `clients`(`id`)
+);
+CREATE UNIQUE INDEX `idx_digest_subscriptions_unsubscribe_token` ON `digest_subscriptions`(`unsubscribe_token`);
+CREATE INDEX `idx_digest_subscriptions_client_id` ON `digest_subscriptions`(`client_id`);
+CREATE INDEX `idx_digest_subscriptions_deleted_at` ON `digest_subscriptions`(`deleted_at`);
+
+CREATE TABLE `email_logs` (
+  `id` integer,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `subscription_id` integer,
+  `recipient` text NOT NULL,
+  `subject` text,
+  `kind` text NOT NULL,
+  `status` text NOT NULL,
+  `error` text,
+  `sent_at` datetime,
+  PRIMARY KEY (`id`)
+);
+CREATE INDEX `idx_email_logs_subscription_id` ON `email_logs`(`subscription_id`);
+CREATE INDEX `idx_email_logs_deleted_at` ON `email_logs`(`deleted_at`);
+
+CREATE TABLE `webhook_subscriptions` (
+  `id` integer,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `url` text NOT NULL,
+  `secret` text NOT NULL,
+  `events` text NOT NULL,
+  `description` text,
+  `active` numeric NOT NULL DEFAULT true,
+  PRIMARY KEY (`id`)
+);
+CREATE INDEX `idx_webhook_subscriptions_deleted_at` ON `webhook_subscriptions`(`deleted_at`);
+
+CREATE TABLE `webhook_deliveries` (
+  `id` integer,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `subscription_id` integer NOT NULL,
+  `event` text NOT NULL,
+  `payload` text NOT NULL,
+  `status` text NOT NULL DEFAULT "pending",
+  `attempts` integer NOT NULL DEFAULT 0,
+  `next_attempt_at` datetime,
+  `last_error` text,
+  `response_status` integer,
+  `delivered_at` datetime,
+  PRIMARY KEY (`id`)
+);
+CREATE INDEX `idx_webhook_deliveries_next_attempt_at` ON `webhook_deliveries`(`next_attempt_at`);
+CREATE INDEX `idx_webhook_deliveries_status` ON `webhook_deliveries`(`status`);
+CREATE INDEX `idx_webhook_deliveries_subscription_id` ON `webhook_deliveries`(`subscription_id`);
+CREATE INDEX `idx_webhook_deliveries_deleted_at` ON `webhook_deliveries`(`deleted_at`);
+
+CREATE TABLE `audit_entries` (
+  `id` integer,
+  `created_at` datetime,
+  `user_id` integer,
+  `username` text,
+  `role` text,
+  `ip` text,
+  `method` text,
+  `path` text,
+  `status` integer,
+  `action` text NOT NULL,
+  `entity_type` text,
+  `entity_id` integer,
+  `before` text,
+  `after` text,
+  `diff` text,
+  PRIMARY KEY (`id`)
+);
+CREATE INDEX `idx_audit_entries_entity_id` ON `audit_entries`(`entity_id`);
+CREATE INDEX `idx_audit_entries_entity_type` ON `audit_entries`(`entity_type`);
+CREATE INDEX `idx_audit_entries_action` ON `audit_entries`(`action`);
+CREATE INDEX `idx_audit_entries_username` ON `audit_entries`(`username`);
+CREATE INDEX `idx_audit_entries_user_id` ON `audit_entries`(`user_id`);
+CREATE INDEX `idx_audit_entries_created_at` ON `audit_entries`(`created_at`);
+
+CREATE TABLE `reading_corrections` (
+  `id` integer,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `utility_data_id` integer NOT NULL,
+  `kind` text NOT NULL,
+  `status` text NOT NULL DEFAULT "pending",
+  `reason` text,
+  `original` text,
+  `proposed` text,
+  `requested_by` integer,
+  `reviewed_by` integer,
+  `reviewed_at` datetime,
+  `review_note` text,
+  PRIMARY KEY (`id`),
+  CONSTRAINT `fk_reading_corrections_utility_data` FOREIGN KEY (`utility_data_id`) REFERENCES `utility_data`(`id`)
+);
+CREATE INDEX `idx_reading_corrections_deleted_at` ON `reading_corrections`(`deleted_at`);
+CREATE INDEX `idx_reading_corrections_requested_by` ON `reading_corrections`(`requested_by`);
+CREATE INDEX `idx_reading_corrections_status` ON `reading_corrections`(`status`);
+CREATE INDEX `idx_reading_corrections_utility_data_id` ON `reading_corrections`(`utility_data_id`);
+
+CREATE TABLE `validation_rules` (
+  `id` integer,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `client_id` integer,
+  `metric` text NOT NULL,
+  `min` real,
+  `max` real,
+  PRIMARY KEY (`id`)
+);
+CREATE INDEX `idx_validation_rules_client_id` ON `validation_rules`(`client_id`);
+CREATE INDEX `idx_validation_rules_deleted_at` ON `validation_rules`(`deleted_at`);
+
+CREATE TABLE `metrics` (
+  `id` integer,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  `code` text NOT NULL,
+  `name` text NOT NULL,
+  `unit` text NOT NULL,
+  `category` text NOT NULL,
+  `aggregation` text NOT NULL DEFAULT "sum",
+  `precision` integer NOT NULL,
+  `sort_order` integer NOT NULL,
+  `min` real,
+  `max` real,
+  `density` real,
+  `active` numeric NOT NULL,
+  PRIMARY KEY (`id`)
+);
+CREATE UNIQUE INDEX `idx_metrics_code` ON `metrics`(`code`);
+CREATE INDEX `idx_metrics_deleted_at` ON `metrics`(`deleted_at`);
+
+CREATE TABLE `reading_values` (
+  `id` integer,
+  `utility_data_id` integer NOT NULL,
+  `metric_code` text NOT NULL,
+  `value` real NOT NULL,
+  `unit` text,
+  `input_value` real,
+  `input_unit` text,
+  PRIMARY KEY (`id`),
+  CONSTRAINT `fk_utility_data_values` FOREIGN KEY (`utility_data_id`) REFERENCES `utility_data`(`id`),
+  CONSTRAINT `fk_reading_values_metric` FOREIGN KEY (`metric_code`) REFERENCES `metrics`(`code`)
+);
+CREATE UNIQUE INDEX `idx_reading_metric` ON `reading_values`(`utility_data_id`,`metric_code`);
+CREATE INDEX `idx_reading_values_metric_code` ON `reading_values`(`metric_code`);
@@ -0,0 +1,3 @@
+-- Drops the job run history
+
+DROP TABLE IF EXISTS `job_runs`;
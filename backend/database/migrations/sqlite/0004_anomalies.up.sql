@@ -0,0 +1,21 @@
+-- Learned baselines of each site's metrics, and the scores of readings
+-- against them. Baselines are learned by the learn-baselines job; readings
+-- already stored are not scored.
+
+CREATE TABLE `baselines` (
+  `client_id` integer,
+  `metric_code` text,
+  `weekday` integer,
+  `median` real NOT NULL,
+  `mad` real NOT NULL,
+  `factor` real NOT NULL,
+  `samples` integer NOT NULL,
+  `learned_at` datetime NOT NULL,
+  PRIMARY KEY (`client_id`,`metric_code`,`weekday`)
+);
+
+ALTER TABLE `reading_values` ADD COLUMN `score` real;
+ALTER TABLE `reading_values` ADD COLUMN `expected` real;
+
+ALTER TABLE `alerts` ADD COLUMN `utility_data_id` integer;
+CREATE INDEX `idx_alerts_utility_data_id` ON `alerts`(`utility_data_id`);
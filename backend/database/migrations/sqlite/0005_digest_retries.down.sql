@@ -0,0 +1,4 @@
+-- Drops the retry state of digest subscriptions
+
+ALTER TABLE `digest_subscriptions` DROP COLUMN `retry_at`;
+ALTER TABLE `digest_subscriptions` DROP COLUMN `failures`;
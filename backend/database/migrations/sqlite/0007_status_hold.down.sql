@@ -0,0 +1,3 @@
+-- Drops the hold on site statuses set by hand
+
+ALTER TABLE `clients` DROP COLUMN `status_held_until`;
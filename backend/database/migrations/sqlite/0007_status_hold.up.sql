@@ -0,0 +1,3 @@
+-- A status set by hand is held for a while before it is recomputed
+
+ALTER TABLE `clients` ADD COLUMN `status_held_until` datetime;
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"utility-backend/logging"
+)
+
+var (
+	// log reports connections and migrations
+	log = logging.For("database")
+	// sqlLog reports the statements run
+	sqlLog = logging.For("sql")
+)
+
+// maxLoggedSQL caps the logged length of statements such as batch inserts
+const maxLoggedSQL = 1000
+
+// queryLogger writes GORM's statements to the sql log: failed ones as
+// errors, ones slower than slow as warnings and the rest at debug level.
+// The level is set with LOG_LEVELS rather than GORM's log modes.
+type queryLogger struct {
+	slow time.Duration
+}
+
+// LogMode implements logger.Interface
+func (l queryLogger) LogMode(logger.LogLevel) logger.Interface {
+	return l
+}
+
+// Info implements logger.Interface
+func (l queryLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	sqlLog.InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+// Warn implements logger.Interface
+func (l queryLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	sqlLog.WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+// Error implements logger.Interface
+func (l queryLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	sqlLog.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+// Trace implements logger.Interface
+func (l queryLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+
+	level, msg := slog.LevelDebug, "Query"
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		level, msg = slog.LevelError, "Query failed"
+	case l.slow > 0 && elapsed > l.slow:
+		level, msg = slog.LevelWarn, "Slow query"
+	}
+	if !sqlLog.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	if len(sql) > maxLoggedSQL {
+		sql = fmt.Sprintf("%s... (%d bytes)", sql[:maxLoggedSQL], len(sql))
+	}
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		logging.Duration(elapsed),
+	}
+	if level == slog.LevelError {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	sqlLog.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// ParamsFilter leaves the values out of logged statements, so that password
+// hashes and tokens never reach the logs
+func (l queryLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/models"
+)
+
+// defaultTimezone is used for sites without a valid timezone
+const defaultTimezone = "Asia/Bangkok"
+
+// siteLocation returns the location of a site's timezone, falling back to the default
+func siteLocation(name string) *time.Location {
+	if loc, err := time.LoadLocation(name); err == nil && name != "" {
+		return loc
+	}
+	if loc, err := time.LoadLocation(defaultTimezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// backfillReadTimes gives readings stored before timestamps were recorded a
+// read time at the start of their date in the site's timezone
+func backfillReadTimes(db *gorm.DB) error {
+	var readings []struct {
+		ID       uint
+		Date     models.Date
+		Timezone string
+	}
+	err := db.Table("utility_data").
+		Select("utility_data.id, utility_data.date, clients.timezone").
+		Joins("LEFT JOIN clients ON clients.id = utility_data.client_id").
+		Where("utility_data.read_at IS NULL").
+		Scan(&readings).Error
+	if err != nil {
+		return err
+	}
+
+	for _, r := range readings {
+		loc := siteLocation(r.Timezone)
+		err = db.Model(&legacyUtilityData{}).Unscoped().Where("id = ?", r.ID).
+			UpdateColumns(map[string]interface{}{"read_at": r.Date.In(loc).UTC(), "timezone": loc.String()}).Error
+		if err != nil {
+			return err
+		}
+	}
+	if len(readings) > 0 {
+		log.Info("Filled in the read time of readings", "readings", len(readings))
+	}
+	return nil
+}
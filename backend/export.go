@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"utility-backend/config"
+	"utility-backend/database"
+	"utility-backend/metrics"
+	"utility-backend/models"
+	"utility-backend/readingcsv"
+)
+
+// runExport handles the export subcommand, writing the same CSV as the export endpoint:
+//
+//	export [-client id] [-from YYYY-MM-DD] [-to YYYY-MM-DD] [-rollup daily|monthly] [-units metric:unit,...] [-o file]
+func runExport(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	clientID := flags.Uint("client", 0, "only export this site")
+	from := flags.String("from", "", "first local date, YYYY-MM-DD")
+	to := flags.String("to", "", "last local date, YYYY-MM-DD")
+	rollup := flags.String("rollup", "", "daily for one row per site and day, monthly for one per site and calendar month")
+	unitList := flags.String("units", "", "display units, e.g. water:gal,pac:lb")
+	output := flags.String("o", "-", "file to write, - for standard output")
+	flags.Usage = usageFunc(flags, "export [flags]")
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		flags.Usage()
+		return fmt.Errorf("unexpected argument %q", positional[0])
+	}
+	for name, value := range map[string]string{"from": *from, "to": *to} {
+		if value == "" {
+			continue
+		}
+		if _, err := models.ParseDate(value); err != nil {
+			return fmt.Errorf("invalid %s date %q, use YYYY-MM-DD", name, value)
+		}
+	}
+	if *rollup != "" && *rollup != "daily" && *rollup != "monthly" {
+		return fmt.Errorf("invalid rollup %q, use daily or monthly", *rollup)
+	}
+
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+
+	catalog, err := metrics.Catalog(db)
+	if err != nil {
+		return err
+	}
+	display, err := metrics.ParseDisplayUnits(*unitList, catalog)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	return readingcsv.Export(db, out, catalog, readingcsv.ExportOptions{
+		ClientID: *clientID,
+		From:     *from,
+		To:       *to,
+		Daily:    *rollup == "daily",
+		Monthly:  *rollup == "monthly",
+		Units:    display,
+	})
+}
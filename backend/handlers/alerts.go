@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"utility-backend/audit"
+	"utility-backend/models"
+	"utility-backend/repository"
+	"utility-backend/webhooks"
+)
+
+// AlertRequest represents the body for raising an alert
+type AlertRequest struct {
+	ClientID uint   `json:"clientId"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+}
+
+// ListAlerts returns alerts, optionally filtered by client and status
+func (h *Handler) ListAlerts(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	var clientID uint
+	if id := c.QueryInt("clientId"); id > 0 {
+		clientID = uint(id)
+	}
+
+	alerts, err := h.alerts.List(clientID, c.Query("status"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load alerts: " + err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    alerts,
+	})
+}
+
+// CreateAlert opens a new alert for a site
+func (h *Handler) CreateAlert(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	var req AlertRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	// Validate input
+	if req.ClientID == 0 || req.Title == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Client ID and title are required",
+		})
+	}
+	if req.Type == "" {
+		req.Type = "warning"
+	}
+	if req.Type != "info" && req.Type != "warning" && req.Type != "danger" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Type must be info, warning or danger",
+		})
+	}
+
+	client, err := h.clients.Find(req.ClientID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Site not found",
+		})
+	}
+
+	alert := models.Alert{
+		ClientID: client.ID,
+		Type:     req.Type,
+		Title:    req.Title,
+		Message:  req.Message,
+		Status:   repository.AlertOpen,
+	}
+	if err := h.alerts.Create(&alert); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save alert: " + err.Error(),
+		})
+	}
+
+	h.invalidate(alert.ClientID)
+	audit.Record(c, "alert.open", "alert", alert.ID, nil, alert)
+	h.webhooks.Publish(h.db, webhooks.EventAlertOpened, alert)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Alert opened successfully",
+		"data":    alert,
+	})
+}
+
+// ResolveAlert marks an open alert as resolved
+func (h *Handler) ResolveAlert(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid alert ID format",
+		})
+	}
+
+	alert, err := h.alerts.Find(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Alert not found",
+		})
+	}
+
+	if alert.Status != repository.AlertResolved {
+		before := alert
+		if err := h.alerts.Resolve(&alert, time.Now()); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to resolve alert: " + err.Error(),
+			})
+		}
+
+		h.invalidate(alert.ClientID)
+		audit.Record(c, "alert.resolve", "alert", alert.ID, before, alert)
+		h.webhooks.Publish(h.db, webhooks.EventAlertResolved, alert)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Alert resolved successfully",
+		"data":    alert,
+	})
+}
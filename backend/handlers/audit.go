@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"utility-backend/models"
+)
+
+// parseAuditTime accepts either an RFC 3339 timestamp or a YYYY-MM-DD date
+func parseAuditTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// ListAuditEntries returns audit entries, newest first, filtered by user,
+// action, entity and time range
+func (h *Handler) ListAuditEntries(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	query := h.db.Model(&models.AuditEntry{})
+
+	if userID := c.QueryInt("userId"); userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if username := c.Query("username"); username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if entityType := c.Query("entityType"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityID := c.QueryInt("entityId"); entityID > 0 {
+		query = query.Where("entity_id = ?", entityID)
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := parseAuditTime(from)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid from time, use YYYY-MM-DD or RFC 3339",
+			})
+		}
+		query = query.Where("created_at >= ?", t)
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseAuditTime(to)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid to time, use YYYY-MM-DD or RFC 3339",
+			})
+		}
+		// A bare date includes the whole day
+		if len(to) == len("2006-01-02") {
+			t = t.AddDate(0, 0, 1)
+		}
+		query = query.Where("created_at < ?", t)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to count audit entries: " + err.Error(),
+		})
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	var entries []models.AuditEntry
+	if err := query.Order("created_at desc, id desc").Limit(limit).Offset(offset).Find(&entries).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load audit entries: " + err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    entries,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
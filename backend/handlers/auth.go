@@ -3,9 +3,7 @@ package handlers
 import (
 	"github.com/gofiber/fiber/v2"
 
-	"utility-backend/database"
 	"utility-backend/middlewares"
-	"utility-backend/models"
 )
 
 // LoginRequest represents the login request body
@@ -15,7 +13,9 @@ type LoginRequest struct {
 }
 
 // Login handles user authentication and returns a JWT token
-func Login(c *fiber.Ctx) error {
+func (h *Handler) Login(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
 	// Parse request body
 	var req LoginRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -34,9 +34,8 @@ func Login(c *fiber.Ctx) error {
 	}
 
 	// Find user in database
-	var user models.User
-	result := database.DB.Where("username = ?", req.Username).First(&user)
-	if result.Error != nil {
+	user, err := h.users.FindByUsername(req.Username)
+	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"success": false,
 			"message": "Invalid username or password",
@@ -52,7 +51,7 @@ func Login(c *fiber.Ctx) error {
 	}
 
 	// Generate JWT token
-	token, err := middlewares.GenerateToken(user)
+	token, err := middlewares.GenerateToken(user, h.jwtSecret)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -62,10 +61,10 @@ func Login(c *fiber.Ctx) error {
 
 	// Return success response with token
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"success": true,
-		"token": token,
+		"success":  true,
+		"token":    token,
 		"username": user.Username,
-		"role": user.Role,
-		"message": "Login successful",
+		"role":     user.Role,
+		"message":  "Login successful",
 	})
-} 
\ No newline at end of file
+}
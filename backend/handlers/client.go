@@ -7,12 +7,26 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 
-	"utility-backend/database"
+	"utility-backend/audit"
+	"utility-backend/metrics"
 	"utility-backend/models"
+	"utility-backend/sites"
+	"utility-backend/units"
+	"utility-backend/validation"
 )
 
-// GetClient returns detailed information for a specific client
-func GetClient(c *fiber.Ctx) error {
+// UpdateClientStatusRequest represents the body for changing a client's status
+type UpdateClientStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// GetClient returns detailed information for a specific client. Charts
+// cover the last window days with readings (30 by default) and the table
+// the latest rows readings (7 by default); older readings are paged through
+// with ListClientReadings.
+func (h *Handler) GetClient(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
 	// Get client ID from URL parameter
 	id := c.Params("id")
 	if id == "" {
@@ -32,19 +46,29 @@ func GetClient(c *fiber.Ctx) error {
 	}
 
 	// Get client from database
-	var client models.Client
-	result := database.DB.First(&client, clientID)
-	if result.Error != nil {
+	client, err := h.clients.Find(uint(clientID))
+	if err != nil {
 		// Return mock data for demo purposes
 		return c.Status(fiber.StatusOK).JSON(getMockClientDetails(clientID))
 	}
 
-	// Get utility data for this client
-	var utilityData []models.UtilityData
-	database.DB.Where("client_id = ?", clientID).Order("date").Find(&utilityData)
+	window := c.QueryInt("window", 30)
+	if window < 1 || window > 366 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Window must be between 1 and 366 days",
+		})
+	}
+	tableRows := c.QueryInt("rows", 7)
+	if tableRows < 0 || tableRows > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Rows must be between 0 and 100",
+		})
+	}
 
 	// If no utility data found, return mock data
-	if len(utilityData) == 0 {
+	if found, _ := h.readings.Exists(client.ID); !found {
 		return c.Status(fiber.StatusOK).JSON(getMockClientDetails(clientID))
 	}
 
@@ -64,26 +88,73 @@ func GetClient(c *fiber.Ctx) error {
 		ContractEnd:   "31 Dec 2025",
 	}
 
+	catalog, err := metrics.Catalog(h.db)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load metric catalog: " + err.Error(),
+		})
+	}
+	display, err := displayUnits(c, catalog)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
 	// Fill summary data
-	var totalWaterUsage, totalChemicalUsage float64
-	for _, data := range utilityData {
-		totalWaterUsage += data.WaterUsage
-		totalChemicalUsage += data.PacUsage + data.PolymerUsage + data.ChlorineUsage
+	totals, err := metrics.Totals(h.db, client.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load totals: " + err.Error(),
+		})
 	}
 
-	response.Summary.WaterMonthlyAvg = totalWaterUsage / float64(len(utilityData))
-	response.Summary.ChemicalMonthlyAvg = totalChemicalUsage / float64(len(utilityData))
+	// Monthly figures are per calendar month in the site's timezone
+	today := models.DateOf(time.Now().In(validation.Location(client.Timezone)))
+	monthly, err := metrics.Monthly(h.db, client.ID, today)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load monthly totals: " + err.Error(),
+		})
+	}
+	water := monthly["water"]
+	response.Summary.WaterMonthlyAvg = water.Average
+	response.Summary.WaterMonthToDate = water.MonthToDate
+	response.Summary.WaterProjectedMonthEnd = water.ProjectedMonthEnd
+	response.Summary.CompleteMonths = water.CompleteMonths
+	// Chemicals add up by weight; those that cannot be weighed are left out
+	for _, m := range catalog {
+		if m.Category != "chemical" || m.Aggregation != metrics.AggregationSum {
+			continue
+		}
+		perKg, err := units.Convert(1, m.Unit, "kg", m.Density)
+		if err != nil {
+			continue
+		}
+		chemical := monthly[m.Code]
+		response.Summary.ChemicalMonthlyAvg += chemical.Average * perKg
+		response.Summary.ChemicalMonthToDate += chemical.MonthToDate * perKg
+		response.Summary.ChemicalProjectedMonthEnd += chemical.ProjectedMonthEnd * perKg
+	}
 	response.Summary.LastInspection = "15 Mar 2023" // Mock inspection dates
 	response.Summary.NextInspection = "15 Jun 2023"
 
-	// Prepare chart data
-	// Limit to last 30 entries if there are more
-	dataLength := len(utilityData)
-	if dataLength > 30 {
-		utilityData = utilityData[dataLength-30:]
-		dataLength = 30
+	// Readings are rolled up per day for the charts
+	days, err := metrics.DailyRollup(h.db, client.ID, window)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load readings: " + err.Error(),
+		})
 	}
 
+	// Prepare chart data
+	dataLength := len(days)
+
 	response.WaterUsage.Labels = make([]string, dataLength)
 	response.WaterUsage.Data = make([]float64, dataLength)
 	response.ChemicalUsage.Labels = make([]string, dataLength)
@@ -91,22 +162,17 @@ func GetClient(c *fiber.Ctx) error {
 	response.ChemicalUsage.Polymer = make([]float64, dataLength)
 	response.ChemicalUsage.Chlorine = make([]float64, dataLength)
 
-	for i, data := range utilityData {
-		// Format date for label
-		t, err := time.Parse("2006-01-02", data.Date)
-		if err != nil {
-			response.WaterUsage.Labels[i] = data.Date
-		} else {
-			response.WaterUsage.Labels[i] = t.Format("Jan 2")
-		}
-
+	for i, day := range days {
+		response.WaterUsage.Labels[i] = day.Date.Format("Jan 2")
 		response.ChemicalUsage.Labels[i] = response.WaterUsage.Labels[i]
-		response.WaterUsage.Data[i] = data.WaterUsage
-		response.ChemicalUsage.Pac[i] = data.PacUsage
-		response.ChemicalUsage.Polymer[i] = data.PolymerUsage
-		response.ChemicalUsage.Chlorine[i] = data.ChlorineUsage
+		response.WaterUsage.Data[i] = day.Value("water")
+		response.ChemicalUsage.Pac[i] = day.Value("pac")
+		response.ChemicalUsage.Polymer[i] = day.Value("polymer")
+		response.ChemicalUsage.Chlorine[i] = day.Value("chlorine")
 	}
 
+	response.Metrics = metricSeries(catalog, days, response.WaterUsage.Labels, totals, monthly, display)
+
 	// Add mock notes
 	response.Notes = []struct {
 		Type    string `json:"type"`
@@ -128,44 +194,36 @@ func GetClient(c *fiber.Ctx) error {
 		},
 	}
 
-	// Prepare table data (last 7 days)
-	tableLength := 7
-	if dataLength < 7 {
-		tableLength = dataLength
+	// Prepare table data (latest readings)
+	utilityData, err := h.readings.Recent(client.ID, tableRows)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load readings: " + err.Error(),
+		})
 	}
+	response.TableData = make([]models.TableRow, len(utilityData))
 
-	startIdx := dataLength - tableLength
-	response.TableData = make([]struct {
-		Date     string  `json:"date"`
-		Water    float64 `json:"water"`
-		Pac      float64 `json:"pac"`
-		Polymer  float64 `json:"polymer"`
-		Chlorine float64 `json:"chlorine"`
-	}, tableLength)
-
-	for i := 0; i < tableLength; i++ {
-		idx := startIdx + i
-		data := utilityData[idx]
-
-		// Format date for table
-		t, err := time.Parse("2006-01-02", data.Date)
-		dateStr := data.Date
-		if err == nil {
-			dateStr = t.Format("Jan 2, 2006")
-		}
+	// Mark readings that have a correction awaiting approval
+	pending := map[uint]bool{}
+	pendingIDs, _ := h.readings.WithPendingCorrection(readingIDs(utilityData))
+	for _, id := range pendingIDs {
+		pending[id] = true
+	}
 
-		response.TableData[i] = struct {
-			Date     string  `json:"date"`
-			Water    float64 `json:"water"`
-			Pac      float64 `json:"pac"`
-			Polymer  float64 `json:"polymer"`
-			Chlorine float64 `json:"chlorine"`
-		}{
-			Date:     dateStr,
-			Water:    data.WaterUsage,
-			Pac:      data.PacUsage,
-			Polymer:  data.PolymerUsage,
-			Chlorine: data.ChlorineUsage,
+	for i, data := range utilityData {
+		response.TableData[i] = models.TableRow{
+			ID:                data.ID,
+			Date:              data.Date.Format("Jan 2, 2006"),
+			ReadAt:            data.ReadAt,
+			Water:             data.Value("water"),
+			Pac:               data.Value("pac"),
+			Polymer:           data.Value("polymer"),
+			Chlorine:          data.Value("chlorine"),
+			Corrected:         data.Corrected,
+			PendingCorrection: pending[data.ID],
+			SuspiciousFields:  data.SuspiciousFields,
+			Values:            displayValues(catalog, data, display),
 		}
 	}
 
@@ -200,6 +258,80 @@ func GetClient(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(response)
 }
 
+// UpdateClientStatus changes a client's status (good, warning or danger).
+// The recompute-status job leaves the status as set for sites.ManualHold.
+func (h *Handler) UpdateClientStatus(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	clientID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid client ID format",
+		})
+	}
+
+	var req UpdateClientStatusRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	if !sites.IsStatus(req.Status) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Status must be good, warning or danger",
+		})
+	}
+
+	client, err := h.clients.Find(uint(clientID))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Client not found",
+		})
+	}
+
+	before := client
+	if err := sites.SetManualStatus(h.db, h.webhooks, &client, req.Status, time.Now()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update status: " + err.Error(),
+		})
+	}
+
+	h.invalidate(client.ID)
+	audit.Record(c, "client.status_change", "client", client.ID, before, client)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Status updated successfully",
+		"data":    client,
+	})
+}
+
+// displayValues returns a reading's values keyed by metric code, in their display units
+func displayValues(catalog []models.Metric, data models.UtilityData, display map[string]string) map[string]float64 {
+	values := map[string]float64{}
+	for _, m := range catalog {
+		if v, ok := data.Lookup(m.Code); ok {
+			values[m.Code] = metrics.InDisplayUnit(m, v, display)
+		}
+	}
+	return values
+}
+
+// readingIDs returns the IDs of the given readings
+func readingIDs(data []models.UtilityData) []uint {
+	ids := make([]uint, len(data))
+	for i, d := range data {
+		ids[i] = d.ID
+	}
+	return ids
+}
+
 // Helper function for mock client data
 func getMockClientDetails(id int) models.ClientDetailResponse {
 	// Generate mock client details
@@ -220,7 +352,12 @@ func getMockClientDetails(id int) models.ClientDetailResponse {
 
 	// Set summary data
 	response.Summary.WaterMonthlyAvg = 750.5
+	response.Summary.WaterMonthToDate = 410.2
+	response.Summary.WaterProjectedMonthEnd = 732.4
 	response.Summary.ChemicalMonthlyAvg = 45.2
+	response.Summary.ChemicalMonthToDate = 23.8
+	response.Summary.ChemicalProjectedMonthEnd = 42.5
+	response.Summary.CompleteMonths = 6
 	response.Summary.LastInspection = "15 Mar 2023"
 	response.Summary.NextInspection = "15 Jun 2023"
 
@@ -274,25 +411,13 @@ func getMockClientDetails(id int) models.ClientDetailResponse {
 	}
 
 	// Generate table data for the last 7 days
-	response.TableData = make([]struct {
-		Date     string  `json:"date"`
-		Water    float64 `json:"water"`
-		Pac      float64 `json:"pac"`
-		Polymer  float64 `json:"polymer"`
-		Chlorine float64 `json:"chlorine"`
-	}, 7)
+	response.TableData = make([]models.TableRow, 7)
 
 	for i := 0; i < 7; i++ {
 		date := now.AddDate(0, 0, i-6)
 		dateStr := date.Format("Jan 2, 2006")
 
-		response.TableData[i] = struct {
-			Date     string  `json:"date"`
-			Water    float64 `json:"water"`
-			Pac      float64 `json:"pac"`
-			Polymer  float64 `json:"polymer"`
-			Chlorine float64 `json:"chlorine"`
-		}{
+		response.TableData[i] = models.TableRow{
 			Date:     dateStr,
 			Water:    50.0 + float64(i*20) + float64(id*5),
 			Pac:      2.0 + float64(i%5) + float64(id%3),
@@ -329,4 +454,4 @@ func getMockClientDetails(id int) models.ClientDetailResponse {
 	}
 
 	return response
-} 
\ No newline at end of file
+}
@@ -0,0 +1,582 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"utility-backend/anomaly"
+	"utility-backend/audit"
+	"utility-backend/models"
+	"utility-backend/repository"
+	"utility-backend/validation"
+	"utility-backend/webhooks"
+)
+
+// CorrectReadingRequest represents the body of a reading edit; omitted fields keep their value
+type CorrectReadingRequest struct {
+	Date       *string            `json:"date"`
+	ReadAt     *string            `json:"readAt"`
+	WaterMeter *float64           `json:"waterMeter"`
+	Pac        *float64           `json:"pac"`
+	Polymer    *float64           `json:"polymer"`
+	Chlorine   *float64           `json:"chlorine"`
+	Values     map[string]float64 `json:"values"` // any catalog metric, keyed by code
+	Units      map[string]string  `json:"units"`  // unit of each value keyed by metric code, if not the catalog unit
+	Notes      *string            `json:"notes"`
+	Reason     string             `json:"reason"`
+}
+
+// VoidReadingRequest represents the body of a reading void
+type VoidReadingRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ReviewCorrectionRequest represents the body of an approval or rejection
+type ReviewCorrectionRequest struct {
+	Note string `json:"note"`
+}
+
+// readingValues captures the current editable values of a reading
+func readingValues(data models.UtilityData) models.ReadingValues {
+	return models.ReadingValues{
+		Date:   &data.Date,
+		ReadAt: &data.ReadAt,
+		Values: data.ValueMap(),
+		Inputs: valueInputs(data.Values),
+		Notes:  &data.Notes,
+	}
+}
+
+// valueInputs returns the values of rows that were entered in another unit, keyed by metric code
+func valueInputs(rows []models.ReadingValue) map[string]models.Input {
+	var inputs map[string]models.Input
+	for _, row := range rows {
+		if row.InputValue == nil {
+			continue
+		}
+		if inputs == nil {
+			inputs = map[string]models.Input{}
+		}
+		inputs[row.MetricCode] = models.Input{Value: *row.InputValue, Unit: row.InputUnit}
+	}
+	return inputs
+}
+
+// proposedMetricValues returns the metric values of a correction, including
+// those of corrections recorded before the metric catalog
+func proposedMetricValues(values models.ReadingValues) map[string]float64 {
+	return legacyValues(values.Values, values.WaterUsage, values.PacUsage, values.PolymerUsage, values.ChlorineUsage)
+}
+
+// applyReadingValues copies the set date, time and notes of values onto a reading
+func applyReadingValues(data *models.UtilityData, values models.ReadingValues) {
+	if values.Date != nil {
+		data.Date = *values.Date
+	}
+	if values.ReadAt != nil {
+		data.ReadAt = *values.ReadAt
+	} else if values.Date != nil {
+		// Corrections requested before readings were timestamped only move
+		// the date, so keep the time of day
+		loc := validation.Location(data.Timezone)
+		clock := data.ReadAt.In(loc)
+		data.ReadAt = time.Date(data.Date.Year(), data.Date.Month(), data.Date.Day(),
+			clock.Hour(), clock.Minute(), clock.Second(), 0, loc).UTC()
+	}
+	if values.Notes != nil {
+		data.Notes = *values.Notes
+	}
+}
+
+// toJSONText encodes a value for a JSON text column
+func toJSONText(v interface{}) models.JSONText {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return models.JSONText(data)
+}
+
+// requestCorrection loads the reading and stores a pending correction for it
+func (h *Handler) requestCorrection(c *fiber.Ctx, kind, reason string, proposed *models.ReadingValues, date, readAt *string) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid reading ID format",
+		})
+	}
+
+	reading, err := h.readings.Find(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Reading not found",
+		})
+	}
+
+	// Edits must pass the same rules as new submissions
+	if proposed != nil {
+		var errs []validation.FieldError
+		if date != nil || readAt != nil {
+			errs = proposeReadingTime(proposed, reading, date, readAt)
+		}
+
+		valueErrs, err := validation.ValidateValues(h.db, reading.ClientID, proposed.Values)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to load validation rules: " + err.Error(),
+			})
+		}
+		if errs = append(errs, valueErrs...); len(errs) > 0 {
+			return validationFailed(c, errs)
+		}
+	}
+
+	// Only one correction per reading may be awaiting review. A unique index
+	// enforces it for requests racing past this check.
+	var pendingCount int64
+	err = h.db.Model(&models.ReadingCorrection{}).
+		Where("utility_data_id = ? AND status = ?", reading.ID, "pending").
+		Count(&pendingCount).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load corrections: " + err.Error(),
+		})
+	}
+	if pendingCount > 0 {
+		return pendingConflict(c)
+	}
+
+	correction := models.ReadingCorrection{
+		UtilityDataID: reading.ID,
+		Kind:          kind,
+		Status:        "pending",
+		Reason:        reason,
+		Original:      toJSONText(readingValues(reading)),
+	}
+	if proposed != nil {
+		correction.Proposed = toJSONText(proposed)
+	}
+	if userID, ok := c.Locals("userID").(uint); ok && userID != 0 {
+		correction.RequestedBy = &userID
+	}
+
+	err = h.db.Create(&correction).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return pendingConflict(c)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save correction: " + err.Error(),
+		})
+	}
+
+	// Site pages flag readings with a correction awaiting review
+	h.invalidate(reading.ClientID)
+	audit.Record(c, "correction.request", "reading_correction", correction.ID, nil, correction)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"message": "Correction submitted for admin approval",
+		"data":    correction,
+	})
+}
+
+// pendingConflict responds to a correction requested for a reading that
+// already has one awaiting review
+func pendingConflict(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+		"success": false,
+		"message": "This reading already has a correction awaiting approval",
+	})
+}
+
+// proposeReadingTime validates a proposed date or time and fills in both, so
+// the reading's date always matches its time. When only the date changes the
+// time of day is kept.
+func proposeReadingTime(proposed *models.ReadingValues, reading models.UtilityData, date, readAt *string) []validation.FieldError {
+	loc := validation.Location(reading.Timezone)
+	now := time.Now()
+
+	value := ""
+	if date != nil {
+		value = *date
+	}
+
+	var t time.Time
+	var d models.Date
+	var errs []validation.FieldError
+	if readAt != nil {
+		if t, d, errs = readingTime(*readAt, value, loc, now); len(errs) > 0 {
+			return errs
+		}
+	} else {
+		if d, errs = validation.ValidateDate("date", value, now, loc); len(errs) > 0 {
+			return errs
+		}
+		clock := reading.ReadAt.In(loc)
+		t = time.Date(d.Year(), d.Month(), d.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, loc)
+	}
+
+	t = t.UTC()
+	proposed.ReadAt = &t
+	proposed.Date = &d
+	return nil
+}
+
+// UpdateReading requests an edit of a reading. The change is applied once an admin approves it.
+func (h *Handler) UpdateReading(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	var req CorrectReadingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	proposed := models.ReadingValues{
+		Values: legacyValues(req.Values, req.WaterMeter, req.Pac, req.Polymer, req.Chlorine),
+		Notes:  req.Notes,
+	}
+
+	// Validate input
+	if req.Date == nil && req.ReadAt == nil && proposed.Notes == nil && len(proposed.Values) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "At least one value to change is required",
+		})
+	}
+	if req.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "A reason for the correction is required",
+		})
+	}
+
+	// Proposed values are stored in catalog units
+	values, rows, unitErrs, err := h.convertValues(proposed.Values, req.Units)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load metric catalog: " + err.Error(),
+		})
+	}
+	if len(unitErrs) > 0 {
+		return validationFailed(c, unitErrs)
+	}
+	proposed.Values = values
+	proposed.Inputs = valueInputs(rows)
+
+	return h.requestCorrection(c, "edit", req.Reason, &proposed, req.Date, req.ReadAt)
+}
+
+// VoidReading requests that a reading be voided. Once approved, the reading is
+// excluded from every view but kept in the database.
+func (h *Handler) VoidReading(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	var req VoidReadingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	if req.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "A reason for voiding the reading is required",
+		})
+	}
+
+	return h.requestCorrection(c, "void", req.Reason, nil, nil, nil)
+}
+
+// ListCorrections returns reading corrections, optionally filtered by status and reading
+func (h *Handler) ListCorrections(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	query := h.db.Order("created_at desc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if readingID := c.QueryInt("readingId"); readingID > 0 {
+		query = query.Where("utility_data_id = ?", readingID)
+	}
+
+	var corrections []models.ReadingCorrection
+	if err := query.Find(&corrections).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load corrections: " + err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    corrections,
+	})
+}
+
+// loadPendingCorrection parses the review request and loads the pending correction it targets
+func (h *Handler) loadPendingCorrection(c *fiber.Ctx) (models.ReadingCorrection, ReviewCorrectionRequest, error) {
+	var correction models.ReadingCorrection
+	var req ReviewCorrectionRequest
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return correction, req, fiber.NewError(fiber.StatusBadRequest, "Invalid correction ID format")
+	}
+
+	// The note is optional, so an empty body is allowed
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return correction, req, fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+	}
+
+	if err := h.db.First(&correction, id).Error; err != nil {
+		return correction, req, fiber.NewError(fiber.StatusNotFound, "Correction not found")
+	}
+	if correction.Status != "pending" {
+		return correction, req, errAlreadyReviewed
+	}
+
+	return correction, req, nil
+}
+
+// errAlreadyReviewed is returned for a correction that is no longer pending
+var errAlreadyReviewed = fiber.NewError(fiber.StatusConflict, "Correction has already been reviewed")
+
+// saveReview records the review of a correction if it is still pending, so
+// that of two reviewers acting at once only the first one's review counts.
+// It returns errAlreadyReviewed otherwise.
+func saveReview(tx *gorm.DB, correction *models.ReadingCorrection) error {
+	result := tx.Model(correction).Where("status = ?", "pending").
+		Select("status", "reviewed_by", "reviewed_at", "review_note").
+		Updates(correction)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errAlreadyReviewed
+	}
+	return nil
+}
+
+// markReviewed fills in the reviewer fields of a correction
+func markReviewed(c *fiber.Ctx, correction *models.ReadingCorrection, status, note string) {
+	now := time.Now()
+	correction.Status = status
+	correction.ReviewedAt = &now
+	correction.ReviewNote = note
+	if userID, ok := c.Locals("userID").(uint); ok && userID != 0 {
+		correction.ReviewedBy = &userID
+	}
+}
+
+// ApproveCorrection applies a pending correction to its reading
+func (h *Handler) ApproveCorrection(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	correction, req, err := h.loadPendingCorrection(c)
+	if err != nil {
+		e := err.(*fiber.Error)
+		return c.Status(e.Code).JSON(fiber.Map{
+			"success": false,
+			"message": e.Message,
+		})
+	}
+
+	reading, err := h.readings.Find(correction.UtilityDataID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Reading not found",
+		})
+	}
+	before := correction
+	readingBefore := reading
+
+	markReviewed(c, &correction, "approved", req.Note)
+
+	// Alerts raised for the reading are resolved with the correction
+	var resolved, resolvedBefore []models.Alert
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := saveReview(tx, &correction); err != nil {
+			return err
+		}
+
+		switch correction.Kind {
+		case "void":
+			// Voided readings are soft-deleted so the original stays on record
+			if err := tx.Delete(&reading).Error; err != nil {
+				return err
+			}
+		default:
+			var proposed models.ReadingValues
+			if err := json.Unmarshal([]byte(correction.Proposed), &proposed); err != nil {
+				return err
+			}
+			applyReadingValues(&reading, proposed)
+			reading.Corrected = true
+
+			err := tx.Model(&reading).
+				Select("date", "read_at", "notes", "corrected").
+				Updates(&reading).Error
+			if err != nil {
+				return err
+			}
+
+			// Replace the changed metric values, adding any the reading did not
+			// record, with the value and unit they were entered in
+			_, rows, _, err := h.convertValues(proposedMetricValues(proposed), nil)
+			if err != nil {
+				return err
+			}
+			for i := range rows {
+				rows[i].UtilityDataID = reading.ID
+				if input, ok := proposed.Inputs[rows[i].MetricCode]; ok {
+					rows[i].InputValue = &input.Value
+					rows[i].InputUnit = input.Unit
+				}
+			}
+			if len(rows) > 0 {
+				err = tx.Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "utility_data_id"}, {Name: "metric_code"}},
+					DoUpdates: clause.AssignmentColumns([]string{"value", "unit", "input_value", "input_unit"}),
+				}).Create(&rows).Error
+				if err != nil {
+					return err
+				}
+			}
+			// Reload into a fresh slice so the audit snapshot keeps the old values
+			reading.Values = nil
+			if err := tx.Where("utility_data_id = ?", reading.ID).Find(&reading.Values).Error; err != nil {
+				return err
+			}
+		}
+
+		// The reading's open alerts describe values it no longer has, so
+		// they are resolved; an edited reading is scored again afterwards
+		alerts := repository.NewAlerts(tx)
+		open, err := alerts.OpenForReading(reading.ID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, alert := range open {
+			resolvedBefore = append(resolvedBefore, alert)
+			if err := alerts.Resolve(&alert, now); err != nil {
+				return err
+			}
+			resolved = append(resolved, alert)
+		}
+
+		// Roll up the reading's date again, and its new date if it moved
+		rollups := repository.NewRollups(tx)
+		if err := rollups.Refresh(reading.ClientID, readingBefore.Date, readingBefore.Date); err != nil {
+			return err
+		}
+		if !reading.Date.Equal(readingBefore.Date.Time) {
+			return rollups.Refresh(reading.ClientID, reading.Date, reading.Date)
+		}
+		return nil
+	})
+	if errors.Is(err, errAlreadyReviewed) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": errAlreadyReviewed.Message,
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to apply correction: " + err.Error(),
+		})
+	}
+
+	audit.Record(c, "correction.approve", "reading_correction", correction.ID, before, correction)
+	if correction.Kind == "void" {
+		audit.Record(c, "reading.void", "utility_data", reading.ID, readingBefore, nil)
+	} else {
+		audit.Record(c, "reading.update", "utility_data", reading.ID, readingBefore, reading)
+	}
+	for i, alert := range resolved {
+		audit.Record(c, "alert.resolve", "alert", alert.ID, resolvedBefore[i], alert)
+		h.webhooks.Publish(h.db, webhooks.EventAlertResolved, alert)
+	}
+
+	// Edited values are scored again once their old alerts are resolved, on
+	// a copy that leaves the values readingBefore may share as they were
+	if correction.Kind != "void" {
+		scored := reading
+		scored.Values = append([]models.ReadingValue(nil), reading.Values...)
+		if alert := anomaly.Check(h.db, h.webhooks, &scored); alert != nil {
+			audit.Record(c, "alert.open", "alert", alert.ID, nil, alert)
+		}
+	}
+	h.invalidate(reading.ClientID)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Correction approved",
+		"data":    correction,
+	})
+}
+
+// RejectCorrection closes a pending correction without changing the reading
+func (h *Handler) RejectCorrection(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	correction, req, err := h.loadPendingCorrection(c)
+	if err != nil {
+		e := err.(*fiber.Error)
+		return c.Status(e.Code).JSON(fiber.Map{
+			"success": false,
+			"message": e.Message,
+		})
+	}
+	before := correction
+
+	markReviewed(c, &correction, "rejected", req.Note)
+
+	err = saveReview(h.db, &correction)
+	if errors.Is(err, errAlreadyReviewed) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": errAlreadyReviewed.Message,
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to reject correction: " + err.Error(),
+		})
+	}
+
+	if reading, err := h.readings.Find(correction.UtilityDataID); err == nil {
+		h.invalidate(reading.ClientID)
+	}
+	audit.Record(c, "correction.reject", "reading_correction", correction.ID, before, correction)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Correction rejected",
+		"data":    correction,
+	})
+}
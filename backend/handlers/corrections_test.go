@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"utility-backend/models"
+	"utility-backend/repository"
+)
+
+// correctionFixture is a site with a reading that has an open alert
+type correctionFixture struct {
+	api     *testAPI
+	site    models.Client
+	reading models.UtilityData
+	alert   models.Alert
+}
+
+func newCorrectionFixture(t *testing.T) correctionFixture {
+	t.Helper()
+	api := newTestAPI(t)
+	f := correctionFixture{api: api, site: api.createSite(t)}
+
+	day := bangkokDay(t, -2)
+	f.reading = models.UtilityData{
+		ClientID: f.site.ID,
+		Date:     day,
+		ReadAt:   day.Time.Add(2 * time.Hour),
+		Timezone: "Asia/Bangkok",
+		Values: []models.ReadingValue{
+			{MetricCode: "water", Value: 900, Unit: "m³"},
+			{MetricCode: "pac", Value: 4, Unit: "kg"},
+		},
+	}
+	if err := repository.NewReadings(api.db).Create(&f.reading); err != nil {
+		t.Fatal(err)
+	}
+	f.alert = models.Alert{ClientID: f.site.ID, Type: "warning", Title: "High Water Usage", Status: repository.AlertOpen, UtilityDataID: &f.reading.ID}
+	if err := api.db.Create(&f.alert).Error; err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// request asks for a correction of the reading as the operator and returns its ID
+func (f correctionFixture) request(t *testing.T, method, path string, body interface{}) uint {
+	t.Helper()
+	status, resp := f.api.do(t, "operator", method, path, body)
+	if status != http.StatusAccepted {
+		t.Fatalf("requesting a correction: status = %d (%s), want 202", status, resp.Message)
+	}
+	var correction struct{ ID uint }
+	if err := json.Unmarshal(resp.Data, &correction); err != nil {
+		t.Fatal(err)
+	}
+	return correction.ID
+}
+
+// review approves or rejects a correction as the admin
+func (f correctionFixture) review(t *testing.T, id uint, verdict string) int {
+	t.Helper()
+	status, _ := f.api.do(t, "admin", http.MethodPost, fmt.Sprintf("/corrections/%d/%s", id, verdict), nil)
+	return status
+}
+
+// dayTotal returns the rolled up total of a metric on the reading's date
+func (f correctionFixture) dayTotal(t *testing.T, metric string) (float64, bool) {
+	t.Helper()
+	var rollups []models.DailyRollup
+	err := f.api.db.Where("client_id = ? AND date = ? AND metric_code = ?", f.site.ID, f.reading.Date, metric).
+		Find(&rollups).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rollups) == 0 {
+		return 0, false
+	}
+	return rollups[0].Total, true
+}
+
+// alertStatus returns the status of the fixture's alert
+func (f correctionFixture) alertStatus(t *testing.T) string {
+	t.Helper()
+	alert, err := repository.NewAlerts(f.api.db).Find(f.alert.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alert.Status
+}
+
+func TestApproveEditCorrection(t *testing.T) {
+	f := newCorrectionFixture(t)
+	path := fmt.Sprintf("/utility-data/%d", f.reading.ID)
+
+	id := f.request(t, http.MethodPut, path, map[string]interface{}{
+		"values": map[string]float64{"water": 210000},
+		"units":  map[string]string{"water": "L"},
+		"reason": "entered in litres",
+	})
+
+	// Only one correction per reading awaits review at a time
+	status, _ := f.api.do(t, "operator", http.MethodPut, path, map[string]interface{}{"waterMeter": 1, "reason": "again"})
+	if status != http.StatusConflict {
+		t.Errorf("second pending correction: status = %d, want 409", status)
+	}
+
+	// Nothing changes until the correction is approved
+	if total, _ := f.dayTotal(t, "water"); total != 900 {
+		t.Fatalf("water total before approval = %v, want 900", total)
+	}
+
+	if status := f.review(t, id, "approve"); status != http.StatusOK {
+		t.Fatalf("approve: status = %d, want 200", status)
+	}
+	if status := f.review(t, id, "approve"); status != http.StatusConflict {
+		t.Errorf("approving again: status = %d, want 409", status)
+	}
+	if status := f.review(t, id, "reject"); status != http.StatusConflict {
+		t.Errorf("rejecting after approval: status = %d, want 409", status)
+	}
+
+	reading, err := repository.NewReadings(f.api.db).Find(f.reading.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reading.Corrected {
+		t.Error("the reading is not flagged as corrected")
+	}
+	for _, v := range reading.Values {
+		switch v.MetricCode {
+		case "water":
+			if v.Value != 210 || v.InputValue == nil || *v.InputValue != 210000 || v.InputUnit != "L" {
+				t.Errorf("water = %v entered as %v %s, want 210 m³ entered as 210000 L", v.Value, v.InputValue, v.InputUnit)
+			}
+		case "pac":
+			if v.Value != 4 {
+				t.Errorf("pac = %v, want it left at 4", v.Value)
+			}
+		}
+	}
+	if total, _ := f.dayTotal(t, "water"); total != 210 {
+		t.Errorf("water total after approval = %v, want 210", total)
+	}
+
+	// The alert raised for the old value is resolved
+	if status := f.alertStatus(t); status != repository.AlertResolved {
+		t.Errorf("alert status = %s, want resolved", status)
+	}
+	actions := f.api.auditActions(t)
+	for _, action := range []string{"correction.request", "correction.approve", "reading.update", "alert.resolve"} {
+		if !hasAction(actions, action) {
+			t.Errorf("audited %v, want %s", actions, action)
+		}
+	}
+}
+
+func TestApproveVoidCorrection(t *testing.T) {
+	f := newCorrectionFixture(t)
+	path := fmt.Sprintf("/utility-data/%d/void", f.reading.ID)
+
+	// A void needs a reason
+	if status, _ := f.api.do(t, "operator", http.MethodPost, path, map[string]string{}); status != http.StatusBadRequest {
+		t.Errorf("void without reason: status = %d, want 400", status)
+	}
+
+	// A rejected request leaves the reading as it was and can be asked again
+	id := f.request(t, http.MethodPost, path, map[string]string{"reason": "duplicate"})
+	if status := f.review(t, id, "reject"); status != http.StatusOK {
+		t.Fatalf("reject: status = %d, want 200", status)
+	}
+	if status := f.review(t, id, "approve"); status != http.StatusConflict {
+		t.Errorf("approving a rejected correction: status = %d, want 409", status)
+	}
+	if _, ok := f.dayTotal(t, "water"); !ok {
+		t.Fatal("the rejected void removed the reading's rollup")
+	}
+
+	id = f.request(t, http.MethodPost, path, map[string]string{"reason": "duplicate"})
+	if status := f.review(t, id, "approve"); status != http.StatusOK {
+		t.Fatalf("approve: status = %d, want 200", status)
+	}
+
+	// The reading is kept but voided, and drops out of the rollups
+	if _, err := repository.NewReadings(f.api.db).Find(f.reading.ID); err == nil {
+		t.Error("the voided reading is still found")
+	}
+	var kept models.UtilityData
+	if err := f.api.db.Unscoped().First(&kept, f.reading.ID).Error; err != nil || !kept.DeletedAt.Valid {
+		t.Errorf("voided reading = %+v (%v), want it soft-deleted", kept, err)
+	}
+	for _, metric := range []string{"water", "pac"} {
+		if total, ok := f.dayTotal(t, metric); ok {
+			t.Errorf("%s is still rolled up at %v", metric, total)
+		}
+	}
+
+	if status := f.alertStatus(t); status != repository.AlertResolved {
+		t.Errorf("alert status = %s, want resolved", status)
+	}
+	actions := f.api.auditActions(t)
+	for _, action := range []string{"correction.reject", "correction.approve", "reading.void", "alert.resolve"} {
+		if !hasAction(actions, action) {
+			t.Errorf("audited %v, want %s", actions, action)
+		}
+	}
+}
@@ -1,34 +1,46 @@
 package handlers
 
 import (
-	"fmt"
 	"math/rand"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 
-	"utility-backend/database"
+	"utility-backend/metrics"
 	"utility-backend/models"
+	"utility-backend/repository"
+	"utility-backend/validation"
 )
 
-// GetDashboardData returns summarized utility data for the dashboard
-func GetDashboardData(c *fiber.Ctx) error {
-	// Get total water usage
-	var totalWaterUsage float64
-	database.DB.Model(&models.UtilityData{}).Select("SUM(water_usage)").Scan(&totalWaterUsage)
-
-	// Get total chemical usage
-	var totalPacUsage, totalPolymerUsage, totalChlorineUsage float64
-	database.DB.Model(&models.UtilityData{}).Select("SUM(pac_usage)").Scan(&totalPacUsage)
-	database.DB.Model(&models.UtilityData{}).Select("SUM(polymer_usage)").Scan(&totalPolymerUsage)
-	database.DB.Model(&models.UtilityData{}).Select("SUM(chlorine_usage)").Scan(&totalChlorineUsage)
+// dashboardAlerts is how many of the latest open alerts the dashboard shows
+const dashboardAlerts = 5
 
-	// Get data for the last 30 days
-	var utilityData []models.UtilityData
-	database.DB.Order("date desc").Limit(30).Find(&utilityData)
+// GetDashboardData returns summarized utility data for the dashboard
+func (h *Handler) GetDashboardData(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	// Get the total of every metric over all readings, from the monthly rollups
+	totals, err := metrics.Totals(h.db, 0)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load totals: " + err.Error(),
+		})
+	}
+	totalWaterUsage := totals["water"]
+	totalPacUsage, totalPolymerUsage, totalChlorineUsage := totals["pac"], totals["polymer"], totals["chlorine"]
+
+	// Get daily totals for the last 30 days with readings, oldest first
+	days, err := metrics.DailyRollup(h.db, 0, 30)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load readings: " + err.Error(),
+		})
+	}
 
 	// If no data is found, use mock data for demo
-	if len(utilityData) == 0 {
+	if len(days) == 0 {
 		return c.Status(fiber.StatusOK).JSON(getMockDashboardData())
 	}
 
@@ -39,39 +51,25 @@ func GetDashboardData(c *fiber.Ctx) error {
 	dashboardData.Summary.TotalWaterUsage = totalWaterUsage
 	dashboardData.Summary.WaterChange = 3.5 // Mock value - would calculate from previous period in real app
 	dashboardData.Summary.TotalPacUsage = totalPacUsage
-	dashboardData.Summary.AvgPacUsage = totalPacUsage / float64(len(utilityData))
 	dashboardData.Summary.TotalPolymerUsage = totalPolymerUsage
-	dashboardData.Summary.AvgPolymerUsage = totalPolymerUsage / float64(len(utilityData))
 	dashboardData.Summary.TotalChlorineUsage = totalChlorineUsage
-	dashboardData.Summary.AvgChlorineUsage = totalChlorineUsage / float64(len(utilityData))
 
-	// Prepare chart data - need to reverse as we want date ascending
-	// Sort utilityData by date (ascending)
-	length := len(utilityData)
+	// Prepare chart data, one point per day
+	length := len(days)
 	labels := make([]string, length)
 	waterData := make([]float64, length)
 	pacData := make([]float64, length)
 	polymerData := make([]float64, length)
 	chlorineData := make([]float64, length)
 
-	for i, data := range utilityData {
-		// Reverse index to get ascending order by date
-		j := length - i - 1
-		
-		// Parse date string to time
-		t, err := time.Parse("2006-01-02", data.Date)
-		if err != nil {
-			// If date parsing fails, use index
-			labels[j] = fmt.Sprintf("Day %d", j+1)
-		} else {
-			// Format date as MMM D
-			labels[j] = t.Format("Jan 2")
-		}
-		
-		waterData[j] = data.WaterUsage
-		pacData[j] = data.PacUsage
-		polymerData[j] = data.PolymerUsage
-		chlorineData[j] = data.ChlorineUsage
+	for j, day := range days {
+		// Format date as MMM D
+		labels[j] = day.Date.Format("Jan 2")
+
+		waterData[j] = day.Value("water")
+		pacData[j] = day.Value("pac")
+		polymerData[j] = day.Value("polymer")
+		chlorineData[j] = day.Value("chlorine")
 	}
 
 	dashboardData.WaterUsage.Labels = labels
@@ -82,31 +80,55 @@ func GetDashboardData(c *fiber.Ctx) error {
 	dashboardData.ChemicalUsage.Polymer = polymerData
 	dashboardData.ChemicalUsage.Chlorine = chlorineData
 
-	// Add mock alerts for demo
-	dashboardData.Alerts = []struct {
-		Type    string `json:"type"`
-		Title   string `json:"title"`
-		Message string `json:"message"`
-		Date    string `json:"date"`
-	}{
-		{
-			Type:    "danger",
-			Title:   "High Water Usage",
-			Message: "Zone B water usage exceeds normal by 25%. Please investigate.",
-			Date:    time.Now().Format("2006-01-02"),
-		},
-		{
-			Type:    "warning",
-			Title:   "PAC Supply Low",
-			Message: "PAC inventory is below the minimum threshold. Please reorder.",
-			Date:    time.Now().Format("2006-01-02"),
-		},
-		{
-			Type:    "info",
-			Title:   "Maintenance Schedule",
-			Message: "Routine equipment maintenance scheduled for next Monday.",
-			Date:    time.Now().Format("2006-01-02"),
-		},
+	// Catalog-driven series
+	catalog, err := metrics.Catalog(h.db)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load metric catalog: " + err.Error(),
+		})
+	}
+	display, err := displayUnits(c, catalog)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	// Monthly figures are per calendar month in the default site timezone
+	today := models.DateOf(time.Now().In(validation.Location("")))
+	monthly, err := metrics.Monthly(h.db, 0, today)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load monthly totals: " + err.Error(),
+		})
+	}
+	dashboardData.Summary.AvgPacUsage = monthly["pac"].Average
+	dashboardData.Summary.AvgPolymerUsage = monthly["polymer"].Average
+	dashboardData.Summary.AvgChlorineUsage = monthly["chlorine"].Average
+	dashboardData.Metrics = metricSeries(catalog, days, labels, totals, monthly, display)
+
+	// The latest open alerts, such as those raised for unusual readings
+	alerts, err := h.alerts.List(0, repository.AlertOpen)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load alerts: " + err.Error(),
+		})
+	}
+	if len(alerts) > dashboardAlerts {
+		alerts = alerts[:dashboardAlerts]
+	}
+	dashboardData.Alerts = []models.DashboardAlert{}
+	for _, alert := range alerts {
+		dashboardData.Alerts = append(dashboardData.Alerts, models.DashboardAlert{
+			Type:    alert.Type,
+			Title:   alert.Title,
+			Message: alert.Message,
+			Date:    alert.CreatedAt.In(validation.Location("")).Format("2006-01-02"),
+		})
 	}
 
 	return c.Status(fiber.StatusOK).JSON(dashboardData)
@@ -138,7 +160,7 @@ func getMockDashboardData() models.DashboardData {
 	for i := 0; i < 30; i++ {
 		date := now.AddDate(0, 0, i-29)
 		labels[i] = date.Format("Jan 2")
-		
+
 		// Generate random data with some patterns
 		waterData[i] = 200 + rand.Float64()*100
 		pacData[i] = 5 + rand.Float64()*10
@@ -154,32 +176,8 @@ func getMockDashboardData() models.DashboardData {
 	dashboardData.ChemicalUsage.Polymer = polymerData
 	dashboardData.ChemicalUsage.Chlorine = chlorineData
 
-	// Add mock alerts
-	dashboardData.Alerts = []struct {
-		Type    string `json:"type"`
-		Title   string `json:"title"`
-		Message string `json:"message"`
-		Date    string `json:"date"`
-	}{
-		{
-			Type:    "danger",
-			Title:   "High Water Usage",
-			Message: "Zone B water usage exceeds normal by 25%. Please investigate.",
-			Date:    now.Format("2006-01-02"),
-		},
-		{
-			Type:    "warning",
-			Title:   "PAC Supply Low",
-			Message: "PAC inventory is below the minimum threshold. Please reorder.",
-			Date:    now.Format("2006-01-02"),
-		},
-		{
-			Type:    "info",
-			Title:   "Maintenance Schedule",
-			Message: "Routine equipment maintenance scheduled for next Monday.",
-			Date:    now.Format("2006-01-02"),
-		},
-	}
+	// Alerts are never mocked, only raised for real readings
+	dashboardData.Alerts = []models.DashboardAlert{}
 
 	return dashboardData
-} 
\ No newline at end of file
+}
@@ -1,25 +1,90 @@
 package handlers
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 
-	"utility-backend/database"
+	"utility-backend/anomaly"
+	"utility-backend/audit"
+	"utility-backend/metrics"
 	"utility-backend/models"
+	"utility-backend/units"
+	"utility-backend/validation"
+	"utility-backend/webhooks"
 )
 
 // SubmitDataRequest represents the structure of the submit data request
 type SubmitDataRequest struct {
-	SiteID       uint    `json:"siteId"`
-	Date         string  `json:"date"`
-	WaterMeter   float64 `json:"waterMeter"`
-	Pac          float64 `json:"pac"`
-	Polymer      float64 `json:"polymer"`
-	Chlorine     float64 `json:"chlorine"`
-	Notes        string  `json:"notes"`
+	SiteID     uint               `json:"siteId"`
+	Date       string             `json:"date"`
+	ReadAt     string             `json:"readAt"` // RFC 3339 time the reading was taken; a date alone means the start of that day
+	WaterMeter *float64           `json:"waterMeter"`
+	Pac        *float64           `json:"pac"`
+	Polymer    *float64           `json:"polymer"`
+	Chlorine   *float64           `json:"chlorine"`
+	Values     map[string]float64 `json:"values"` // any catalog metric, keyed by code
+	Units      map[string]string  `json:"units"`  // unit of each value keyed by metric code, if not the catalog unit
+	Notes      string             `json:"notes"`
+	Confirm    bool               `json:"confirm"` // save even if values look unusual for the site
+}
+
+// legacyValues merges the original per-metric request fields into values keyed by metric code
+func legacyValues(values map[string]float64, water, pac, polymer, chlorine *float64) map[string]float64 {
+	merged := map[string]float64{}
+	for code, v := range map[string]*float64{"water": water, "pac": pac, "polymer": polymer, "chlorine": chlorine} {
+		if v != nil {
+			merged[code] = *v
+		}
+	}
+	for code, v := range values {
+		merged[metrics.NormalizeCode(code)] = v
+	}
+	return merged
+}
+
+// readingTime works out when a reading was taken and its local date at the
+// site from the submitted readAt and date. A date alone means the start of that day.
+func readingTime(readAt, date string, loc *time.Location, now time.Time) (time.Time, models.Date, []validation.FieldError) {
+	if readAt == "" {
+		d, errs := validation.ValidateDate("date", date, now, loc)
+		if len(errs) > 0 {
+			return time.Time{}, d, errs
+		}
+		return d.In(loc), d, nil
+	}
+
+	t, errs := validation.ValidateReadAt("readAt", readAt, now)
+	if len(errs) > 0 {
+		return t, models.Date{}, errs
+	}
+	local := models.DateOf(t.In(loc))
+	if date != "" && date != local.String() {
+		return t, local, []validation.FieldError{{
+			Field:   "date",
+			Code:    validation.CodeInvalidDate,
+			Message: fmt.Sprintf("Date does not match readAt, which is %s at the site", local),
+		}}
+	}
+	return t, local, nil
+}
+
+// validationFailed responds with field-level errors the frontend can map to form inputs
+func validationFailed(c *fiber.Ctx, errs []validation.FieldError) error {
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+		"success": false,
+		"message": "Validation failed",
+		"errors":  errs,
+	})
 }
 
 // SubmitData handles the submission of utility data
-func SubmitData(c *fiber.Ctx) error {
+func (h *Handler) SubmitData(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
 	// Check if the user is authorized (operator or admin)
 	role := c.Locals("role")
 	if role != "operator" && role != "admin" {
@@ -40,53 +105,193 @@ func SubmitData(c *fiber.Ctx) error {
 
 	// Validate input
 	if req.SiteID == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		return validationFailed(c, []validation.FieldError{{
+			Field:   "siteId",
+			Code:    validation.CodeRequired,
+			Message: "Site ID is required",
+		}})
+	}
+
+	// Check if the client exists
+	client, err := h.clients.Find(req.SiteID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
-			"message": "Site ID is required",
+			"message": "Site not found",
 		})
 	}
 
-	if req.Date == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+	loc := validation.Location(client.Timezone)
+	readAt, date, errs := readingTime(req.ReadAt, req.Date, loc, time.Now())
+	values := legacyValues(req.Values, req.WaterMeter, req.Pac, req.Polymer, req.Chlorine)
+	if len(values) == 0 {
+		errs = append(errs, validation.FieldError{
+			Field:   "values",
+			Code:    validation.CodeRequired,
+			Message: "At least one metric value is required",
+		})
+	}
+	values, rows, unitErrs, err := h.convertValues(values, req.Units)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
-			"message": "Date is required",
+			"message": "Failed to load metric catalog: " + err.Error(),
 		})
 	}
+	errs = append(errs, unitErrs...)
+	valueErrs, err := validation.ValidateValues(h.db, client.ID, values)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load validation rules: " + err.Error(),
+		})
+	}
+	if errs = append(errs, valueErrs...); len(errs) > 0 {
+		return validationFailed(c, errs)
+	}
 
-	// Check if the client exists
-	var client models.Client
-	result := database.DB.First(&client, req.SiteID)
-	if result.Error != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+	// Unusual values must be confirmed by the operator before they are saved
+	warnings, err := validation.CheckPlausibility(h.db, client.ID, date, values)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
-			"message": "Site not found",
+			"message": "Failed to check reading history: " + err.Error(),
+		})
+	}
+	if len(warnings) > 0 && !req.Confirm {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success":           false,
+			"message":           "Some values are unusual for this site. Resubmit with confirm set to save them.",
+			"needsConfirmation": true,
+			"warnings":          warnings,
 		})
 	}
 
 	// Create utility data record
 	utilityData := models.UtilityData{
-		ClientID:      req.SiteID,
-		Date:          req.Date,
-		WaterUsage:    req.WaterMeter,
-		PacUsage:      req.Pac,
-		PolymerUsage:  req.Polymer,
-		ChlorineUsage: req.Chlorine,
-		Notes:         req.Notes,
-	}
-
-	// Save to database
-	result = database.DB.Create(&utilityData)
-	if result.Error != nil {
+		ClientID: req.SiteID,
+		Date:     date,
+		ReadAt:   readAt.UTC(),
+		Timezone: loc.String(),
+		Notes:    req.Notes,
+		Values:   rows,
+	}
+	if userID, ok := c.Locals("userID").(uint); ok && userID != 0 {
+		utilityData.RecordedBy = &userID
+	}
+	if len(warnings) > 0 {
+		fields := make([]string, len(warnings))
+		for i, w := range warnings {
+			fields[i] = w.Field
+		}
+		utilityData.Confirmed = true
+		utilityData.SuspiciousFields = strings.Join(fields, ",")
+	}
+
+	// Save to database, together with the values
+	if err := h.readings.Create(&utilityData); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
-			"message": "Failed to save data: " + result.Error.Error(),
+			"message": "Failed to save data: " + err.Error(),
 		})
 	}
 
+	// Score the reading against the site's baselines, raising an alert if it is unusual
+	alert := anomaly.Check(h.db, h.webhooks, &utilityData)
+
+	h.monitor.ReadingsIngested(1)
+	h.invalidate(utilityData.ClientID)
+	audit.Record(c, "reading.create", "utility_data", utilityData.ID, nil, utilityData)
+	if alert != nil {
+		audit.Record(c, "alert.open", "alert", alert.ID, nil, alert)
+	}
+	h.webhooks.Publish(h.db, webhooks.EventReadingCreated, utilityData)
+
 	// Return success response
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"success": true,
 		"message": "Data submitted successfully",
-		"data": utilityData,
+		"data":    utilityData,
+	})
+}
+
+// ListFlaggedReadings returns readings that were saved despite plausibility warnings, newest first
+func (h *Handler) ListFlaggedReadings(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	var clientID uint
+	if id := c.QueryInt("clientId"); id > 0 {
+		clientID = uint(id)
+	}
+
+	readings, err := h.readings.Flagged(clientID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load readings: " + err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    readings,
 	})
-} 
\ No newline at end of file
+}
+
+// convertValues converts submitted values into each metric's catalog unit and
+// builds the reading value rows, keeping what was entered when the unit differs.
+// Values of unknown metrics are passed through for validation to reject.
+func (h *Handler) convertValues(values map[string]float64, inputUnits map[string]string) (map[string]float64, []models.ReadingValue, []validation.FieldError, error) {
+	catalog, err := metrics.All(h.db)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	byCode := map[string]models.Metric{}
+	for _, m := range catalog {
+		byCode[m.Code] = m
+	}
+	unitOf := map[string]string{}
+	for code, unit := range inputUnits {
+		unitOf[metrics.NormalizeCode(code)] = unit
+	}
+
+	codes := make([]string, 0, len(values))
+	for code := range values {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	converted := map[string]float64{}
+	var rows []models.ReadingValue
+	var errs []validation.FieldError
+	for _, code := range codes {
+		value := values[code]
+		metric, known := byCode[code]
+		row := models.ReadingValue{MetricCode: code, Value: value, Unit: metric.Unit}
+
+		if unit := unitOf[code]; known && unit != "" {
+			u, err := units.Lookup(unit)
+			if err == nil {
+				row.Value, err = metrics.ToCatalogUnit(metric, value, u.Symbol)
+			}
+			if err != nil {
+				errs = append(errs, validation.FieldError{
+					Field:   "units." + code,
+					Code:    validation.CodeInvalidUnit,
+					Message: fmt.Sprintf("%s is measured in %s and cannot be submitted in %q", metric.Name, metric.Unit, unit),
+				})
+				continue
+			}
+			if u.Symbol != metric.Unit {
+				input := value
+				row.InputValue = &input
+				row.InputUnit = u.Symbol
+			}
+		}
+
+		converted[code] = row.Value
+		rows = append(rows, row)
+	}
+
+	return converted, rows, errs, nil
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"utility-backend/models"
+	"utility-backend/repository"
+	"utility-backend/validation"
+)
+
+// bangkokDay returns the local date in Bangkok days days from today
+func bangkokDay(t *testing.T, days int) models.Date {
+	t.Helper()
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return models.DateOf(time.Now().In(loc)).AddDays(days)
+}
+
+func TestSubmitData(t *testing.T) {
+	api := newTestAPI(t)
+	site := api.createSite(t)
+	day := bangkokDay(t, -1)
+
+	tests := []struct {
+		name   string
+		role   string
+		body   map[string]interface{}
+		status int
+		field  string // of the validation error expected
+	}{
+		{"viewer", "viewer", map[string]interface{}{"siteId": site.ID, "date": day.String(), "waterMeter": 10}, http.StatusForbidden, ""},
+		{"no site", "operator", map[string]interface{}{"date": day.String(), "waterMeter": 10}, http.StatusUnprocessableEntity, "siteId"},
+		{"unknown site", "operator", map[string]interface{}{"siteId": site.ID + 1, "date": day.String(), "waterMeter": 10}, http.StatusNotFound, ""},
+		{"no values", "operator", map[string]interface{}{"siteId": site.ID, "date": day.String()}, http.StatusUnprocessableEntity, "values"},
+		{"future date", "operator", map[string]interface{}{"siteId": site.ID, "date": day.AddDays(3).String(), "waterMeter": 10}, http.StatusUnprocessableEntity, "date"},
+		{"wrong unit", "operator", map[string]interface{}{"siteId": site.ID, "date": day.String(), "waterMeter": 10, "units": map[string]string{"water": "kg"}}, http.StatusUnprocessableEntity, "units.water"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := api.do(t, tt.role, http.MethodPost, "/submit-data", tt.body)
+			if status != tt.status {
+				t.Fatalf("status = %d (%s), want %d", status, resp.Message, tt.status)
+			}
+			if tt.field != "" && (len(resp.Errors) == 0 || resp.Errors[0].Field != tt.field) {
+				t.Errorf("errors = %+v, want one for %s", resp.Errors, tt.field)
+			}
+		})
+	}
+	var count int64
+	api.db.Model(&models.UtilityData{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("%d readings were saved by rejected submissions", count)
+	}
+
+	// A valid submission is saved with its values in catalog units and rolled up
+	status, resp := api.do(t, "operator", http.MethodPost, "/submit-data", map[string]interface{}{
+		"siteId": site.ID,
+		"readAt": day.String() + "T09:30:00+07:00",
+		"values": map[string]float64{"water": 120.5, "pac": 2500},
+		"units":  map[string]string{"pac": "g"},
+		"notes":  "morning round",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("status = %d (%s), want 201", status, resp.Message)
+	}
+
+	var readings []models.UtilityData
+	if err := api.db.Preload("Values").Find(&readings).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(readings) != 1 {
+		t.Fatalf("%d readings saved, want 1", len(readings))
+	}
+	reading := readings[0]
+	if reading.Date != day || reading.Notes != "morning round" || reading.Confirmed {
+		t.Errorf("reading = date %s, notes %q, confirmed %v", reading.Date, reading.Notes, reading.Confirmed)
+	}
+	if reading.RecordedBy == nil || *reading.RecordedBy != api.users["operator"].ID {
+		t.Errorf("recorded by %v, want the operator", reading.RecordedBy)
+	}
+	if v, _ := reading.Lookup("water"); v != 120.5 {
+		t.Errorf("water = %v, want 120.5", v)
+	}
+	for _, v := range reading.Values {
+		if v.MetricCode == "pac" && (v.Value != 2.5 || v.Unit != "kg" || v.InputValue == nil || *v.InputValue != 2500 || v.InputUnit != "g") {
+			t.Errorf("pac = %v %s entered as %v %s, want 2.5 kg entered as 2500 g", v.Value, v.Unit, v.InputValue, v.InputUnit)
+		}
+	}
+
+	var rollup models.DailyRollup
+	err := api.db.Where("client_id = ? AND date = ? AND metric_code = ?", site.ID, day, "pac").First(&rollup).Error
+	if err != nil || rollup.Total != 2.5 || rollup.Readings != 1 {
+		t.Errorf("pac rollup = %+v (%v), want 2.5 kg from 1 reading", rollup, err)
+	}
+	if actions := api.auditActions(t); !hasAction(actions, "reading.create") {
+		t.Errorf("audited %v, want reading.create", actions)
+	}
+}
+
+func TestSubmitDataConfirmsUnusualValues(t *testing.T) {
+	api := newTestAPI(t)
+	site := api.createSite(t)
+	day := bangkokDay(t, -1)
+
+	// A steady history of around 100 m³ a day
+	readings := repository.NewReadings(api.db)
+	for i := 1; i <= validation.MinHistory+3; i++ {
+		date := day.AddDays(-i)
+		reading := models.UtilityData{
+			ClientID: site.ID,
+			Date:     date,
+			ReadAt:   date.Time.Add(2 * time.Hour),
+			Timezone: "Asia/Bangkok",
+			Values:   []models.ReadingValue{{MetricCode: "water", Value: float64(95 + i%3*5), Unit: "m³"}},
+		}
+		if err := readings.Create(&reading); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := validation.MinHistory + 3
+
+	submit := func(water float64, confirm bool) (int, response) {
+		return api.do(t, "operator", http.MethodPost, "/submit-data", map[string]interface{}{
+			"siteId":     site.ID,
+			"date":       day.String(),
+			"waterMeter": water,
+			"confirm":    confirm,
+		})
+	}
+	countReadings := func() int {
+		var count int64
+		if err := api.db.Model(&models.UtilityData{}).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		return int(count)
+	}
+
+	// A usual value is saved straight away
+	if status, resp := submit(101, false); status != http.StatusCreated {
+		t.Fatalf("usual value: status = %d (%s), want 201", status, resp.Message)
+	}
+	before++
+
+	// An unusual one needs confirming and is not saved until it is
+	status, resp := submit(900, false)
+	if status != http.StatusConflict || !resp.NeedsConfirmation {
+		t.Fatalf("unusual value: status = %d, needsConfirmation %v, want 409 asking for confirmation", status, resp.NeedsConfirmation)
+	}
+	if len(resp.Warnings) != 1 || resp.Warnings[0].Field != "waterMeter" || resp.Warnings[0].Code != validation.CodeOutlier {
+		t.Errorf("warnings = %+v, want an outlier warning for waterMeter", resp.Warnings)
+	}
+	if got := countReadings(); got != before {
+		t.Fatalf("%d readings after the unconfirmed submission, want %d", got, before)
+	}
+
+	// Confirmed, it is saved and flagged
+	status, resp = submit(900, true)
+	if status != http.StatusCreated {
+		t.Fatalf("confirmed: status = %d (%s), want 201", status, resp.Message)
+	}
+	if got := countReadings(); got != before+1 {
+		t.Fatalf("%d readings after confirming, want %d", got, before+1)
+	}
+	flagged, err := readings.Flagged(site.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flagged) != 1 || flagged[0].SuspiciousFields != "waterMeter" || flagged[0].Value("water") != 900 {
+		t.Errorf("flagged readings = %+v, want the confirmed one with waterMeter flagged", flagged)
+	}
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"net/mail"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"utility-backend/audit"
+	"utility-backend/models"
+	"utility-backend/notifications"
+	"utility-backend/validation"
+)
+
+// DigestSubscriptionRequest represents the body for creating a digest subscription
+type DigestSubscriptionRequest struct {
+	ClientID uint   `json:"clientId"`
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	SendHour *int   `json:"sendHour"`
+	Timezone string `json:"timezone"`
+}
+
+// ListDigestSubscriptions returns all daily digest subscriptions
+func (h *Handler) ListDigestSubscriptions(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	var subs []models.DigestSubscription
+	result := h.db.Order("client_id, id").Find(&subs)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load subscriptions: " + result.Error.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    subs,
+	})
+}
+
+// CreateDigestSubscription subscribes a recipient to a site's daily digest.
+// Email and name default to the client's contact details.
+func (h *Handler) CreateDigestSubscription(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	var req DigestSubscriptionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	if req.ClientID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Client ID is required",
+		})
+	}
+
+	client, err := h.clients.Find(req.ClientID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Site not found",
+		})
+	}
+
+	sub := models.DigestSubscription{
+		ClientID: client.ID,
+		Email:    req.Email,
+		Name:     req.Name,
+		SendHour: 7,
+		Timezone: req.Timezone,
+		Active:   true,
+	}
+	if sub.Email == "" {
+		sub.Email = client.Email
+	}
+	if sub.Name == "" {
+		sub.Name = client.ContactName
+	}
+	if sub.Timezone == "" {
+		sub.Timezone = "Asia/Bangkok"
+	}
+	if req.SendHour != nil {
+		sub.SendHour = *req.SendHour
+	}
+
+	// Validate input
+	if sub.Email == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Email is required when the site has no contact email",
+		})
+	}
+	addr, err := mail.ParseAddress(sub.Email)
+	if err != nil {
+		return validationFailed(c, []validation.FieldError{{
+			Field:   "email",
+			Code:    validation.CodeInvalidEmail,
+			Message: "Email is not a valid address",
+		}})
+	}
+	sub.Email = addr.Address
+	if sub.SendHour < 0 || sub.SendHour > 23 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Send hour must be between 0 and 23",
+		})
+	}
+	if _, err := time.LoadLocation(sub.Timezone); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid timezone",
+		})
+	}
+
+	token, err := notifications.NewUnsubscribeToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate unsubscribe token",
+		})
+	}
+	sub.UnsubscribeToken = token
+
+	if err := h.db.Create(&sub).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save subscription: " + err.Error(),
+		})
+	}
+
+	audit.Record(c, "digest_subscription.create", "digest_subscription", sub.ID, nil, sub)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Subscription created successfully",
+		"data":    sub,
+	})
+}
+
+// DeleteDigestSubscription removes a digest subscription
+func (h *Handler) DeleteDigestSubscription(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid subscription ID format",
+		})
+	}
+
+	var sub models.DigestSubscription
+	if err := h.db.First(&sub, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Subscription not found",
+		})
+	}
+
+	result := h.db.Delete(&sub)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete subscription: " + result.Error.Error(),
+		})
+	}
+
+	audit.Record(c, "digest_subscription.delete", "digest_subscription", sub.ID, sub, nil)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Subscription deleted successfully",
+	})
+}
+
+// SendDigestNow sends a subscription's digest immediately, regardless of its schedule
+func (h *Handler) SendDigestNow(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid subscription ID format",
+		})
+	}
+
+	var sub models.DigestSubscription
+	if err := h.db.First(&sub, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Subscription not found",
+		})
+	}
+
+	if err := h.digests.SendDigest(h.db, &sub, time.Now()); err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to send digest: " + err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Digest sent to " + sub.Email,
+	})
+}
+
+// ListEmailLog returns the most recent entries of the email send log
+func (h *Handler) ListEmailLog(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	var entries []models.EmailLog
+	result := h.db.Order("sent_at desc").Limit(limit).Find(&entries)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load email log: " + result.Error.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    entries,
+	})
+}
+
+// UnsubscribePage asks to confirm unsubscribing with the token of an email
+// link. It changes nothing, so that mail scanners and link prefetchers
+// opening the link do not unsubscribe anyone.
+func (h *Handler) UnsubscribePage(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	sub, ok := h.findUnsubscribeToken(c.Params("token"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).Type("html").
+			SendString("<p>This unsubscribe link is invalid or has expired.</p>")
+	}
+	if !sub.Active {
+		return c.Status(fiber.StatusOK).Type("html").
+			SendString("<p>You are already unsubscribed from the daily digest for this site.</p>")
+	}
+
+	site := "this site"
+	if client, err := h.clients.Find(sub.ClientID); err == nil {
+		site = client.Name
+	}
+	return c.Status(fiber.StatusOK).Type("html").SendString(fmt.Sprintf(
+		`<form method="post"><p>Stop sending the daily digest for %s to %s?</p><button type="submit">Unsubscribe</button></form>`,
+		html.EscapeString(site), html.EscapeString(sub.Email)))
+}
+
+// findUnsubscribeToken returns the digest subscription of an unsubscribe token
+func (h *Handler) findUnsubscribeToken(token string) (models.DigestSubscription, bool) {
+	var sub models.DigestSubscription
+	if token == "" || h.db.Where("unsubscribe_token = ?", token).First(&sub).Error != nil {
+		return sub, false
+	}
+	return sub, true
+}
+
+// Unsubscribe disables the digest subscription identified by the token in
+// an email link. It is posted by the confirmation page, or directly by
+// mail clients offering one-click unsubscribing (RFC 8058).
+func (h *Handler) Unsubscribe(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	sub, ok := h.findUnsubscribeToken(c.Params("token"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).Type("html").
+			SendString("<p>This unsubscribe link is invalid or has expired.</p>")
+	}
+
+	if sub.Active {
+		before := sub
+		now := time.Now()
+		sub.Active = false
+		sub.UnsubscribedAt = &now
+
+		result := h.db.Model(&sub).Select("active", "unsubscribed_at").Updates(&sub)
+		if result.Error != nil {
+			return c.Status(fiber.StatusInternalServerError).Type("html").
+				SendString("<p>We could not process your request. Please try again later.</p>")
+		}
+
+		audit.Record(c, "digest_subscription.unsubscribe", "digest_subscription", sub.ID, before, sub)
+	}
+
+	return c.Status(fiber.StatusOK).Type("html").
+		SendString("<p>You have been unsubscribed from the daily digest for this site.</p>")
+}
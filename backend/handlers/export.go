@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"utility-backend/metrics"
+	"utility-backend/models"
+	"utility-backend/readingcsv"
+)
+
+// ExportReadings returns readings as CSV with one column per active catalog
+// metric. Filters: clientId, from and to (YYYY-MM-DD local dates, inclusive);
+// units selects alternate units as on the dashboard. rollup=daily exports one
+// row per site and day, and rollup=monthly one per site and calendar month,
+// instead of one per reading.
+func (h *Handler) ExportReadings(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	clientID := c.QueryInt("clientId")
+	from, to := c.Query("from"), c.Query("to")
+	for param, value := range map[string]string{"from": from, "to": to} {
+		if value == "" {
+			continue
+		}
+		if _, err := models.ParseDate(value); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": fmt.Sprintf("Invalid %s date, use YYYY-MM-DD", param),
+			})
+		}
+	}
+
+	rollup := c.Query("rollup")
+	if rollup != "" && rollup != "daily" && rollup != "monthly" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid rollup, use daily or monthly",
+		})
+	}
+
+	catalog, err := metrics.Catalog(h.db)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load metric catalog: " + err.Error(),
+		})
+	}
+
+	display, err := displayUnits(c, catalog)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	var buf bytes.Buffer
+	err = readingcsv.Export(h.db, &buf, catalog, readingcsv.ExportOptions{
+		ClientID: uint(clientID),
+		From:     from,
+		To:       to,
+		Daily:    rollup == "daily",
+		Monthly:  rollup == "monthly",
+		Units:    display,
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to export readings: " + err.Error(),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="readings.csv"`)
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"utility-backend/cache"
+	"utility-backend/monitoring"
+	"utility-backend/notifications"
+	"utility-backend/repository"
+	"utility-backend/scheduler"
+	"utility-backend/webhooks"
+)
+
+// Handler serves the API from the database it is constructed with
+type Handler struct {
+	db        *gorm.DB
+	clients   *repository.Clients
+	readings  *repository.Readings
+	users     *repository.Users
+	alerts    *repository.Alerts
+	monitor   *monitoring.Monitor
+	jobs      *scheduler.Scheduler
+	responses cache.Store
+	digests   *notifications.Sender
+	webhooks  *webhooks.Dispatcher
+	jwtSecret []byte
+}
+
+// New returns the API handlers working on db. Login tokens are signed with
+// jwtSecret. The monitor, which may be nil, counts ingested readings and
+// reports stuck background workers. The scheduler runs the jobs listed and
+// triggered through the API. Cached responses, if responses is not nil, are
+// invalidated as the data they show changes. Digests are sent through
+// digests and events published through hooks.
+func New(db *gorm.DB, jwtSecret []byte, monitor *monitoring.Monitor, jobs *scheduler.Scheduler, responses cache.Store,
+	digests *notifications.Sender, hooks *webhooks.Dispatcher) *Handler {
+	repos := repository.New(db)
+	return &Handler{
+		db:        db,
+		clients:   repos.Clients,
+		readings:  repos.Readings,
+		users:     repos.Users,
+		alerts:    repos.Alerts,
+		monitor:   monitor,
+		jobs:      jobs,
+		responses: responses,
+		digests:   digests,
+		webhooks:  hooks,
+		jwtSecret: jwtSecret,
+	}
+}
+
+// forRequest returns a copy of h whose queries run in the request's context,
+// so that the SQL log shows the request ID
+func (h *Handler) forRequest(c *fiber.Ctx) *Handler {
+	return New(h.db.WithContext(c.UserContext()), h.jwtSecret, h.monitor, h.jobs, h.responses, h.digests, h.webhooks)
+}
+
+// invalidate drops the cached responses showing a site's data
+func (h *Handler) invalidate(clientID uint) {
+	cache.Invalidate(h.responses, clientID)
+}
+
+// invalidateAll drops every cached response, e.g. when the metric catalog
+// they all show changes
+func (h *Handler) invalidateAll() {
+	cache.Clear(h.responses)
+}
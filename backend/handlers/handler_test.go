@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"utility-backend/config"
+	"utility-backend/database"
+	"utility-backend/middlewares"
+	"utility-backend/models"
+	"utility-backend/notifications"
+	"utility-backend/webhooks"
+)
+
+// openTestDB returns a migrated in-memory SQLite database of the test's own
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	cfg, err := config.Load("", map[string]string{
+		"DB_TYPE": "sqlite",
+		"DB_PATH": "file:" + url.PathEscape(t.Name()) + "?mode=memory&cache=shared",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// testAPI serves the reading and correction routes on a test database. Each
+// request is made as the user of the role it names, as if signed in.
+type testAPI struct {
+	app   *fiber.App
+	db    *gorm.DB
+	users map[string]models.User
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	db := openTestDB(t)
+	api := &testAPI{app: fiber.New(), db: db, users: map[string]models.User{}}
+	for _, role := range []string{"admin", "operator", "viewer"} {
+		user := models.User{Username: role, Password: "x", Role: role}
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		api.users[role] = user
+	}
+
+	api.app.Use(func(c *fiber.Ctx) error {
+		user := api.users[c.Get("X-Test-Role")]
+		c.Locals("userID", user.ID)
+		c.Locals("username", user.Username)
+		c.Locals("role", user.Role)
+		return c.Next()
+	})
+	api.app.Use(middlewares.Audit(db))
+
+	h := New(db, []byte("secret"), nil, nil, nil, notifications.NewSender(config.SMTP{}, ""), webhooks.NewDispatcher(db))
+	api.app.Post("/submit-data", h.SubmitData)
+	api.app.Put("/utility-data/:id", middlewares.OperatorOrAdmin, h.UpdateReading)
+	api.app.Post("/utility-data/:id/void", middlewares.OperatorOrAdmin, h.VoidReading)
+	api.app.Post("/corrections/:id/approve", middlewares.AdminOnly, h.ApproveCorrection)
+	api.app.Post("/corrections/:id/reject", middlewares.AdminOnly, h.RejectCorrection)
+	return api
+}
+
+// response is the JSON envelope every handler answers with
+type response struct {
+	Success           bool            `json:"success"`
+	Message           string          `json:"message"`
+	Data              json.RawMessage `json:"data"`
+	Errors            []struct{ Field, Code string }
+	NeedsConfirmation bool `json:"needsConfirmation"`
+	Warnings          []struct{ Field, Code string }
+}
+
+// do sends a request with a JSON body as role and decodes the response
+func (api *testAPI) do(t *testing.T, role, method, path string, body interface{}) (int, response) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-Role", role)
+
+	resp, err := api.app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var decoded response
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("%s %s: decoding response: %v", method, path, err)
+	}
+	return resp.StatusCode, decoded
+}
+
+// createSite saves a site in Bangkok time
+func (api *testAPI) createSite(t *testing.T) models.Client {
+	t.Helper()
+	client := models.Client{Name: "Bang Na", PlotNumber: "A-1", Timezone: "Asia/Bangkok"}
+	if err := api.db.Create(&client).Error; err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// auditActions returns the actions audited so far, oldest first
+func (api *testAPI) auditActions(t *testing.T) []string {
+	t.Helper()
+	var actions []string
+	if err := api.db.Model(&models.AuditEntry{}).Order("id").Pluck("action", &actions).Error; err != nil {
+		t.Fatal(err)
+	}
+	return actions
+}
+
+// hasAction reports whether actions includes action
+func hasAction(actions []string, action string) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
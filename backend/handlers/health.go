@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"utility-backend/database"
+)
+
+// readyTimeout bounds the database queries of a readiness check
+const readyTimeout = 2 * time.Second
+
+// Live reports that the process is up and serving requests, without checking its dependencies
+func (h *Handler) Live(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "ok",
+	})
+}
+
+// Ready reports whether the server can do its work: the database answers,
+// its schema is at the version of this build and the background workers
+// are running. Each check is listed, and any failure answers 503.
+func (h *Handler) Ready(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), readyTimeout)
+	defer cancel()
+
+	checks := fiber.Map{"database": "ok", "migrations": "ok", "workers": "ok"}
+	ready := true
+
+	if err := database.Ping(ctx, h.db); err != nil {
+		// The error names the database host and user, so it is only logged
+		slog.ErrorContext(ctx, "Readiness check failed", "error", err)
+		checks["database"] = "unreachable"
+		checks["migrations"] = "unknown"
+		ready = false
+	} else if err := database.CheckSchema(h.db.WithContext(ctx)); err != nil {
+		checks["migrations"] = err.Error()
+		ready = false
+	}
+
+	if stale := h.monitor.StaleWorkers(time.Now()); len(stale) > 0 {
+		checks["workers"] = strings.Join(stale, "; ")
+		ready = false
+	}
+
+	if !ready {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status": "unavailable",
+			"checks": checks,
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "ok",
+		"checks": checks,
+	})
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"utility-backend/audit"
+	"utility-backend/repository"
+	"utility-backend/scheduler"
+)
+
+// ListJobs returns the scheduled jobs with their next run times and latest runs
+func (h *Handler) ListJobs(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	jobs, err := h.jobs.Jobs(h.db)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load jobs: " + err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    jobs,
+	})
+}
+
+// ListJobRuns returns the run history, most recent first, optionally of a single job
+func (h *Handler) ListJobRuns(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	runs, err := repository.NewJobRuns(h.db).List(c.Query("job"), limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load job runs: " + err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    runs,
+	})
+}
+
+// RunJob starts a job now, outside its schedule. The run carries on after
+// the response; its outcome shows in the run history.
+func (h *Handler) RunJob(c *fiber.Ctx) error {
+	username, _ := c.Locals("username").(string)
+	run, err := h.jobs.Trigger(c.Params("name"), username)
+	switch {
+	case errors.Is(err, scheduler.ErrUnknownJob):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Job not found",
+		})
+	case errors.Is(err, scheduler.ErrRunning):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "Job is already running",
+		})
+	case errors.Is(err, scheduler.ErrStopped):
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"success": false,
+			"message": "Server is shutting down",
+		})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to start job: " + err.Error(),
+		})
+	}
+
+	audit.Record(c, "job.run", "job_run", run.ID, nil, run)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"message": "Job started",
+		"data":    run,
+	})
+}
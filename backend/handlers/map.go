@@ -5,19 +5,20 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 
-	"utility-backend/database"
+	"utility-backend/metrics"
 	"utility-backend/models"
 )
 
 // GetMapData returns data for the interactive site map
-func GetMapData(c *fiber.Ctx) error {
+func (h *Handler) GetMapData(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
 	// Get all clients
-	var clients []models.Client
-	result := database.DB.Find(&clients)
-	if result.Error != nil {
+	clients, err := h.clients.List()
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
-			"message": "Failed to load clients: " + result.Error.Error(),
+			"message": "Failed to load clients: " + err.Error(),
 		})
 	}
 
@@ -54,25 +55,15 @@ func GetMapData(c *fiber.Ctx) error {
 			mapData.Stats.Critical++
 		}
 
-		// Get utility data for this client (last 7 days)
-		var utilityData []models.UtilityData
-		database.DB.Where("client_id = ?", client.ID).Order("date desc").Limit(7).Find(&utilityData)
+		// Get daily water usage for this client (last 7 days)
+		days, _ := metrics.DailyRollup(h.db, client.ID, 7)
 
 		// Prepare usage history
-		usageHistory := make([]models.Usage, len(utilityData))
-		for j, data := range utilityData {
-			// Convert date to readable format
-			t, err := time.Parse("2006-01-02", data.Date)
-			dateStr := data.Date
-			if err == nil {
-				dateStr = t.Format("Jan 2")
-			}
-
-			// Reverse order for ascending dates
-			k := len(utilityData) - j - 1
+		usageHistory := make([]models.Usage, len(days))
+		for k, day := range days {
 			usageHistory[k] = models.Usage{
-				Date:  dateStr,
-				Value: data.WaterUsage,
+				Date:  day.Date.Format("Jan 2"),
+				Value: day.Value("water"),
 			}
 		}
 
@@ -171,4 +162,4 @@ func getMockUsageHistory() []models.Usage {
 	}
 
 	return history
-} 
\ No newline at end of file
+}
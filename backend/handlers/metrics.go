@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"regexp"
+
+	"github.com/gofiber/fiber/v2"
+
+	"utility-backend/audit"
+	"utility-backend/metrics"
+	"utility-backend/models"
+	"utility-backend/units"
+)
+
+// metricCodePattern restricts metric codes to identifiers usable as JSON keys and CSV columns
+var metricCodePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// MetricRequest represents the body for creating or updating a catalog metric.
+// Omitted fields keep their current value on update.
+type MetricRequest struct {
+	Code        string   `json:"code"`
+	Name        *string  `json:"name"`
+	Unit        *string  `json:"unit"`
+	Category    *string  `json:"category"`
+	Aggregation *string  `json:"aggregation"`
+	Precision   *int     `json:"precision"`
+	SortOrder   *int     `json:"sortOrder"`
+	Min         *float64 `json:"min"`
+	Max         *float64 `json:"max"`
+	Density     *float64 `json:"density"`
+	Active      *bool    `json:"active"`
+}
+
+// displayUnits parses the units query parameter, e.g. units=water:gal,pac:lb,
+// into the unit each metric should be shown in
+func displayUnits(c *fiber.Ctx, catalog []models.Metric) (map[string]string, error) {
+	return metrics.ParseDisplayUnits(c.Query("units"), catalog)
+}
+
+// metricSeries builds the catalog-driven part of a dashboard or client
+// response from the charted days, the totals over all readings and the
+// calendar-month usage
+func metricSeries(catalog []models.Metric, charted []metrics.Day, labels []string, totals map[string]float64, monthly map[string]models.MonthlyUsage, display map[string]string) []models.MetricSeries {
+	series := make([]models.MetricSeries, len(catalog))
+	for i, m := range catalog {
+		s := models.MetricSeries{
+			Code:        m.Code,
+			Name:        m.Name,
+			Unit:        metrics.DisplayUnit(m, display),
+			Category:    m.Category,
+			Aggregation: m.Aggregation,
+			Precision:   m.Precision,
+			Labels:      labels,
+			Data:        make([]float64, len(charted)),
+		}
+		if m.Aggregation == metrics.AggregationSum {
+			s.Total = metrics.InDisplayUnit(m, totals[m.Code], display)
+			usage := monthly[m.Code]
+			s.Monthly = &models.MonthlyUsage{
+				Average:           metrics.InDisplayUnit(m, usage.Average, display),
+				CompleteMonths:    usage.CompleteMonths,
+				MonthToDate:       metrics.InDisplayUnit(m, usage.MonthToDate, display),
+				ProjectedMonthEnd: metrics.InDisplayUnit(m, usage.ProjectedMonthEnd, display),
+			}
+		}
+
+		var sum float64
+		var count int
+		for j, data := range charted {
+			if v, ok := data.Lookup(m.Code); ok {
+				v = metrics.InDisplayUnit(m, v, display)
+				s.Data[j] = v
+				sum += v
+				count++
+			}
+		}
+		if count > 0 {
+			s.Average = sum / float64(count)
+		}
+
+		series[i] = s
+	}
+	return series
+}
+
+// applyMetricRequest copies the set fields of a request onto a metric and
+// returns a message describing the first invalid field
+func applyMetricRequest(metric *models.Metric, req MetricRequest) string {
+	if req.Name != nil {
+		metric.Name = *req.Name
+	}
+	if req.Unit != nil {
+		metric.Unit = *req.Unit
+	}
+	if req.Category != nil {
+		metric.Category = *req.Category
+	}
+	if req.Aggregation != nil {
+		metric.Aggregation = *req.Aggregation
+	}
+	if req.Precision != nil {
+		metric.Precision = *req.Precision
+	}
+	if req.SortOrder != nil {
+		metric.SortOrder = *req.SortOrder
+	}
+	if req.Min != nil {
+		metric.Min = req.Min
+	}
+	if req.Max != nil {
+		metric.Max = req.Max
+	}
+	if req.Density != nil {
+		metric.Density = req.Density
+	}
+	if req.Active != nil {
+		metric.Active = *req.Active
+	}
+
+	// Units are stored by their canonical symbol
+	unit, unitErr := units.Lookup(metric.Unit)
+	if unitErr == nil {
+		metric.Unit = unit.Symbol
+	}
+
+	switch {
+	case metric.Name == "":
+		return "Name is required"
+	case metric.Unit == "":
+		return "Unit is required"
+	case unitErr != nil:
+		return "Unknown unit, see /api/units for the supported units"
+	case metric.Density != nil && *metric.Density <= 0:
+		return "Density must be greater than zero"
+	case !metrics.IsCategory(metric.Category):
+		return "Category must be water, chemical, energy or effluent"
+	case metric.Aggregation != metrics.AggregationSum && metric.Aggregation != metrics.AggregationAvg:
+		return "Aggregation must be sum or avg"
+	case metric.Precision < 0 || metric.Precision > 6:
+		return "Precision must be between 0 and 6"
+	case metric.Min != nil && metric.Max != nil && *metric.Max < *metric.Min:
+		return "Max must not be less than min"
+	}
+	return ""
+}
+
+// ListMetrics returns the metric catalog. Admins can pass all=true to include inactive metrics.
+func (h *Handler) ListMetrics(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	var catalog []models.Metric
+	var err error
+	if c.Query("all") == "true" && c.Locals("role") == "admin" {
+		catalog, err = metrics.All(h.db)
+	} else {
+		catalog, err = metrics.Catalog(h.db)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load metric catalog: " + err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    catalog,
+	})
+}
+
+// CreateMetric adds a metric to the catalog
+func (h *Handler) CreateMetric(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	var req MetricRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	metric := models.Metric{
+		Code:        metrics.NormalizeCode(req.Code),
+		Aggregation: metrics.AggregationSum,
+		Precision:   2,
+		Active:      true,
+	}
+
+	// Validate input
+	if !metricCodePattern.MatchString(metric.Code) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Code must start with a letter and contain only lowercase letters, digits and underscores",
+		})
+	}
+	if msg := applyMetricRequest(&metric, req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+	if _, err := metrics.Find(h.db, metric.Code); err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "A metric with this code already exists",
+		})
+	}
+
+	active := metric.Active
+	if err := h.db.Create(&metric).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save metric: " + err.Error(),
+		})
+	}
+	if !active {
+		// Create skips false booleans, so store an inactive metric explicitly
+		h.db.Model(&metric).Update("active", false)
+	}
+
+	h.invalidateAll()
+	audit.Record(c, "metric.create", "metric", metric.ID, nil, metric)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Metric created successfully",
+		"data":    metric,
+	})
+}
+
+// UpdateMetric changes a catalog metric. The code cannot be changed because
+// readings refer to it; deactivate a metric instead of deleting it.
+func (h *Handler) UpdateMetric(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	var req MetricRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	metric, err := metrics.Find(h.db, c.Params("code"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Metric not found",
+		})
+	}
+	before := metric
+
+	if msg := applyMetricRequest(&metric, req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+
+	// Recorded values are in the current unit, so it is fixed once there are any
+	if metric.Unit != before.Unit {
+		var count int64
+		h.db.Model(&models.ReadingValue{}).Where("metric_code = ?", metric.Code).Count(&count)
+		if count > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"success": false,
+				"message": "The unit cannot be changed once readings of this metric exist",
+			})
+		}
+	}
+
+	err = h.db.Model(&metric).
+		Select("name", "unit", "category", "aggregation", "precision", "sort_order", "min", "max", "density", "active").
+		Updates(&metric).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update metric: " + err.Error(),
+		})
+	}
+
+	h.invalidateAll()
+	audit.Record(c, "metric.update", "metric", metric.ID, before, metric)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Metric updated successfully",
+		"data":    metric,
+	})
+}
+
+// ListUnits returns the supported units of measure
+func (h *Handler) ListUnits(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    units.All,
+	})
+}
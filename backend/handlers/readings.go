@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"utility-backend/metrics"
+	"utility-backend/models"
+	"utility-backend/repository"
+)
+
+// ListClientReadings returns a page of a site's readings, newest first
+// unless order=asc. Pages are continued by passing back the nextCursor of
+// the previous one, which stays valid while readings are added.
+func (h *Handler) ListClientReadings(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	clientID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid client ID format",
+		})
+	}
+	client, err := h.clients.Find(uint(clientID))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Client not found",
+		})
+	}
+
+	page := repository.ReadingPage{Limit: c.QueryInt("limit", 50)}
+	if page.Limit <= 0 || page.Limit > 500 {
+		page.Limit = 50
+	}
+
+	switch c.Query("order", "desc") {
+	case "desc":
+		page.Descending = true
+	case "asc":
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Order must be asc or desc",
+		})
+	}
+
+	for param, date := range map[string]**models.Date{"from": &page.From, "to": &page.To} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := models.ParseDate(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": fmt.Sprintf("Invalid %s date, use YYYY-MM-DD", param),
+			})
+		}
+		*date = &parsed
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		page.After, err = decodeCursor(cursor)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid cursor",
+			})
+		}
+	}
+
+	// Only the selected metrics are shown, or the active catalog by default
+	catalog, err := metrics.Catalog(h.db)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load metric catalog: " + err.Error(),
+		})
+	}
+	if selected := c.Query("metrics"); selected != "" {
+		all, err := metrics.All(h.db)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to load metric catalog: " + err.Error(),
+			})
+		}
+		catalog, err = selectMetrics(all, selected)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": err.Error(),
+			})
+		}
+		for _, m := range catalog {
+			page.Metrics = append(page.Metrics, m.Code)
+		}
+	}
+	display, err := displayUnits(c, catalog)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	// One reading more than the page tells whether there is a next page
+	limit := page.Limit
+	page.Limit++
+	readings, err := h.readings.Page(client.ID, page)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load readings: " + err.Error(),
+		})
+	}
+	var next *string
+	if len(readings) > limit {
+		readings = readings[:limit]
+		cursor := encodeCursor(readings[limit-1].ID)
+		next = &cursor
+	}
+
+	pending := map[uint]bool{}
+	pendingIDs, _ := h.readings.WithPendingCorrection(readingIDs(readings))
+	for _, id := range pendingIDs {
+		pending[id] = true
+	}
+
+	rows := make([]models.ReadingRow, len(readings))
+	for i, data := range readings {
+		rows[i] = models.ReadingRow{
+			ID:                data.ID,
+			Date:              data.Date,
+			ReadAt:            data.ReadAt,
+			Timezone:          data.Timezone,
+			Notes:             data.Notes,
+			Corrected:         data.Corrected,
+			PendingCorrection: pending[data.ID],
+			SuspiciousFields:  data.SuspiciousFields,
+			Values:            displayValues(catalog, data, display),
+			Scores:            readingScores(data),
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":    true,
+		"data":       rows,
+		"nextCursor": next,
+	})
+}
+
+// readingScores returns the anomaly scores of a reading's values, keyed by metric code
+func readingScores(data models.UtilityData) map[string]float64 {
+	scores := map[string]float64{}
+	for _, v := range data.Values {
+		if v.Score != nil {
+			scores[v.MetricCode] = *v.Score
+		}
+	}
+	return scores
+}
+
+// selectMetrics returns the metrics of the catalog named in a
+// comma-separated list. Inactive metrics can be selected, so that their
+// history can still be read.
+func selectMetrics(all []models.Metric, list string) ([]models.Metric, error) {
+	byCode := map[string]models.Metric{}
+	for _, m := range all {
+		byCode[m.Code] = m
+	}
+
+	var selected []models.Metric
+	seen := map[string]bool{}
+	for _, code := range strings.Split(list, ",") {
+		code = metrics.NormalizeCode(code)
+		m, ok := byCode[code]
+		if !ok {
+			return nil, fmt.Errorf("unknown metric %q", code)
+		}
+		if !seen[code] {
+			seen[code] = true
+			selected = append(selected, m)
+		}
+	}
+	return selected, nil
+}
+
+// encodeCursor returns the opaque cursor continuing a page after a reading
+func encodeCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("r" + strconv.FormatUint(uint64(id), 10)))
+}
+
+// decodeCursor returns the reading a cursor continues after
+func decodeCursor(cursor string) (uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(raw) < 2 || raw[0] != 'r' {
+		return 0, errors.New("invalid cursor")
+	}
+	id, err := strconv.ParseUint(string(raw[1:]), 10, 64)
+	if err != nil || id == 0 {
+		return 0, errors.New("invalid cursor")
+	}
+	return uint(id), nil
+}
+
+// ListBaselines returns a site's learned baselines by metric and day of the week
+func (h *Handler) ListBaselines(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	clientID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid client ID format",
+		})
+	}
+	client, err := h.clients.Find(uint(clientID))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Client not found",
+		})
+	}
+
+	baselines, err := repository.NewBaselines(h.db).ForClient(client.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load baselines: " + err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    baselines,
+	})
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"utility-backend/audit"
+	"utility-backend/metrics"
+	"utility-backend/models"
+	"utility-backend/validation"
+)
+
+// ValidationRuleRequest represents the body for setting a metric's plausible range
+type ValidationRuleRequest struct {
+	ClientID *uint    `json:"clientId"`
+	Metric   string   `json:"metric"`
+	Min      *float64 `json:"min"`
+	Max      *float64 `json:"max"`
+}
+
+// ListValidationRules returns the configured rules and the built-in defaults.
+// With a clientId query parameter it also returns the effective ranges for that site.
+func (h *Handler) ListValidationRules(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	var rules []models.ValidationRule
+	if err := h.db.Order("client_id, metric").Find(&rules).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load validation rules: " + err.Error(),
+		})
+	}
+
+	catalog, err := metrics.Catalog(h.db)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load metric catalog: " + err.Error(),
+		})
+	}
+
+	defaults := make([]fiber.Map, len(catalog))
+	for i, m := range catalog {
+		defaults[i] = fiber.Map{"metric": m.Code, "field": metrics.FieldName(m.Code), "unit": m.Unit, "min": m.Min, "max": m.Max}
+	}
+
+	response := fiber.Map{
+		"success":  true,
+		"data":     rules,
+		"defaults": defaults,
+	}
+
+	if clientID := c.QueryInt("clientId"); clientID > 0 {
+		ranges, err := validation.Ranges(h.db, uint(clientID))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to load validation rules: " + err.Error(),
+			})
+		}
+
+		effective := make([]fiber.Map, 0, len(catalog))
+		for _, m := range catalog {
+			r := ranges[m.Code]
+			effective = append(effective, fiber.Map{"metric": m.Code, "field": metrics.FieldName(m.Code), "unit": r.Unit, "min": r.Min, "max": r.Max})
+		}
+		response["effective"] = effective
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// SaveValidationRule creates or replaces the rule for a metric, either for
+// every site (no clientId) or for a single site
+func (h *Handler) SaveValidationRule(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	var req ValidationRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	// Validate input
+	var errs []validation.FieldError
+	req.Metric = metrics.NormalizeCode(req.Metric)
+	if metric, err := metrics.Find(h.db, req.Metric); err != nil || !metric.Active {
+		errs = append(errs, validation.FieldError{Field: "metric", Code: validation.CodeRequired, Message: "A known metric is required"})
+	}
+	if req.Min == nil && req.Max == nil {
+		errs = append(errs, validation.FieldError{Field: "min", Code: validation.CodeRequired, Message: "Min or max is required"})
+	}
+	if req.Min != nil && *req.Min < 0 {
+		errs = append(errs, validation.FieldError{Field: "min", Code: validation.CodeNegative, Message: "Min cannot be negative"})
+	}
+	if req.Min != nil && req.Max != nil && *req.Max < *req.Min {
+		errs = append(errs, validation.FieldError{Field: "max", Code: validation.CodeOutOfRange, Message: "Max must not be less than min"})
+	}
+	if len(errs) > 0 {
+		return validationFailed(c, errs)
+	}
+
+	if req.ClientID != nil {
+		if _, err := h.clients.Find(*req.ClientID); err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"message": "Site not found",
+			})
+		}
+	}
+
+	// There is at most one rule per metric and scope
+	query := h.db.Where("metric = ?", req.Metric)
+	if req.ClientID == nil {
+		query = query.Where("client_id IS NULL")
+	} else {
+		query = query.Where("client_id = ?", *req.ClientID)
+	}
+
+	var rule models.ValidationRule
+	var before interface{}
+	if query.First(&rule).Error == nil {
+		before = rule
+	}
+
+	rule.ClientID = req.ClientID
+	rule.Metric = req.Metric
+	rule.Min = req.Min
+	rule.Max = req.Max
+
+	if err := h.db.Save(&rule).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save validation rule: " + err.Error(),
+		})
+	}
+
+	audit.Record(c, "validation_rule.save", "validation_rule", rule.ID, before, rule)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Validation rule saved successfully",
+		"data":    rule,
+	})
+}
+
+// DeleteValidationRule removes a rule so the broader rule or built-in default applies again
+func (h *Handler) DeleteValidationRule(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid rule ID format",
+		})
+	}
+
+	var rule models.ValidationRule
+	if err := h.db.First(&rule, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Validation rule not found",
+		})
+	}
+
+	if err := h.db.Unscoped().Delete(&rule).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete validation rule: " + err.Error(),
+		})
+	}
+
+	audit.Record(c, "validation_rule.delete", "validation_rule", rule.ID, rule, nil)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Validation rule deleted successfully",
+	})
+}
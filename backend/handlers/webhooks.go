@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"utility-backend/audit"
+	"utility-backend/models"
+	"utility-backend/webhooks"
+)
+
+// WebhookRequest represents the body for creating or updating a webhook subscription
+type WebhookRequest struct {
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret"`
+	Events      []string `json:"events"`
+	Description string   `json:"description"`
+	Active      *bool    `json:"active"`
+}
+
+// validateWebhookRequest checks the URL and event names of a webhook request
+func validateWebhookRequest(req WebhookRequest) string {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "A valid http or https URL is required"
+	}
+	if len(req.Events) == 0 {
+		return "At least one event is required"
+	}
+	for _, e := range req.Events {
+		if !webhooks.IsValidEvent(e) {
+			return "Unknown event: " + e
+		}
+	}
+	return ""
+}
+
+// ListWebhooks returns all webhook subscriptions and the available events
+func (h *Handler) ListWebhooks(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	var subs []models.WebhookSubscription
+	result := h.db.Order("id").Find(&subs)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load webhooks: " + result.Error.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    subs,
+		"events":  webhooks.Events,
+	})
+}
+
+// CreateWebhook registers a new webhook subscription. The signing secret is
+// generated unless provided and is only returned in this response.
+func (h *Handler) CreateWebhook(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	var req WebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	if msg := validateWebhookRequest(req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = webhooks.NewSecret(); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to generate webhook secret",
+			})
+		}
+	}
+
+	sub := models.WebhookSubscription{
+		URL:         req.URL,
+		Secret:      secret,
+		Events:      strings.Join(req.Events, ","),
+		Description: req.Description,
+		Active:      req.Active == nil || *req.Active,
+	}
+	if err := h.db.Create(&sub).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save webhook: " + err.Error(),
+		})
+	}
+
+	// GORM applies the column default to a false Active on insert
+	if !sub.Active {
+		h.db.Model(&sub).Update("active", false)
+	}
+
+	audit.Record(c, "webhook.create", "webhook_subscription", sub.ID, nil, sub)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Webhook created successfully",
+		"data":    sub,
+		"secret":  secret,
+	})
+}
+
+// UpdateWebhook changes a subscription's URL, events, description or active flag
+func (h *Handler) UpdateWebhook(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid webhook ID format",
+		})
+	}
+
+	var sub models.WebhookSubscription
+	if err := h.db.First(&sub, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Webhook not found",
+		})
+	}
+
+	// Start from the current values so partial updates are allowed
+	req := WebhookRequest{
+		URL:         sub.URL,
+		Events:      strings.Split(sub.Events, ","),
+		Description: sub.Description,
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	if msg := validateWebhookRequest(req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+
+	before := sub
+	updates := map[string]interface{}{
+		"url":         req.URL,
+		"events":      strings.Join(req.Events, ","),
+		"description": req.Description,
+	}
+	if req.Active != nil {
+		updates["active"] = *req.Active
+	}
+	if req.Secret != "" {
+		updates["secret"] = req.Secret
+	}
+
+	if err := h.db.Model(&sub).Updates(updates).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update webhook: " + err.Error(),
+		})
+	}
+	h.db.First(&sub, sub.ID)
+
+	audit.Record(c, "webhook.update", "webhook_subscription", sub.ID, before, sub)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Webhook updated successfully",
+		"data":    sub,
+	})
+}
+
+// DeleteWebhook removes a webhook subscription
+func (h *Handler) DeleteWebhook(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid webhook ID format",
+		})
+	}
+
+	var sub models.WebhookSubscription
+	if err := h.db.First(&sub, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Webhook not found",
+		})
+	}
+
+	result := h.db.Delete(&sub)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete webhook: " + result.Error.Error(),
+		})
+	}
+
+	audit.Record(c, "webhook.delete", "webhook_subscription", sub.ID, sub, nil)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// ListWebhookDeliveries returns recent deliveries, optionally filtered by status and subscription
+func (h *Handler) ListWebhookDeliveries(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	query := h.db.Order("id desc").Limit(limit)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if subID := c.QueryInt("subscriptionId"); subID > 0 {
+		query = query.Where("subscription_id = ?", subID)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Find(&deliveries).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load deliveries: " + err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    deliveries,
+	})
+}
+
+// ListDeadLetters returns deliveries that exhausted their retries
+func (h *Handler) ListDeadLetters(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	var deliveries []models.WebhookDelivery
+	result := h.db.Where("status = ?", webhooks.StatusDead).Order("id desc").Find(&deliveries)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load dead letters: " + result.Error.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    deliveries,
+	})
+}
+
+// RedeliverWebhook queues a delivery to be sent again with a fresh retry budget
+func (h *Handler) RedeliverWebhook(c *fiber.Ctx) error {
+	h = h.forRequest(c)
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid delivery ID format",
+		})
+	}
+
+	delivery, err := h.webhooks.Redeliver(h.db, uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Delivery not found",
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"message": "Delivery queued for redelivery",
+		"data":    delivery,
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"utility-backend/audit"
+	"utility-backend/config"
+	"utility-backend/database"
+	"utility-backend/readingcsv"
+	"utility-backend/repository"
+)
+
+// maxReportedErrors caps the invalid rows listed by an import
+const maxReportedErrors = 50
+
+// runImport handles the import subcommand:
+//
+//	import [-dry-run] [-user username] <file.csv | ->
+func runImport(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "check the file without importing anything")
+	username := flags.String("user", "", "record the readings as submitted by this user")
+	flags.Usage = usageFunc(flags, "import [-dry-run] [-user username] <file.csv | ->")
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		flags.Usage()
+		return errors.New("expected one file, or - for standard input")
+	}
+
+	var in io.Reader = os.Stdin
+	if positional[0] != "-" {
+		file, err := os.Open(positional[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+
+	opts := readingcsv.ImportOptions{DryRun: *dryRun}
+	if *username != "" {
+		user, err := repository.NewUsers(db).FindByUsername(*username)
+		if err != nil {
+			return fmt.Errorf("user %s not found", *username)
+		}
+		opts.RecordedBy = &user.ID
+	}
+
+	result, err := readingcsv.Import(db, in, opts)
+	if err != nil {
+		return err
+	}
+
+	if len(result.Errors) > 0 {
+		for i, e := range result.Errors {
+			if i == maxReportedErrors {
+				fmt.Fprintf(os.Stderr, "... and %d more\n", len(result.Errors)-i)
+				break
+			}
+			fmt.Fprintln(os.Stderr, e)
+		}
+		return fmt.Errorf("%d of %d rows are invalid, nothing was imported", len(result.Errors), result.Rows)
+	}
+
+	if *dryRun {
+		fmt.Printf("%d rows are valid: %d readings would be imported, %d duplicates skipped\n",
+			result.Rows, result.Imported, result.Duplicates)
+		return nil
+	}
+	if result.Imported > 0 {
+		err := audit.RecordCommand(db, "import", "reading.import", "utility_data", 0, nil, map[string]interface{}{
+			"file":       positional[0],
+			"imported":   result.Imported,
+			"duplicates": result.Duplicates,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Imported %d readings, skipped %d duplicates\n", result.Imported, result.Duplicates)
+	return nil
+}
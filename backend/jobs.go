@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/anomaly"
+	"utility-backend/audit"
+	"utility-backend/cache"
+	"utility-backend/models"
+	"utility-backend/notifications"
+	"utility-backend/repository"
+	"utility-backend/scheduler"
+	"utility-backend/sites"
+	"utility-backend/webhooks"
+)
+
+// registerJobs adds the background jobs to the scheduler. Digests are only
+// sent when digests has email enabled. Status changes and alerts are
+// published through hooks, and cached responses of the sites they touch are
+// dropped from responses, which may be nil; rebuilt rollups and relearned
+// baselines drop them all. Readings are checked for alerts
+// as they are submitted and corrected; the evaluate-alerts job only catches
+// the low daily totals that cannot be told until the day is over.
+func registerJobs(sched *scheduler.Scheduler, digests *notifications.Sender, hooks *webhooks.Dispatcher, responses cache.Store) error {
+	jobs := []scheduler.Job{
+		{
+			Name:        "recompute-status",
+			Schedule:    "*/15 * * * *",
+			Description: "recompute site statuses from alerts and flagged readings",
+			Run: func(ctx context.Context, db *gorm.DB) error {
+				return recomputeStatus(db, hooks, responses)
+			},
+		},
+		{
+			Name:        "evaluate-alerts",
+			Schedule:    "10 * * * *",
+			Description: "raise alerts for daily totals found too low once a site's day is over",
+			Run: func(ctx context.Context, db *gorm.DB) error {
+				return evaluateAlerts(db, hooks, responses)
+			},
+		},
+		{
+			Name:        "rebuild-rollups",
+			Schedule:    "30 2 * * *",
+			Description: "rebuild the daily and monthly rollups from the readings",
+			Run: func(ctx context.Context, db *gorm.DB) error {
+				_, err := repository.NewRollups(db).Rebuild(0)
+				cache.Clear(responses)
+				return err
+			},
+		},
+		{
+			Name:        "learn-baselines",
+			Schedule:    "0 3 * * *",
+			Description: "relearn each site's baselines from its recent readings",
+			Run: func(ctx context.Context, db *gorm.DB) error {
+				_, err := anomaly.Learn(db, 0, time.Now())
+				cache.Clear(responses)
+				return err
+			},
+		},
+	}
+	if digests.Enabled() {
+		jobs = append(jobs, scheduler.Job{
+			Name:        "send-digests",
+			Schedule:    "* * * * *",
+			Description: "email the daily digests whose send hour has passed",
+			Run: func(ctx context.Context, db *gorm.DB) error {
+				return digests.SendDueDigests(ctx, db, time.Now())
+			},
+		})
+	}
+
+	for _, job := range jobs {
+		if err := sched.Register(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recomputeStatus recomputes the status of every site, as the
+// recompute-status command does, and audits the changes as made by the job
+func recomputeStatus(db *gorm.DB, hooks *webhooks.Dispatcher, responses cache.Store) error {
+	clients, err := repository.NewClients(db).List()
+	if err != nil {
+		return err
+	}
+	changes, err := sites.Recompute(db, hooks, clients, time.Now(), false, func(before, after models.Client) error {
+		return audit.RecordJob(db, "recompute-status", "client.status_change", "client", after.ID, before, after)
+	})
+	for _, change := range changes {
+		cache.Invalidate(responses, change.Client.ID)
+	}
+	return err
+}
+
+// evaluateAlerts raises alerts for the days just over at each site and
+// audits them as made by the job
+func evaluateAlerts(db *gorm.DB, hooks *webhooks.Dispatcher, responses cache.Store) error {
+	alerts, err := anomaly.CheckDays(db, hooks, time.Now())
+	for _, alert := range alerts {
+		cache.Invalidate(responses, alert.ClientID)
+		if err := audit.RecordJob(db, "evaluate-alerts", "alert.open", "alert", alert.ID, nil, alert); err != nil {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,130 @@
+// Package logging sets up the structured logs of the backend. Records are
+// written to stderr as JSON or text, carry the ID of the request they were
+// written for and have passwords and tokens redacted. Each component can log
+// at its own level.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"utility-backend/config"
+)
+
+// setup is the handler and levels that records are written with
+type setup struct {
+	handler slog.Handler
+	level   slog.Level
+	levels  map[string]slog.Level
+}
+
+// levelOf returns the level that component logs at
+func (s *setup) levelOf(component string) slog.Level {
+	if level, ok := s.levels[component]; ok {
+		return level
+	}
+	return s.level
+}
+
+// current is replaced by Setup. Until then records are written as text at info level.
+var current atomic.Pointer[setup]
+
+func init() {
+	current.Store(&setup{handler: newHandler("text"), level: slog.LevelInfo})
+}
+
+// Setup configures every logger, including those of For created earlier and
+// the standard library's log package, which logs at info level
+func Setup(cfg config.Log) error {
+	s := &setup{handler: newHandler(cfg.Format), levels: map[string]slog.Level{}}
+	if err := s.level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return err
+	}
+	levels, err := cfg.ComponentLevels()
+	if err != nil {
+		return err
+	}
+	for component, name := range levels {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(name)); err != nil {
+			return err
+		}
+		s.levels[component] = level
+	}
+
+	current.Store(s)
+	slog.SetDefault(For(""))
+	return nil
+}
+
+// newHandler returns a handler writing every record to stderr in format.
+// Levels are filtered before records reach it.
+func newHandler(format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: redactAttr}
+	if format == "json" {
+		return requestHandler{slog.NewJSONHandler(os.Stderr, opts)}
+	}
+	return requestHandler{slog.NewTextHandler(os.Stderr, opts)}
+}
+
+// Duration returns d as a durationMs attribute, which reads better in JSON
+// than the nanoseconds of slog.Duration
+func Duration(d time.Duration) slog.Attr {
+	return slog.Float64("durationMs", float64(d.Microseconds())/1000)
+}
+
+// For returns the logger of a component, one of config.LogComponents.
+// Its records are labelled with the component, and its level can be set
+// apart from the rest in LOG_LEVELS.
+func For(component string) *slog.Logger {
+	return slog.New(&componentHandler{component: component})
+}
+
+// componentHandler writes a component's records with the current setup, so
+// that loggers can be created before Setup runs
+type componentHandler struct {
+	component string
+	// with adds the attributes and groups of Logger.With and Logger.WithGroup
+	with func(slog.Handler) slog.Handler
+}
+
+// Enabled implements slog.Handler
+func (h *componentHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= current.Load().levelOf(h.component)
+}
+
+// Handle implements slog.Handler
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	handler := current.Load().handler
+	if h.component != "" {
+		handler = handler.WithAttrs([]slog.Attr{slog.String("component", h.component)})
+	}
+	if h.with != nil {
+		handler = h.with(handler)
+	}
+	return handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.wrap(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+// WithGroup implements slog.Handler
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	return h.wrap(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+// wrap returns a copy of h that also applies with to the handler
+func (h *componentHandler) wrap(with func(slog.Handler) slog.Handler) slog.Handler {
+	prev := h.with
+	if prev == nil {
+		return &componentHandler{component: h.component, with: with}
+	}
+	return &componentHandler{component: h.component, with: func(handler slog.Handler) slog.Handler {
+		return with(prev(handler))
+	}}
+}
@@ -1,83 +1,119 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/logger"
-	"github.com/joho/godotenv"
+	// Embed the timezone database for job and digest scheduling in slim images
+	_ "time/tzdata"
 
+	"utility-backend/config"
 	"utility-backend/database"
-	"utility-backend/handlers"
-	"utility-backend/middlewares"
+	"utility-backend/logging"
+	"utility-backend/seed"
 )
 
 func main() {
-	log.Println("Starting utility monitoring backend...")
-	
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: No .env file found or error loading it")
+	// Settings come from the environment, a settings file and flags before the command
+	global := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	loadConfig := config.Flags(global)
+	global.Usage = func() { usage(global) }
+	if err := global.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		os.Exit(2)
 	}
 
-	// Initialize database
-	log.Println("Initializing database connection...")
-	if err := database.InitDB(); err != nil {
-		log.Fatalf("Error initializing database: %v", err)
+	name, args := "serve", global.Args()
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		usage(global)
+		return
+	}
+	cmd, ok := findCommand(name)
+	if !ok {
+		usage(global)
+		os.Exit(2)
 	}
-	log.Println("Database initialized successfully")
-
-	// Create Fiber app
-	app := fiber.New(fiber.Config{
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-			}
 
-			return c.Status(code).JSON(fiber.Map{
-				"success": false,
-				"message": err.Error(),
-				"data":    nil,
-			})
-		},
-	})
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// Middleware
-	app.Use(logger.New())
+	if err := logging.Setup(cfg.Log); err != nil {
+		log.Fatal(err)
+	}
 
-	// Setup CORS
-	handlers.SetupCORS(app)
+	if err := cmd.run(cfg, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		slog.Error("Command failed", "command", cmd.name, "error", err)
+		os.Exit(1)
+	}
+}
 
-	// API routes
-	api := app.Group("/api")
+// runServe handles the serve subcommand, starting the API server
+func runServe(cfg *config.Config, args []string) error {
+	if len(args) > 0 {
+		return errors.New("usage: serve")
+	}
+	slog.Info("Starting utility monitoring backend", "environment", cfg.Environment)
+	if cfg.File != "" {
+		slog.Info("Loaded settings", "file", cfg.File)
+	}
+	if cfg.JWTSecret == "" {
+		slog.Warn("JWT_SECRET is not set, login tokens are signed with an empty key")
+	}
 
-	// Public routes
-	api.Post("/login", handlers.Login)
+	// Initialize database
+	slog.Info("Initializing database connection")
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("initializing database: %w", err)
+	}
+	slog.Info("Database initialized successfully")
 
-	// Protected routes
-	api.Use(middlewares.AuthRequired)
-	api.Get("/dashboard", handlers.GetDashboardData)
-	api.Post("/submit-data", handlers.SubmitData)
-	api.Get("/map-data", handlers.GetMapData)
-	api.Get("/clients/:id", handlers.GetClient)
+	// Development databases start with demo data; production is seeded explicitly
+	if !cfg.IsProduction() {
+		if err := seed.IfEmpty(db); err != nil {
+			return fmt.Errorf("seeding demo data: %w", err)
+		}
+	}
 
-	// Health check endpoint for Render
-	api.Get("/health", func(c *fiber.Ctx) error {
-		return c.Status(200).JSON(fiber.Map{
-			"status":  "ok",
-			"message": "Server is running",
-		})
-	})
+	// SIGTERM, which platforms send before replacing an instance, and Ctrl-C
+	// start a graceful shutdown. Background workers stop when ctx is done.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	srv, err := newServer(ctx, cfg, db)
+	if err != nil {
+		return err
+	}
 
 	// Server startup
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "5001"
+	slog.Info("Server starting", "port", cfg.Port)
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- srv.app.Listen(fmt.Sprintf(":%d", cfg.Port))
+	}()
+
+	select {
+	case err := <-listenErr:
+		return err
+	case <-ctx.Done():
 	}
-
-	log.Printf("Server starting on port %s", port)
-	log.Fatal(app.Listen(":" + port))
-}
\ No newline at end of file
+	// A second signal stops the process at once
+	stop()
+	return shutdown(srv.app, db, &srv.workers, cfg.ShutdownTimeout)
+}
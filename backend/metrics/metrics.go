@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"utility-backend/models"
+	"utility-backend/units"
+)
+
+// Aggregations
+const (
+	AggregationSum = "sum"
+	AggregationAvg = "avg"
+)
+
+// Categories lists the valid metric categories
+var Categories = []string{"water", "chemical", "energy", "effluent"}
+
+// LegacyFields maps the metrics that predate the catalog to the request
+// field they are still accepted in
+var LegacyFields = map[string]string{
+	"water":    "waterMeter",
+	"pac":      "pac",
+	"polymer":  "polymer",
+	"chlorine": "chlorine",
+}
+
+// FieldName returns the request field a metric's value is submitted in
+func FieldName(code string) string {
+	if field, ok := LegacyFields[code]; ok {
+		return field
+	}
+	return "values." + code
+}
+
+// Catalog returns the active metrics in display order
+func Catalog(db *gorm.DB) ([]models.Metric, error) {
+	var catalog []models.Metric
+	err := db.Where("active = ?", true).Order("sort_order, code").Find(&catalog).Error
+	return catalog, err
+}
+
+// All returns every metric, including inactive ones, in display order
+func All(db *gorm.DB) ([]models.Metric, error) {
+	var catalog []models.Metric
+	err := db.Order("sort_order, code").Find(&catalog).Error
+	return catalog, err
+}
+
+// Find returns the metric with the given code
+func Find(db *gorm.DB, code string) (models.Metric, error) {
+	var metric models.Metric
+	err := db.Where("code = ?", code).First(&metric).Error
+	return metric, err
+}
+
+// Aggregate combines several values of a metric according to its aggregation
+func Aggregate(metric models.Metric, values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var total float64
+	for _, v := range values {
+		total += v
+	}
+	if metric.Aggregation == AggregationAvg {
+		return total / float64(len(values))
+	}
+	return total
+}
+
+// IsCategory reports whether category is a valid metric category
+func IsCategory(category string) bool {
+	for _, c := range Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeCode lower-cases a metric code and trims surrounding space
+func NormalizeCode(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
+
+// ToCatalogUnit converts a value entered in unit into the metric's catalog unit
+func ToCatalogUnit(metric models.Metric, value float64, unit string) (float64, error) {
+	return units.Convert(value, unit, metric.Unit, metric.Density)
+}
+
+// FromCatalogUnit converts a value in the metric's catalog unit into unit
+func FromCatalogUnit(metric models.Metric, value float64, unit string) (float64, error) {
+	return units.Convert(value, metric.Unit, unit, metric.Density)
+}
+
+// ParseDisplayUnits parses a list of metric:unit pairs, e.g. water:gal,pac:lb,
+// into the unit each metric should be shown in. Metrics not listed are shown
+// in their catalog unit.
+func ParseDisplayUnits(param string, catalog []models.Metric) (map[string]string, error) {
+	display := map[string]string{}
+	if param == "" {
+		return display, nil
+	}
+
+	byCode := map[string]models.Metric{}
+	for _, m := range catalog {
+		byCode[m.Code] = m
+	}
+
+	for _, pair := range strings.Split(param, ",") {
+		code, unit, ok := strings.Cut(pair, ":")
+		code = NormalizeCode(code)
+		m, known := byCode[code]
+		if !ok || !known {
+			return nil, fmt.Errorf("invalid units entry %q, use metric:unit", pair)
+		}
+
+		u, err := units.Lookup(unit)
+		if err != nil {
+			return nil, err
+		}
+		if !units.Compatible(m.Unit, u.Symbol, m.Density) {
+			return nil, fmt.Errorf("%s is measured in %s and cannot be shown in %s", m.Name, m.Unit, u.Symbol)
+		}
+		display[code] = u.Symbol
+	}
+	return display, nil
+}
+
+// InDisplayUnit converts a value in the metric's catalog unit into its display unit
+func InDisplayUnit(m models.Metric, value float64, display map[string]string) float64 {
+	unit, ok := display[m.Code]
+	if !ok {
+		return value
+	}
+	converted, err := FromCatalogUnit(m, value, unit)
+	if err != nil {
+		return value
+	}
+	return converted
+}
+
+// DisplayUnit returns the unit a metric is shown in
+func DisplayUnit(m models.Metric, display map[string]string) string {
+	if unit, ok := display[m.Code]; ok {
+		return unit
+	}
+	return m.Unit
+}
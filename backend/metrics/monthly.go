@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"sort"
+
+	"gorm.io/gorm"
+
+	"utility-backend/models"
+)
+
+// Month is the rollup of a site's readings in one calendar month
+type Month struct {
+	Month    models.Date        // first day of the month
+	Values   map[string]float64 // keyed by metric code, combined with each metric's aggregation
+	Days     int                // days with readings of the month's most recorded metric
+	Complete bool               // the site was reading for the whole month, which has ended
+}
+
+// Lookup returns the month's value for a metric and whether one was recorded
+func (m Month) Lookup(code string) (float64, bool) {
+	v, ok := m.Values[code]
+	return v, ok
+}
+
+// MonthlyRollup combines a site's readings per calendar month, summing or
+// averaging each metric according to the catalog, as of the local date
+// today. Months are returned oldest first. It reads the monthly rollups.
+//
+// A month is complete once it has ended, unless the site's first reading
+// was taken after its first day. Months without readings are left out
+// rather than counted as no usage.
+func MonthlyRollup(db *gorm.DB, clientID uint, today models.Date) ([]Month, error) {
+	sites, err := monthlyRollups(db, clientID, today)
+	if err != nil {
+		return nil, err
+	}
+	return sites[clientID], nil
+}
+
+// Monthly summarizes each summed metric of a site, or of all sites with a
+// clientID of 0, by calendar month as of the local date today: the average
+// total of the complete months that recorded it, the total of the current
+// month so far, and the month-end total projected from the average of the
+// current month's days with readings. The
+// summaries of all sites add up those of each site, so that sites which
+// started reading at different times are each averaged over their own
+// complete months.
+func Monthly(db *gorm.DB, clientID uint, today models.Date) (map[string]models.MonthlyUsage, error) {
+	sites, err := monthlyRollups(db, clientID, today)
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := All(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sites are added up in a fixed order, for the same totals every time
+	siteIDs := make([]uint, 0, len(sites))
+	for id := range sites {
+		siteIDs = append(siteIDs, id)
+	}
+	sort.Slice(siteIDs, func(i, j int) bool { return siteIDs[i] < siteIDs[j] })
+
+	current := models.NewDate(today.Year(), today.Month(), 1)
+	daysInMonth := current.AddDate(0, 1, -1).Day()
+
+	usage := map[string]models.MonthlyUsage{}
+	for _, m := range catalog {
+		if m.Aggregation != AggregationSum {
+			continue
+		}
+		var total models.MonthlyUsage
+		for _, id := range siteIDs {
+			months := sites[id]
+			var site models.MonthlyUsage
+			var sum float64
+			var days int
+			for _, month := range months {
+				v, ok := month.Lookup(m.Code)
+				switch {
+				case !ok:
+				case month.Month.Equal(current.Time):
+					site.MonthToDate = v
+					days = month.Days
+				case month.Complete:
+					sum += v
+					site.CompleteMonths++
+				}
+			}
+			if site.CompleteMonths > 0 {
+				site.Average = sum / float64(site.CompleteMonths)
+			}
+			if days > 0 {
+				site.ProjectedMonthEnd = site.MonthToDate / float64(days) * float64(daysInMonth)
+			}
+
+			total.Average += site.Average
+			total.MonthToDate += site.MonthToDate
+			total.ProjectedMonthEnd += site.ProjectedMonthEnd
+			if site.CompleteMonths > total.CompleteMonths {
+				total.CompleteMonths = site.CompleteMonths
+			}
+		}
+		usage[m.Code] = total
+	}
+	return usage, nil
+}
+
+// monthlyRollups returns the monthly rollups of a site, or of every site
+// with a clientID of 0, keyed by site, oldest first
+func monthlyRollups(db *gorm.DB, clientID uint, today models.Date) (map[uint][]Month, error) {
+	scope := func(model interface{}) *gorm.DB {
+		query := db.Model(model)
+		if clientID != 0 {
+			query = query.Where("client_id = ?", clientID)
+		}
+		return query
+	}
+
+	var rows []struct {
+		ClientID   uint
+		Month      models.Date
+		MetricCode string
+		Total      float64
+		Readings   int
+		Days       int
+	}
+	err := scope(&models.MonthlyRollup{}).
+		Select("client_id, month, metric_code, total, readings, days").
+		Order("client_id, month").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var firsts []struct {
+		ClientID uint
+		First    models.Date
+	}
+	err = scope(&models.DailyRollup{}).
+		Select("client_id, MIN(date) AS first").Group("client_id").Scan(&firsts).Error
+	if err != nil {
+		return nil, err
+	}
+	started := map[uint]models.Date{}
+	for _, f := range firsts {
+		started[f.ClientID] = f.First
+	}
+
+	catalog, err := All(db)
+	if err != nil {
+		return nil, err
+	}
+	averaged := map[string]bool{}
+	for _, m := range catalog {
+		averaged[m.Code] = m.Aggregation == AggregationAvg
+	}
+
+	current := models.NewDate(today.Year(), today.Month(), 1)
+	sites := map[uint][]Month{}
+	for _, row := range rows {
+		months := sites[row.ClientID]
+		if len(months) == 0 || !months[len(months)-1].Month.Equal(row.Month.Time) {
+			months = append(months, Month{
+				Month:    row.Month,
+				Values:   map[string]float64{},
+				Complete: row.Month.Before(current.Time) && !started[row.ClientID].After(row.Month.Time),
+			})
+		}
+		month := &months[len(months)-1]
+		if averaged[row.MetricCode] {
+			month.Values[row.MetricCode] = row.Total / float64(row.Readings)
+		} else {
+			month.Values[row.MetricCode] = row.Total
+		}
+		if row.Days > month.Days {
+			month.Days = row.Days
+		}
+		sites[row.ClientID] = months
+	}
+	return sites, nil
+}
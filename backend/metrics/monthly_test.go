@@ -0,0 +1,187 @@
+package metrics
+
+import (
+	"math"
+	"net/url"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/config"
+	"utility-backend/database"
+	"utility-backend/models"
+	"utility-backend/repository"
+)
+
+// openTestDB returns a migrated in-memory SQLite database of the test's own
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	cfg, err := config.Load("", map[string]string{
+		"DB_TYPE": "sqlite",
+		"DB_PATH": "file:" + url.PathEscape(t.Name()) + "?mode=memory&cache=shared",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// createSite saves a site in Bangkok time
+func createSite(t *testing.T, db *gorm.DB, name string) models.Client {
+	t.Helper()
+	client := models.Client{Name: name, PlotNumber: "P-" + name, Timezone: "Asia/Bangkok"}
+	if err := db.Create(&client).Error; err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// createReading saves and rolls up a reading of a date, values in catalog units
+func createReading(t *testing.T, db *gorm.DB, clientID uint, date string, values map[string]float64) {
+	t.Helper()
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reading := models.UtilityData{
+		ClientID: clientID,
+		Date:     models.DateOf(day),
+		ReadAt:   day.Add(2 * time.Hour),
+		Timezone: "Asia/Bangkok",
+	}
+	for code, value := range values {
+		reading.Values = append(reading.Values, models.ReadingValue{MetricCode: code, Value: value})
+	}
+	if err := repository.NewReadings(db).Create(&reading); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMonthly(t *testing.T) {
+	db := openTestDB(t)
+	today := models.NewDate(2026, 10, 19)
+
+	// Started reading mid-July, so July is not a complete month
+	bangNa := createSite(t, db, "Bang Na")
+	createReading(t, db, bangNa.ID, "2026-07-15", map[string]float64{"water": 100})
+	createReading(t, db, bangNa.ID, "2026-08-01", map[string]float64{"water": 300, "pac": 2})
+	createReading(t, db, bangNa.ID, "2026-08-20", map[string]float64{"water": 300})
+	createReading(t, db, bangNa.ID, "2026-09-10", map[string]float64{"water": 900, "pac": 4.5})
+	createReading(t, db, bangNa.ID, "2026-10-01", map[string]float64{"water": 50, "pac": 1})
+	createReading(t, db, bangNa.ID, "2026-10-02", map[string]float64{"water": 50, "bod": 20})
+	createReading(t, db, bangNa.ID, "2026-10-10", map[string]float64{"water": 100, "bod": 30})
+
+	// Read nothing in September, which is left out rather than averaged as 0
+	latKrabang := createSite(t, db, "Lat Krabang")
+	createReading(t, db, latKrabang.ID, "2026-08-01", map[string]float64{"water": 1000})
+	createReading(t, db, latKrabang.ID, "2026-10-05", map[string]float64{"water": 500})
+
+	// Never read
+	idle := createSite(t, db, "Idle")
+
+	tests := []struct {
+		name     string
+		clientID uint
+		metric   string
+		want     models.MonthlyUsage
+	}{
+		{
+			name: "water", clientID: bangNa.ID, metric: "water",
+			// August and September averaged; October's 200 over 3 of 31 days
+			want: models.MonthlyUsage{Average: 750, CompleteMonths: 2, MonthToDate: 200, ProjectedMonthEnd: 200.0 / 3 * 31},
+		},
+		{
+			name: "chemical", clientID: bangNa.ID, metric: "pac",
+			// Projected over the days the month was read, not those with PAC
+			want: models.MonthlyUsage{Average: 3.25, CompleteMonths: 2, MonthToDate: 1, ProjectedMonthEnd: 1.0 / 3 * 31},
+		},
+		{
+			name: "month with no readings", clientID: latKrabang.ID, metric: "water",
+			want: models.MonthlyUsage{Average: 1000, CompleteMonths: 1, MonthToDate: 500, ProjectedMonthEnd: 500 * 31},
+		},
+		{
+			name: "metric never read", clientID: bangNa.ID, metric: "steam",
+			want: models.MonthlyUsage{},
+		},
+		{
+			name: "site never read", clientID: idle.ID, metric: "water",
+			want: models.MonthlyUsage{},
+		},
+		{
+			name: "all sites", clientID: 0, metric: "water",
+			// Each site averaged over its own complete months, then added up
+			want: models.MonthlyUsage{Average: 1750, CompleteMonths: 2, MonthToDate: 700, ProjectedMonthEnd: 200.0/3*31 + 500*31},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage, err := Monthly(db, tt.clientID, today)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := usage["bod"]; ok {
+				t.Error("averaged metric bod is summarized")
+			}
+			got, ok := usage[tt.metric]
+			if !ok {
+				t.Fatalf("no summary of %s", tt.metric)
+			}
+			if got.CompleteMonths != tt.want.CompleteMonths || !near(got.Average, tt.want.Average) ||
+				!near(got.MonthToDate, tt.want.MonthToDate) || !near(got.ProjectedMonthEnd, tt.want.ProjectedMonthEnd) {
+				t.Errorf("Monthly(%d)[%s] = %+v, want %+v", tt.clientID, tt.metric, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMonthlyRollup(t *testing.T) {
+	db := openTestDB(t)
+	site := createSite(t, db, "Bang Na")
+	createReading(t, db, site.ID, "2026-07-15", map[string]float64{"water": 100})
+	createReading(t, db, site.ID, "2026-08-01", map[string]float64{"water": 300, "bod": 20})
+	createReading(t, db, site.ID, "2026-08-20", map[string]float64{"water": 300, "bod": 30})
+	createReading(t, db, site.ID, "2026-10-01", map[string]float64{"water": 50})
+
+	months, err := MonthlyRollup(db, site.ID, models.NewDate(2026, 10, 19))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		month    string
+		water    float64
+		bod      float64 // 0 for none
+		days     int
+		complete bool
+	}{
+		{"2026-07-01", 100, 0, 1, false},
+		{"2026-08-01", 600, 25, 2, true},
+		{"2026-10-01", 50, 0, 1, false},
+	}
+	if len(months) != len(want) {
+		t.Fatalf("%d months, want %d", len(months), len(want))
+	}
+	for i, w := range want {
+		m := months[i]
+		water, _ := m.Lookup("water")
+		bod, _ := m.Lookup("bod")
+		if m.Month.String() != w.month || water != w.water || bod != w.bod || m.Days != w.days || m.Complete != w.complete {
+			t.Errorf("month %d = %s water %v bod %v over %d days complete %v, want %+v",
+				i, m.Month, water, bod, m.Days, m.Complete, w)
+		}
+	}
+}
+
+// near reports whether two totals agree to within rounding
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
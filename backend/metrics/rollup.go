@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"utility-backend/models"
+)
+
+// Day is the rollup of the readings taken on one local date
+type Day struct {
+	Date     models.Date
+	Values   map[string]float64 // keyed by metric code, combined with each metric's aggregation
+	Readings int                // readings of the day's most recorded metric, summed over sites
+}
+
+// Value returns the day's value for a metric, or 0 if none was recorded
+func (d Day) Value(code string) float64 {
+	return d.Values[code]
+}
+
+// Lookup returns the day's value for a metric and whether one was recorded
+func (d Day) Lookup(code string) (float64, bool) {
+	v, ok := d.Values[code]
+	return v, ok
+}
+
+// DailyRollup combines readings per local date, summing or averaging each
+// metric according to the catalog. A clientID of 0 rolls up all sites. When
+// days is positive only the most recent days with readings are returned.
+// Days are returned oldest first. It reads the daily rollups rather than
+// the readings.
+func DailyRollup(db *gorm.DB, clientID uint, days int) ([]Day, error) {
+	scope := db.Model(&models.DailyRollup{})
+	if clientID != 0 {
+		scope = scope.Where("client_id = ?", clientID)
+	}
+
+	// Find the first date of the window
+	if days > 0 {
+		var dates []models.Date
+		err := scope.Session(&gorm.Session{}).Distinct("date").Order("date desc").Limit(days).Pluck("date", &dates).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(dates) == 0 {
+			return nil, nil
+		}
+		scope = scope.Where("date >= ?", dates[len(dates)-1])
+	}
+
+	var rows []struct {
+		ClientID   uint
+		Date       models.Date
+		MetricCode string
+		Total      float64
+		Readings   int
+	}
+	if err := scope.Select("client_id, date, metric_code, total, readings").Order("date").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	catalog, err := All(db)
+	if err != nil {
+		return nil, err
+	}
+	averaged := map[string]bool{}
+	for _, m := range catalog {
+		averaged[m.Code] = m.Aggregation == AggregationAvg
+	}
+
+	type sum struct {
+		total    float64
+		readings int
+	}
+	var result []Day
+	var sums []map[string]*sum
+	index := map[string]int{}        // keyed by YYYY-MM-DD
+	siteReadings := map[string]int{} // keyed by site and date
+	for _, row := range rows {
+		key := row.Date.String()
+		i, ok := index[key]
+		if !ok {
+			i = len(result)
+			index[key] = i
+			result = append(result, Day{Date: row.Date, Values: map[string]float64{}})
+			sums = append(sums, map[string]*sum{})
+		}
+		s := sums[i][row.MetricCode]
+		if s == nil {
+			s = &sum{}
+			sums[i][row.MetricCode] = s
+		}
+		s.total += row.Total
+		s.readings += row.Readings
+
+		// A site's readings on a date are those of its most recorded metric
+		siteKey := fmt.Sprintf("%d %s", row.ClientID, key)
+		if row.Readings > siteReadings[siteKey] {
+			result[i].Readings += row.Readings - siteReadings[siteKey]
+			siteReadings[siteKey] = row.Readings
+		}
+	}
+	for i := range result {
+		for code, s := range sums[i] {
+			if averaged[code] {
+				result[i].Values[code] = s.total / float64(s.readings)
+			} else {
+				result[i].Values[code] = s.total
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Totals returns the sum of every value recorded of each metric, keyed by
+// metric code. A clientID of 0 totals all sites. It reads the monthly rollups.
+func Totals(db *gorm.DB, clientID uint) (map[string]float64, error) {
+	query := db.Model(&models.MonthlyRollup{}).
+		Select("metric_code, SUM(total) AS total").
+		Group("metric_code")
+	if clientID != 0 {
+		query = query.Where("client_id = ?", clientID)
+	}
+
+	var sums []struct {
+		MetricCode string
+		Total      float64
+	}
+	if err := query.Scan(&sums).Error; err != nil {
+		return nil, err
+	}
+	totals := map[string]float64{}
+	for _, sum := range sums {
+		totals[sum.MetricCode] = sum.Total
+	}
+	return totals, nil
+}
@@ -0,0 +1,19 @@
+package middlewares
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"utility-backend/audit"
+)
+
+// Audit returns a middleware writing the audit trail of a request to db once
+// its handler has finished. It must run after AuthRequired so the acting user
+// is known.
+func Audit(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+		audit.Flush(db.WithContext(c.UserContext()), c, err)
+		return err
+	}
+}
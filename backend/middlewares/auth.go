@@ -1,7 +1,6 @@
 package middlewares
 
 import (
-	"os"
 	"strings"
 	"time"
 
@@ -13,13 +12,22 @@ import (
 
 // JWT claim structure
 type Claims struct {
+	UserID   uint   `json:"uid"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
 	jwt.RegisteredClaims
 }
 
-// AuthRequired is a middleware to check if the user is authenticated
-func AuthRequired(c *fiber.Ctx) error {
+// AuthRequired returns a middleware to check if the user is authenticated
+// with a token signed with secret
+func AuthRequired(secret []byte) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return authenticate(c, secret)
+	}
+}
+
+// authenticate checks the request's token and stores its claims
+func authenticate(c *fiber.Ctx, secret []byte) error {
 	// Get the Authorization header
 	authHeader := c.Get("Authorization")
 	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
@@ -38,9 +46,9 @@ func AuthRequired(c *fiber.Ctx) error {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid token signing method")
 		}
-		
+
 		// Return the secret key
-		return []byte(os.Getenv("JWT_SECRET")), nil
+		return secret, nil
 	})
 
 	if err != nil {
@@ -68,6 +76,7 @@ func AuthRequired(c *fiber.Ctx) error {
 	}
 
 	// Add the user claims to the context
+	c.Locals("userID", claims.UserID)
 	c.Locals("username", claims.Username)
 	c.Locals("role", claims.Role)
 
@@ -75,13 +84,14 @@ func AuthRequired(c *fiber.Ctx) error {
 	return c.Next()
 }
 
-// GenerateToken creates a new JWT token for a user
-func GenerateToken(user models.User) (string, error) {
+// GenerateToken creates a new JWT token for a user, signed with secret
+func GenerateToken(user models.User, secret []byte) (string, error) {
 	// Define token expiration (24 hours)
 	expirationTime := time.Now().Add(24 * time.Hour)
-	
+
 	// Create claims
 	claims := &Claims{
+		UserID:   user.ID,
 		Username: user.Username,
 		Role:     user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
@@ -90,43 +100,43 @@ func GenerateToken(user models.User) (string, error) {
 			Subject:   user.Username,
 		},
 	}
-	
+
 	// Create token with claims
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	
+
 	// Sign the token with the secret key
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	tokenString, err := token.SignedString(secret)
 	if err != nil {
 		return "", err
 	}
-	
+
 	return tokenString, nil
 }
 
 // AdminOnly is a middleware to check if the user is an admin
 func AdminOnly(c *fiber.Ctx) error {
 	role := c.Locals("role")
-	
+
 	if role != "admin" {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"success": false,
 			"message": "Admin access required",
 		})
 	}
-	
+
 	return c.Next()
 }
 
 // OperatorOrAdmin is a middleware to check if the user is an operator or admin
 func OperatorOrAdmin(c *fiber.Ctx) error {
 	role := c.Locals("role")
-	
+
 	if role != "operator" && role != "admin" {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"success": false,
 			"message": "Operator or admin access required",
 		})
 	}
-	
+
 	return c.Next()
-} 
\ No newline at end of file
+}
@@ -0,0 +1,122 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"utility-backend/cache"
+)
+
+// CacheTags returns the tags of a cached response, naming the sites it covers
+type CacheTags func(c *fiber.Ctx) []string
+
+// AllSites tags responses covering every site
+func AllSites(*fiber.Ctx) []string {
+	return []string{cache.TagAllSites}
+}
+
+// SiteParam tags responses covering the site in the id route parameter
+func SiteParam(c *fiber.Ctx) []string {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return nil
+	}
+	return []string{cache.SiteTag(uint(id))}
+}
+
+// Cache returns a middleware serving successful GET responses from store
+// for ttl, keyed by path, query parameters and the user. Responses
+// carry an ETag, and a request whose If-None-Match matches it is answered
+// with 304 Not Modified. A nil store or zero ttl leaves out the caching but
+// keeps the ETags. It must run after AuthRequired.
+func Cache(store cache.Store, ttl time.Duration, tags CacheTags) fiber.Handler {
+	caching := store != nil && ttl > 0
+	return func(c *fiber.Ctx) error {
+		if c.Method() != fiber.MethodGet {
+			return c.Next()
+		}
+		// Browsers keep the response but check its ETag before reusing it
+		c.Set(fiber.HeaderCacheControl, "private, no-cache")
+
+		key := cacheKey(c)
+		var tagged []string
+		var generation uint64
+		if caching {
+			if entry, ok := store.Get(key); ok {
+				c.Set("X-Cache", "HIT")
+				return sendEntry(c, entry)
+			}
+			// Read before the response is built, so that a change made
+			// meanwhile keeps it from being stored
+			tagged = tags(c)
+			generation = store.Generation(tagged...)
+		}
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if c.Response().StatusCode() != fiber.StatusOK {
+			return nil
+		}
+
+		// The response buffer is reused once the request is done
+		body := append([]byte(nil), c.Response().Body()...)
+		sum := sha256.Sum256(body)
+		entry := cache.Entry{
+			Body:        body,
+			ContentType: string(c.Response().Header.ContentType()),
+			ETag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+		}
+		if caching {
+			entry.Tags = tagged
+			entry.Generation = generation
+			store.Set(key, entry, ttl)
+			c.Set("X-Cache", "MISS")
+		}
+		return sendEntry(c, entry)
+	}
+}
+
+// sendEntry writes a cached response, or 304 if the client has it already
+func sendEntry(c *fiber.Ctx, entry cache.Entry) error {
+	c.Set(fiber.HeaderETag, entry.ETag)
+	if etagMatches(c.Get(fiber.HeaderIfNoneMatch), entry.ETag) {
+		c.Response().ResetBody()
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	c.Set(fiber.HeaderContentType, entry.ContentType)
+	return c.Status(fiber.StatusOK).Send(entry.Body)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, weakly
+// compared as RFC 9110 asks for If-None-Match
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheKey identifies a response by the user and their role, the path and
+// the query parameters in a fixed order, so that no user is served another
+// one's response
+func cacheKey(c *fiber.Ctx) string {
+	var params []string
+	c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		params = append(params, string(key)+"="+string(value))
+	})
+	sort.Strings(params)
+
+	role, _ := c.Locals("role").(string)
+	userID, _ := c.Locals("userID").(uint)
+	return strconv.FormatUint(uint64(userID), 10) + " " + role + " " + c.Path() + "?" + strings.Join(params, "&")
+}
@@ -0,0 +1,97 @@
+package middlewares
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+
+	"utility-backend/logging"
+)
+
+// RequestIDHeader carries the request ID in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID matches the request IDs accepted from clients and proxies
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// log writes one record per request
+var log = logging.For("http")
+
+// RequestID returns a middleware giving every request an ID, taken from the
+// X-Request-ID header when a proxy has set one. The ID is sent back in the
+// same header and carried by the request's user context into its logs.
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = utils.UUIDv4()
+		} else {
+			// The header value is only valid during the request
+			id = utils.CopyString(id)
+		}
+
+		c.Set(RequestIDHeader, id)
+		c.Locals("requestID", id)
+		c.SetUserContext(logging.WithRequestID(c.UserContext(), id))
+		return c.Next()
+	}
+}
+
+// RequestLog returns a middleware logging every request once it is served:
+// server errors at error level, client errors at warn level and the rest at
+// info level. Path parameters holding secrets, such as unsubscribe tokens,
+// are redacted. It must run after RequestID.
+func RequestLog() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+		if err != nil {
+			// Let the error handler write the response before it is logged
+			if handleErr := c.App().ErrorHandler(c, err); handleErr != nil {
+				c.Status(fiber.StatusInternalServerError)
+			}
+		}
+
+		status := c.Response().StatusCode()
+		level := slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= fiber.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Method()),
+			slog.String("path", loggedPath(c)),
+			slog.String("route", c.Route().Path),
+			slog.Int("status", status),
+			logging.Duration(time.Since(start)),
+			slog.Int("bytes", len(c.Response().Body())),
+			slog.String("ip", c.IP()),
+		}
+		if userID, ok := c.Locals("userID").(uint); ok {
+			attrs = append(attrs, slog.Uint64("userId", uint64(userID)))
+		}
+		if err != nil {
+			attrs = append(attrs, slog.Any("error", err))
+		}
+		log.LogAttrs(c.UserContext(), level, "Request", attrs...)
+		return nil
+	}
+}
+
+// loggedPath returns the request path with its secret parameters redacted
+func loggedPath(c *fiber.Ctx) string {
+	path := c.Path()
+	for _, name := range c.Route().Params {
+		if value := c.Params(name); value != "" && logging.IsSensitive(name) {
+			path = strings.Replace(path, value, logging.Redacted, 1)
+		}
+	}
+	return path
+}
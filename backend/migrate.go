@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"utility-backend/config"
+	"utility-backend/database"
+)
+
+// runMigrate handles the migrate subcommand:
+//
+//	migrate [up]      apply pending migrations
+//	migrate down [n]  revert the last n migrations, 1 by default
+//	migrate status    list applied and pending migrations
+func runMigrate(cfg *config.Config, args []string) error {
+	db, err := database.Connect(cfg.Database)
+	if err != nil {
+		return err
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		applied, err := database.MigrateUp(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migrations\n", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations to revert: %s", args[1])
+			}
+			steps = n
+		}
+		reverted, err := database.MigrateDown(db, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted %d migrations\n", reverted)
+	case "status":
+		status, err := database.Status(db)
+		if err != nil {
+			return err
+		}
+		if status.Legacy {
+			fmt.Println("Schema: created before versioned migrations, run migrate up to adopt it")
+		} else {
+			fmt.Printf("Schema version: %d (this build: %d)\n", status.Current, status.Latest)
+		}
+		for _, m := range status.Applied {
+			fmt.Printf("  applied  %04d_%s  %s\n", m.Version, m.Name, m.AppliedAt.Format("2006-01-02 15:04:05"))
+		}
+		for _, m := range status.Pending {
+			fmt.Printf("  pending  %04d_%s\n", m.Version, m.Name)
+		}
+		for _, v := range status.Unknown {
+			fmt.Printf("  unknown  %04d (newer than this build)\n", v)
+		}
+	default:
+		return errors.New("usage: migrate [up | down [n] | status]")
+	}
+	return nil
+}
@@ -1,6 +1,13 @@
 package models
 
 import (
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -26,21 +33,413 @@ type Client struct {
 	Email       string  `json:"email"`
 	Phone       string  `json:"phone"`
 	Notes       string  `json:"notes"`
+	Timezone    string  `gorm:"not null;default:'Asia/Bangkok'" json:"timezone"` // IANA zone the site's readings are taken in
+
+	StatusHeldUntil *time.Time `json:"statusHeldUntil"` // a status set by hand is not recomputed before this
 
 	// Relationships
 	UtilityData []UtilityData `gorm:"foreignKey:ClientID" json:"-"`
 }
 
-// UtilityData represents daily utility usage records
+// UtilityData represents a utility reading taken at a point in time. A site
+// may have several readings a day; daily views roll them up by Date.
 type UtilityData struct {
 	gorm.Model
-	ClientID      uint    `gorm:"not null" json:"clientId"`
-	Date          string  `gorm:"not null" json:"date"` // YYYY-MM-DD format
-	WaterUsage    float64 `json:"waterUsage"`           // in cubic meters
-	PacUsage      float64 `json:"pacUsage"`             // in kg
-	PolymerUsage  float64 `json:"polymerUsage"`         // in kg
-	ChlorineUsage float64 `json:"chlorineUsage"`        // in kg
-	Notes         string  `json:"notes"`
+	ClientID         uint      `gorm:"not null;index:idx_utility_data_client_date,priority:1" json:"clientId"`
+	Date             Date      `gorm:"not null;index:idx_utility_data_client_date,priority:2" json:"date"` // local date of ReadAt
+	ReadAt           time.Time `gorm:"index" json:"readAt"`                                                // when the reading was taken
+	Timezone         string    `json:"timezone"`                                                           // IANA zone Date is derived in
+	Notes            string    `json:"notes"`
+	RecordedBy       *uint     `gorm:"index" json:"recordedBy"`                 // user who submitted the reading
+	Corrected        bool      `gorm:"not null;default:false" json:"corrected"` // values changed by an approved correction
+	Confirmed        bool      `gorm:"not null;default:false" json:"confirmed"` // submitted despite plausibility warnings
+	SuspiciousFields string    `json:"suspiciousFields"`                        // comma-separated fields flagged as unusual
+
+	// Relationships
+	Values   []ReadingValue `gorm:"foreignKey:UtilityDataID" json:"values"`
+	Recorder *User          `gorm:"foreignKey:RecordedBy" json:"-"`
+}
+
+// Value returns the reading's value for a metric, or 0 if it was not recorded
+func (d UtilityData) Value(code string) float64 {
+	v, _ := d.Lookup(code)
+	return v
+}
+
+// Lookup returns the reading's value for a metric and whether it was recorded
+func (d UtilityData) Lookup(code string) (float64, bool) {
+	for _, v := range d.Values {
+		if v.MetricCode == code {
+			return v.Value, true
+		}
+	}
+	return 0, false
+}
+
+// ValueMap returns the reading's values keyed by metric code
+func (d UtilityData) ValueMap() map[string]float64 {
+	values := make(map[string]float64, len(d.Values))
+	for _, v := range d.Values {
+		values[v.MetricCode] = v.Value
+	}
+	return values
+}
+
+// Metric is an entry in the catalog of quantities a reading can record
+type Metric struct {
+	gorm.Model
+	Code        string   `gorm:"size:64;uniqueIndex;not null" json:"code"` // stable identifier, e.g. water or electricity
+	Name        string   `gorm:"not null" json:"name"`
+	Unit        string   `gorm:"not null" json:"unit"`
+	Category    string   `gorm:"not null" json:"category"`                  // water, chemical, energy or effluent
+	Aggregation string   `gorm:"not null;default:'sum'" json:"aggregation"` // how readings combine over a period: sum or avg
+	Precision   int      `gorm:"not null" json:"precision"`                 // decimal places shown
+	SortOrder   int      `gorm:"not null" json:"sortOrder"`
+	Min         *float64 `json:"min"` // default plausible range, overridden by validation rules
+	Max         *float64 `json:"max"`
+	Density     *float64 `json:"density"`                // kg per litre of solution, for chemicals reported by volume
+	Active      bool     `gorm:"not null" json:"active"` // inactive metrics are hidden and cannot be submitted
+}
+
+// ReadingValue is the value of one catalog metric on a reading
+type ReadingValue struct {
+	ID            uint     `gorm:"primarykey" json:"-"`
+	UtilityDataID uint     `gorm:"not null;uniqueIndex:idx_reading_metric" json:"-"`
+	MetricCode    string   `gorm:"size:64;not null;uniqueIndex:idx_reading_metric;index" json:"metric"`
+	Value         float64  `gorm:"not null" json:"value"` // in Unit
+	Unit          string   `json:"unit"`                  // the metric's catalog unit when recorded
+	InputValue    *float64 `json:"inputValue,omitempty"`  // value as submitted, if in a different unit
+	InputUnit     string   `json:"inputUnit,omitempty"`
+	Score         *float64 `json:"score,omitempty"`    // robust z-score against the site's baseline; nil if not scored
+	Expected      *float64 `json:"expected,omitempty"` // the baseline's usual value for the reading's weekday
+
+	// Relationships
+	Metric *Metric `gorm:"foreignKey:MetricCode;references:Code" json:"-"`
+}
+
+// DailyRollup is the total of a metric over a site's readings on one local
+// date. Rollups are derived from the readings, kept up to date as readings are
+// written and can be rebuilt from them at any time.
+type DailyRollup struct {
+	ClientID   uint    `gorm:"primaryKey;autoIncrement:false" json:"clientId"`
+	Date       Date    `gorm:"primaryKey;index" json:"date"`
+	MetricCode string  `gorm:"primaryKey;size:64" json:"metric"`
+	Total      float64 `gorm:"not null" json:"total"`
+	Readings   int     `gorm:"not null" json:"readings"` // readings recording the metric
+}
+
+// MonthlyRollup is the total of a metric over a site's readings in one
+// calendar month, combined from its daily rollups
+type MonthlyRollup struct {
+	ClientID   uint    `gorm:"primaryKey;autoIncrement:false" json:"clientId"`
+	Month      Date    `gorm:"primaryKey;index" json:"month"` // first day of the month
+	MetricCode string  `gorm:"primaryKey;size:64" json:"metric"`
+	Total      float64 `gorm:"not null" json:"total"`
+	Readings   int     `gorm:"not null" json:"readings"`
+	Days       int     `gorm:"not null" json:"days"` // days with readings recording the metric
+}
+
+// Baseline is a site's learned usual value of a metric on one day of the
+// week: the median and median absolute deviation of its recent readings,
+// with the weekday's seasonal factor applied. Baselines are learned from the
+// readings and can be relearned at any time.
+type Baseline struct {
+	ClientID   uint      `gorm:"primaryKey;autoIncrement:false" json:"clientId"`
+	MetricCode string    `gorm:"primaryKey;size:64" json:"metric"`
+	Weekday    int       `gorm:"primaryKey;autoIncrement:false" json:"weekday"` // 0 is Sunday
+	Median     float64   `gorm:"not null" json:"median"`
+	MAD        float64   `gorm:"column:mad;not null" json:"mad"`
+	Factor     float64   `gorm:"not null" json:"factor"`  // the weekday's median relative to that of every day
+	Samples    int       `gorm:"not null" json:"samples"` // readings learned from, on every weekday
+	LearnedAt  time.Time `gorm:"not null" json:"learnedAt"`
+}
+
+// Alert represents an alert raised for a client site
+type Alert struct {
+	gorm.Model
+	ClientID      uint       `gorm:"not null;index" json:"clientId"`
+	Type          string     `gorm:"not null" json:"type"` // info, warning, danger
+	Title         string     `gorm:"not null" json:"title"`
+	Message       string     `json:"message"`
+	Status        string     `gorm:"not null;default:'open'" json:"status"` // open or resolved
+	ResolvedAt    *time.Time `json:"resolvedAt"`
+	UtilityDataID *uint      `gorm:"index" json:"utilityDataId"` // the unusual reading an alert was raised for
+}
+
+// DigestSubscription is a recipient of the daily usage digest for a client site
+type DigestSubscription struct {
+	gorm.Model
+	ClientID         uint       `gorm:"not null;index" json:"clientId"`
+	Email            string     `gorm:"not null" json:"email"`
+	Name             string     `json:"name"`
+	SendHour         int        `gorm:"not null;default:7" json:"sendHour"` // local hour of day (0-23)
+	Timezone         string     `gorm:"not null;default:'Asia/Bangkok'" json:"timezone"`
+	Active           bool       `gorm:"not null;default:true" json:"active"`
+	UnsubscribeToken string     `gorm:"uniqueIndex;not null" json:"-"`
+	LastSentAt       *time.Time `json:"lastSentAt"`
+	UnsubscribedAt   *time.Time `json:"unsubscribedAt"`
+	Failures         int        `gorm:"not null;default:0" json:"failures"` // failed sends since the last digest was sent
+	RetryAt          *time.Time `json:"retryAt"`                            // a failed digest is not retried before this
+
+	// Relationships
+	Client Client `gorm:"foreignKey:ClientID" json:"-"`
+}
+
+// EmailLog records every email the notifier attempted to send
+type EmailLog struct {
+	gorm.Model
+	SubscriptionID *uint     `gorm:"index" json:"subscriptionId"`
+	Recipient      string    `gorm:"not null" json:"recipient"`
+	Subject        string    `json:"subject"`
+	Kind           string    `gorm:"not null" json:"kind"`   // digest
+	Status         string    `gorm:"not null" json:"status"` // sent or failed
+	Error          string    `json:"error"`
+	SentAt         time.Time `json:"sentAt"`
+}
+
+// WebhookSubscription is an admin-configured endpoint that receives event notifications
+type WebhookSubscription struct {
+	gorm.Model
+	URL         string `gorm:"not null" json:"url"`
+	Secret      string `gorm:"not null" json:"-"`
+	Events      string `gorm:"not null" json:"events"` // comma-separated event names, or * for all
+	Description string `json:"description"`
+	Active      bool   `gorm:"not null;default:true" json:"active"`
+}
+
+// WebhookDelivery is a single signed event payload queued for a webhook subscription
+type WebhookDelivery struct {
+	gorm.Model
+	SubscriptionID uint       `gorm:"not null;index" json:"subscriptionId"`
+	Event          string     `gorm:"not null" json:"event"`
+	Payload        string     `gorm:"not null" json:"payload"`
+	Status         string     `gorm:"not null;default:'pending';index" json:"status"` // pending, delivered or dead
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"index" json:"nextAttemptAt"`
+	LastError      string     `json:"lastError"`
+	ResponseStatus int        `json:"responseStatus"`
+	DeliveredAt    *time.Time `json:"deliveredAt"`
+}
+
+// ReadingValues holds the editable values of a reading; nil fields and
+// metrics missing from Values are left unchanged
+type ReadingValues struct {
+	Date   *Date              `json:"date,omitempty"`
+	ReadAt *time.Time         `json:"readAt,omitempty"`
+	Values map[string]float64 `json:"values,omitempty"` // keyed by metric code, in catalog units
+	Inputs map[string]Input   `json:"inputs,omitempty"` // values of Values entered in another unit
+	Notes  *string            `json:"notes,omitempty"`
+
+	// Values of corrections recorded before the metric catalog
+	WaterUsage    *float64 `json:"waterUsage,omitempty"`
+	PacUsage      *float64 `json:"pacUsage,omitempty"`
+	PolymerUsage  *float64 `json:"polymerUsage,omitempty"`
+	ChlorineUsage *float64 `json:"chlorineUsage,omitempty"`
+}
+
+// Input is a value as it was entered, in a unit other than its metric's catalog unit
+type Input struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// ReadingCorrection is a requested edit or void of a reading that takes effect
+// only once an admin approves it
+type ReadingCorrection struct {
+	gorm.Model
+	UtilityDataID uint       `gorm:"not null;index" json:"utilityDataId"`
+	Kind          string     `gorm:"not null" json:"kind"`                           // edit or void
+	Status        string     `gorm:"not null;default:'pending';index" json:"status"` // pending, approved or rejected
+	Reason        string     `json:"reason"`
+	Original      JSONText   `json:"original"` // ReadingValues at the time of the request
+	Proposed      JSONText   `json:"proposed"` // ReadingValues to apply for an edit
+	RequestedBy   *uint      `gorm:"index" json:"requestedBy"`
+	ReviewedBy    *uint      `json:"reviewedBy"`
+	ReviewedAt    *time.Time `json:"reviewedAt"`
+	ReviewNote    string     `json:"reviewNote"`
+
+	// Relationships
+	UtilityData UtilityData `gorm:"foreignKey:UtilityDataID" json:"-"`
+}
+
+// ValidationRule overrides the plausible range of a metric for every site or for one site
+type ValidationRule struct {
+	gorm.Model
+	ClientID *uint    `gorm:"index" json:"clientId"`  // nil applies to every site
+	Metric   string   `gorm:"not null" json:"metric"` // water, pac, polymer or chlorine
+	Min      *float64 `json:"min"`
+	Max      *float64 `json:"max"`
+}
+
+// JSONText is a JSON document stored as text and emitted as raw JSON
+type JSONText string
+
+// MarshalJSON emits the stored document without re-encoding it as a string
+func (j JSONText) MarshalJSON() ([]byte, error) {
+	if j == "" {
+		return []byte("null"), nil
+	}
+	return []byte(j), nil
+}
+
+// DateLayout is the format dates are written in
+const DateLayout = "2006-01-02"
+
+// Date is a calendar date without a time of day. It is stored in a DATE
+// column and written as YYYY-MM-DD in JSON.
+type Date struct {
+	time.Time // midnight UTC of the date
+}
+
+// NewDate returns the date for a year, month and day
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// DateOf returns the date of t in t's location
+func DateOf(t time.Time) Date {
+	return NewDate(t.Year(), t.Month(), t.Day())
+}
+
+// ParseDate strictly parses a YYYY-MM-DD date
+func ParseDate(value string) (Date, error) {
+	t, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return Date{}, err
+	}
+	// Reject values that parse but are not in canonical form
+	if t.Format(DateLayout) != value {
+		return Date{}, fmt.Errorf("date %q is not in YYYY-MM-DD format", value)
+	}
+	return Date{t}, nil
+}
+
+// String returns the date as YYYY-MM-DD
+func (d Date) String() string {
+	return d.Format(DateLayout)
+}
+
+// In returns the start of the date in loc
+func (d Date) In(loc *time.Location) time.Time {
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, loc)
+}
+
+// AddDays returns the date n days later, or earlier for negative n
+func (d Date) AddDays(n int) Date {
+	return Date{d.AddDate(0, 0, n)}
+}
+
+// MarshalJSON writes the date as "YYYY-MM-DD", or null for the zero date
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+// UnmarshalJSON reads a "YYYY-MM-DD" date
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*d = Date{}
+		return nil
+	}
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		return fmt.Errorf("date must be a YYYY-MM-DD string")
+	}
+	parsed, err := ParseDate(string(data[1 : len(data)-1]))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// GormDataType stores dates in a DATE column
+func (Date) GormDataType() string {
+	return "date"
+}
+
+// Value writes the date as YYYY-MM-DD, which both SQLite and PostgreSQL
+// compare correctly
+func (d Date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Scan reads a date from a DATE column or a YYYY-MM-DD string
+func (d *Date) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case time.Time:
+		*d = DateOf(v)
+		return nil
+	case string:
+		return d.scanString(v)
+	case []byte:
+		return d.scanString(string(v))
+	case nil:
+		*d = Date{}
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into a date", value)
+}
+
+func (d *Date) scanString(value string) error {
+	// Drivers may return a DATE as a timestamp at midnight
+	if len(value) > len(DateLayout) {
+		value = value[:len(DateLayout)]
+	}
+	parsed, err := ParseDate(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// ErrAuditImmutable is returned when an audit entry is updated or deleted
+var ErrAuditImmutable = errors.New("audit entries are immutable")
+
+// AuditEntry is an immutable record of a data-changing request
+type AuditEntry struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time `gorm:"index" json:"createdAt"`
+	UserID     *uint     `gorm:"index" json:"userId"`
+	Username   string    `gorm:"index" json:"username"`
+	Role       string    `json:"role"`
+	IP         string    `json:"ip"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"` // HTTP response status
+	Action     string    `gorm:"not null;index" json:"action"`
+	EntityType string    `gorm:"index" json:"entityType"`
+	EntityID   *uint     `gorm:"index" json:"entityId"`
+	Before     JSONText  `json:"before"`
+	After      JSONText  `json:"after"`
+	Diff       JSONText  `json:"diff"` // changed fields as {"field": {"from": ..., "to": ...}}
+}
+
+// BeforeUpdate prevents audit entries from being modified
+func (AuditEntry) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditImmutable
+}
+
+// BeforeDelete prevents audit entries from being removed
+func (AuditEntry) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditImmutable
+}
+
+// JobRun records one run of a scheduled job, whether on its schedule or
+// triggered by an admin
+type JobRun struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Job         string     `gorm:"not null;uniqueIndex:idx_job_runs_job_scheduled_at;index" json:"job"`
+	Trigger     string     `gorm:"not null" json:"trigger"`                                      // schedule or manual
+	ScheduledAt *time.Time `gorm:"uniqueIndex:idx_job_runs_job_scheduled_at" json:"scheduledAt"` // the slot a scheduled run fills; nil for manual runs
+	TriggeredBy string     `json:"triggeredBy"`                                                  // username of a manual run
+	Instance    string     `json:"instance"`                                                     // host that ran the job
+	Status      string     `gorm:"not null;index" json:"status"`                                 // running, succeeded or failed
+	Error       string     `json:"error"`
+	StartedAt   time.Time  `gorm:"not null;index" json:"startedAt"`
+	FinishedAt  *time.Time `json:"finishedAt"`
 }
 
 // HashPassword applies password hashing (simplified for demo)
@@ -56,6 +455,15 @@ func (u *User) CheckPassword(password string) bool {
 	return u.Password == password
 }
 
+// GeneratePassword returns a random password for an account created without one
+func GeneratePassword() (string, error) {
+	b := make([]byte, 9)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // ClientWithStats extends Client with usage statistics
 type ClientWithStats struct {
 	Client
@@ -71,7 +479,7 @@ type DashboardData struct {
 		TotalWaterUsage    float64 `json:"totalWaterUsage"`
 		WaterChange        float64 `json:"waterChange"`
 		TotalPacUsage      float64 `json:"totalPacUsage"`
-		AvgPacUsage        float64 `json:"avgPacUsage"`
+		AvgPacUsage        float64 `json:"avgPacUsage"` // mean total of the complete calendar months, as are the other averages
 		TotalPolymerUsage  float64 `json:"totalPolymerUsage"`
 		AvgPolymerUsage    float64 `json:"avgPolymerUsage"`
 		TotalChlorineUsage float64 `json:"totalChlorineUsage"`
@@ -87,12 +495,16 @@ type DashboardData struct {
 		Polymer  []float64 `json:"polymer"`
 		Chlorine []float64 `json:"chlorine"`
 	} `json:"chemicalUsage"`
-	Alerts []struct {
-		Type    string `json:"type"`
-		Title   string `json:"title"`
-		Message string `json:"message"`
-		Date    string `json:"date"`
-	} `json:"alerts"`
+	Metrics []MetricSeries   `json:"metrics"` // every active catalog metric
+	Alerts  []DashboardAlert `json:"alerts"`
+}
+
+// DashboardAlert is an open alert shown on the dashboard
+type DashboardAlert struct {
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	Date    string `json:"date"`
 }
 
 // MapData represents the data structure for the map API
@@ -109,13 +521,65 @@ type MapData struct {
 
 // MapSite represents a site on the map
 type MapSite struct {
-	ID            uint     `json:"id"`
-	Name          string   `json:"name"`
-	PlotNumber    string   `json:"plotNumber"`
-	Latitude      float64  `json:"latitude"`
-	Longitude     float64  `json:"longitude"`
-	Status        string   `json:"status"`
-	UsageHistory  []Usage  `json:"usageHistory"`
+	ID           uint    `json:"id"`
+	Name         string  `json:"name"`
+	PlotNumber   string  `json:"plotNumber"`
+	Latitude     float64 `json:"latitude"`
+	Longitude    float64 `json:"longitude"`
+	Status       string  `json:"status"`
+	UsageHistory []Usage `json:"usageHistory"`
+}
+
+// TableRow represents a reading in the client detail table
+type TableRow struct {
+	ID                uint               `json:"id"`
+	Date              string             `json:"date"`
+	ReadAt            time.Time          `json:"readAt"`
+	Water             float64            `json:"water"`
+	Pac               float64            `json:"pac"`
+	Polymer           float64            `json:"polymer"`
+	Chlorine          float64            `json:"chlorine"`
+	Corrected         bool               `json:"corrected"`         // values were changed by an approved correction
+	PendingCorrection bool               `json:"pendingCorrection"` // an edit or void awaits approval
+	SuspiciousFields  string             `json:"suspiciousFields"`  // fields confirmed despite plausibility warnings
+	Values            map[string]float64 `json:"values"`            // every recorded metric, keyed by code
+}
+
+// ReadingRow is a reading in a site's reading history
+type ReadingRow struct {
+	ID                uint               `json:"id"`
+	Date              Date               `json:"date"`
+	ReadAt            time.Time          `json:"readAt"`
+	Timezone          string             `json:"timezone"`
+	Notes             string             `json:"notes"`
+	Corrected         bool               `json:"corrected"`
+	PendingCorrection bool               `json:"pendingCorrection"`
+	SuspiciousFields  string             `json:"suspiciousFields"`
+	Values            map[string]float64 `json:"values"` // the selected metrics recorded, keyed by code
+	Scores            map[string]float64 `json:"scores"` // anomaly scores of the values that were scored
+}
+
+// MetricSeries is a catalog metric's summary and chart data in a dashboard or client response
+type MetricSeries struct {
+	Code        string        `json:"code"`
+	Name        string        `json:"name"`
+	Unit        string        `json:"unit"`
+	Category    string        `json:"category"`
+	Aggregation string        `json:"aggregation"`
+	Precision   int           `json:"precision"`
+	Total       float64       `json:"total"`   // sum of all readings, for summed metrics
+	Average     float64       `json:"average"` // mean of the charted days that recorded the metric
+	Labels      []string      `json:"labels"`
+	Data        []float64     `json:"data"`    // one value per day, 0 where the metric was not recorded
+	Monthly     *MonthlyUsage `json:"monthly"` // calendar-month usage, for summed metrics
+}
+
+// MonthlyUsage summarizes a summed metric by calendar month
+type MonthlyUsage struct {
+	Average           float64 `json:"average"`           // mean total of the complete months
+	CompleteMonths    int     `json:"completeMonths"`    // how many months the average is over
+	MonthToDate       float64 `json:"monthToDate"`       // total of the current month so far
+	ProjectedMonthEnd float64 `json:"projectedMonthEnd"` // month to date extrapolated to the whole month
 }
 
 // Usage represents a single usage data point
@@ -138,45 +602,46 @@ type ClientDetailResponse struct {
 	Phone         string `json:"phone"`
 	ContractStart string `json:"contractStart"`
 	ContractEnd   string `json:"contractEnd"`
-	
+
 	Summary struct {
-		WaterMonthlyAvg    float64 `json:"waterMonthlyAvg"`
-		ChemicalMonthlyAvg float64 `json:"chemicalMonthlyAvg"`
-		LastInspection     string  `json:"lastInspection"`
-		NextInspection     string  `json:"nextInspection"`
+		WaterMonthlyAvg           float64 `json:"waterMonthlyAvg"` // mean total of the complete calendar months
+		WaterMonthToDate          float64 `json:"waterMonthToDate"`
+		WaterProjectedMonthEnd    float64 `json:"waterProjectedMonthEnd"`
+		ChemicalMonthlyAvg        float64 `json:"chemicalMonthlyAvg"` // of all summed chemicals in kg, leaving out those measured by volume without a density
+		ChemicalMonthToDate       float64 `json:"chemicalMonthToDate"`
+		ChemicalProjectedMonthEnd float64 `json:"chemicalProjectedMonthEnd"`
+		CompleteMonths            int     `json:"completeMonths"` // months the water average is over
+		LastInspection            string  `json:"lastInspection"`
+		NextInspection            string  `json:"nextInspection"`
 	} `json:"summary"`
-	
+
 	WaterUsage struct {
 		Labels []string  `json:"labels"`
 		Data   []float64 `json:"data"`
 	} `json:"waterUsage"`
-	
+
 	ChemicalUsage struct {
 		Labels   []string  `json:"labels"`
 		Pac      []float64 `json:"pac"`
 		Polymer  []float64 `json:"polymer"`
 		Chlorine []float64 `json:"chlorine"`
 	} `json:"chemicalUsage"`
-	
+
 	Notes []struct {
 		Type    string `json:"type"`
 		Title   string `json:"title"`
 		Message string `json:"message"`
 		Date    string `json:"date"`
 	} `json:"notes"`
-	
-	TableData []struct {
-		Date     string  `json:"date"`
-		Water    float64 `json:"water"`
-		Pac      float64 `json:"pac"`
-		Polymer  float64 `json:"polymer"`
-		Chlorine float64 `json:"chlorine"`
-	} `json:"tableData"`
-	
+
+	Metrics []MetricSeries `json:"metrics"` // every active catalog metric
+
+	TableData []TableRow `json:"tableData"`
+
 	Documents []struct {
 		Name        string `json:"name"`
 		Description string `json:"description"`
 		DateAdded   string `json:"dateAdded"`
 		FileSize    string `json:"fileSize"`
 	} `json:"documents"`
-} 
\ No newline at end of file
+}
@@ -0,0 +1,70 @@
+package monitoring
+
+import (
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+
+	"utility-backend/repository"
+)
+
+var (
+	alertsOpenDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "alerts", "open"),
+		"Open alerts across all sites, by type.",
+		[]string{"type"}, nil,
+	)
+	workerHeartbeatDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "worker", "last_heartbeat_timestamp_seconds"),
+		"Unix time a background worker last reported being alive.",
+		[]string{"worker"}, nil,
+	)
+)
+
+// alertCollector counts the open alerts in the database on every scrape
+type alertCollector struct {
+	alerts *repository.Alerts
+}
+
+func newAlertCollector(db *gorm.DB) alertCollector {
+	return alertCollector{alerts: repository.NewAlerts(db)}
+}
+
+// Describe implements prometheus.Collector
+func (a alertCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- alertsOpenDesc
+}
+
+// Collect implements prometheus.Collector
+func (a alertCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := a.alerts.CountOpenByType()
+	if err != nil {
+		slog.Error("Failed to count open alerts for metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(alertsOpenDesc, err)
+		return
+	}
+	for alertType, n := range counts {
+		ch <- prometheus.MustNewConstMetric(alertsOpenDesc, prometheus.GaugeValue, float64(n), alertType)
+	}
+}
+
+// workerCollector reports the last heartbeat of every background worker
+type workerCollector struct {
+	m *Monitor
+}
+
+// Describe implements prometheus.Collector
+func (w workerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- workerHeartbeatDesc
+}
+
+// Collect implements prometheus.Collector
+func (w workerCollector) Collect(ch chan<- prometheus.Metric) {
+	w.m.mu.Lock()
+	defer w.m.mu.Unlock()
+	for _, hb := range w.m.workers {
+		ch <- prometheus.MustNewConstMetric(workerHeartbeatDesc, prometheus.GaugeValue,
+			float64(hb.Last().UnixNano())/1e9, hb.name)
+	}
+}
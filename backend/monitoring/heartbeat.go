@@ -0,0 +1,28 @@
+package monitoring
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Heartbeat is beaten by a background worker on every pass so that
+// readiness checks can tell a stuck or stopped worker
+type Heartbeat struct {
+	name       string
+	maxSilence time.Duration
+	last       atomic.Int64 // Unix nanoseconds
+}
+
+// Beat records that the worker is alive. It does nothing on a nil heartbeat,
+// so workers can run without a monitor.
+func (hb *Heartbeat) Beat() {
+	if hb == nil {
+		return
+	}
+	hb.last.Store(time.Now().UnixNano())
+}
+
+// Last returns when the worker last beat
+func (hb *Heartbeat) Last() time.Time {
+	return time.Unix(0, hb.last.Load())
+}
@@ -0,0 +1,147 @@
+// Package monitoring exposes Prometheus metrics of the server and tracks
+// whether its background workers are still running.
+package monitoring
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+	"gorm.io/gorm"
+)
+
+// namespace prefixes the application's metric names
+const namespace = "utility"
+
+// Monitor holds the metrics registry and the heartbeats of background workers
+type Monitor struct {
+	registry         *prometheus.Registry
+	requestDuration  *prometheus.HistogramVec
+	readingsIngested prometheus.Counter
+
+	mu      sync.Mutex
+	workers map[string]*Heartbeat
+}
+
+// New returns a monitor exporting the Go runtime, the connection pool of db
+// and the open alerts stored in it
+func New(db *gorm.DB) (*Monitor, error) {
+	m := &Monitor{
+		registry: prometheus.NewRegistry(),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to serve HTTP requests, by route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		readingsIngested: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "readings_ingested_total",
+			Help:      "Readings submitted to this server.",
+		}),
+		workers: map[string]*Heartbeat{},
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(sqlDB, db.Dialector.Name()),
+		m.requestDuration,
+		m.readingsIngested,
+		newAlertCollector(db),
+		workerCollector{m},
+	)
+	return m, nil
+}
+
+// Middleware records the duration of every request. Requests are labelled
+// with their route pattern rather than their path, so IDs in paths do not
+// create a series each.
+func (m *Monitor) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+		} else if err != nil {
+			status = fiber.StatusInternalServerError
+		}
+		// Fiber reuses the buffer behind c.Method, so the label is copied
+		m.requestDuration.
+			WithLabelValues(utils.CopyString(c.Method()), c.Route().Path, strconv.Itoa(status)).
+			Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// Handler serves the metrics in the Prometheus text format. When token is
+// set, scrapers must send it as a bearer token.
+func (m *Monitor) Handler(token string) fiber.Handler {
+	serve := fasthttpadaptor.NewFastHTTPHandler(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	return func(c *fiber.Ctx) error {
+		if token != "" {
+			given := []byte(c.Get(fiber.HeaderAuthorization))
+			if subtle.ConstantTimeCompare(given, []byte("Bearer "+token)) != 1 {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"success": false,
+					"message": "Missing or invalid metrics token",
+				})
+			}
+		}
+		serve(c.Context())
+		return nil
+	}
+}
+
+// ReadingsIngested counts n newly stored readings
+func (m *Monitor) ReadingsIngested(n int) {
+	if m == nil {
+		return
+	}
+	m.readingsIngested.Add(float64(n))
+}
+
+// Worker registers a background worker that is considered stuck when it
+// has not beaten its heartbeat for longer than maxSilence
+func (m *Monitor) Worker(name string, maxSilence time.Duration) *Heartbeat {
+	hb := &Heartbeat{name: name, maxSilence: maxSilence}
+	hb.Beat()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.workers[name] = hb
+	return hb
+}
+
+// StaleWorkers describes the registered workers that have gone silent, in name order
+func (m *Monitor) StaleWorkers(now time.Time) []string {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var stale []string
+	for _, hb := range m.workers {
+		if silence := now.Sub(hb.Last()); silence > hb.maxSilence {
+			stale = append(stale, fmt.Sprintf("%s not seen for %s", hb.name, silence.Round(time.Second)))
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
@@ -0,0 +1,321 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"math"
+	"net/mail"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/config"
+	"utility-backend/logging"
+	"utility-backend/metrics"
+	"utility-backend/models"
+	"utility-backend/repository"
+)
+
+//go:embed templates/*
+var templateFS embed.FS
+
+var (
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html"))
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt"))
+)
+
+// baselineDays is the number of days before the report day used as the usage baseline
+const baselineDays = 30
+
+const (
+	// retryBackoff is the delay before retrying a failed digest; it doubles on every failure
+	retryBackoff = 5 * time.Minute
+	// maxRetryBackoff caps the delay between retries
+	maxRetryBackoff = time.Hour
+)
+
+// ErrMailerNotConfigured is returned when sending is attempted without SMTP settings
+var ErrMailerNotConfigured = errors.New("SMTP is not configured")
+
+var log = logging.For("notifications")
+
+// Sender emails digests through Mailer, linking back to the app at BaseURL
+type Sender struct {
+	Mailer  Mailer // nil when email is not configured
+	BaseURL string // without a trailing slash
+}
+
+// NewSender returns a sender mailing through the SMTP settings, or with no
+// mailer when they are not set
+func NewSender(smtp config.SMTP, appBaseURL string) *Sender {
+	s := &Sender{BaseURL: strings.TrimRight(appBaseURL, "/")}
+	if m := NewSMTPMailer(smtp); m != nil {
+		s.Mailer = m
+	}
+	return s
+}
+
+// Enabled reports whether email delivery is enabled
+func (s *Sender) Enabled() bool {
+	return s.Mailer != nil
+}
+
+// MetricLine compares a metric's usage on the report day with its baseline
+type MetricLine struct {
+	Name      string
+	Unit      string
+	Precision int // decimal places shown
+	Usage     float64
+	Baseline  float64
+	Change    float64 // percentage change versus baseline
+	HasUsage  bool
+	HasChange bool
+}
+
+// DigestData is the template data for a daily digest email
+type DigestData struct {
+	RecipientName  string
+	ClientName     string
+	PlotNumber     string
+	ReportDate     string
+	Metrics        []MetricLine
+	Alerts         []models.Alert
+	UnsubscribeURL string
+}
+
+// BuildDigest collects the usage and alert data for a subscription's report day
+func (s *Sender) BuildDigest(db *gorm.DB, sub models.DigestSubscription, client models.Client, reportDay time.Time) (DigestData, error) {
+	day := models.DateOf(reportDay)
+	baselineStart := day.AddDays(-baselineDays)
+	repos := repository.New(db)
+
+	data := DigestData{
+		RecipientName:  sub.Name,
+		ClientName:     client.Name,
+		PlotNumber:     client.PlotNumber,
+		ReportDate:     reportDay.Format("Mon, 2 Jan 2006"),
+		UnsubscribeURL: fmt.Sprintf("%s/api/unsubscribe/%s", s.BaseURL, sub.UnsubscribeToken),
+	}
+	if data.RecipientName == "" {
+		data.RecipientName = client.ContactName
+	}
+
+	// Usage on the report day
+	usage, err := repos.Readings.InRange(client.ID, day, day.AddDays(1))
+	if err != nil {
+		return data, err
+	}
+
+	// Baseline readings from the preceding days
+	history, err := repos.Readings.InRange(client.ID, baselineStart, day)
+	if err != nil {
+		return data, err
+	}
+
+	catalog, err := metrics.Catalog(db)
+	if err != nil {
+		return data, err
+	}
+
+	for _, m := range catalog {
+		line := MetricLine{Name: m.Name, Unit: m.Unit, Precision: m.Precision}
+
+		var today []float64
+		for _, d := range usage {
+			if v, ok := d.Lookup(m.Code); ok {
+				today = append(today, v)
+			}
+		}
+		line.HasUsage = len(today) > 0
+		line.Usage = metrics.Aggregate(m, today)
+
+		// Baseline is the average daily value over the days that have readings
+		days := map[string][]float64{}
+		for _, d := range history {
+			if v, ok := d.Lookup(m.Code); ok {
+				days[d.Date.String()] = append(days[d.Date.String()], v)
+			}
+		}
+		for _, values := range days {
+			line.Baseline += metrics.Aggregate(m, values)
+		}
+		if len(days) > 0 {
+			line.Baseline /= float64(len(days))
+		}
+
+		// Metrics the site does not report are left out
+		if !line.HasUsage && len(days) == 0 {
+			continue
+		}
+
+		if line.HasUsage && line.Baseline > 0 {
+			line.Change = math.Round((line.Usage-line.Baseline)/line.Baseline*1000) / 10
+			line.HasChange = true
+		}
+		data.Metrics = append(data.Metrics, line)
+	}
+
+	// Open alerts for the site
+	data.Alerts, err = repos.Alerts.List(client.ID, repository.AlertOpen)
+	if err != nil {
+		return data, err
+	}
+
+	return data, nil
+}
+
+// RenderDigest renders the subject, plaintext and HTML bodies of a digest
+func RenderDigest(data DigestData) (Message, error) {
+	msg := Message{
+		Subject:        fmt.Sprintf("Daily usage digest: %s (%s)", data.ClientName, data.ReportDate),
+		UnsubscribeURL: data.UnsubscribeURL,
+	}
+
+	var text bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&text, "digest.txt", data); err != nil {
+		return msg, err
+	}
+	msg.Text = text.String()
+
+	var html bytes.Buffer
+	if err := htmlTemplates.ExecuteTemplate(&html, "digest.html", data); err != nil {
+		return msg, err
+	}
+	msg.HTML = html.String()
+
+	return msg, nil
+}
+
+// SendDigest builds and sends the digest for a subscription and records the attempt
+// in the send log. The report day is the day before now in the recipient's timezone.
+func (s *Sender) SendDigest(db *gorm.DB, sub *models.DigestSubscription, now time.Time) error {
+	if s.Mailer == nil {
+		return ErrMailerNotConfigured
+	}
+
+	loc, err := time.LoadLocation(sub.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", sub.Timezone, err)
+	}
+	local := now.In(loc)
+	reportDay := time.Date(local.Year(), local.Month(), local.Day()-1, 0, 0, 0, 0, loc)
+
+	client, err := repository.NewClients(db).Find(sub.ClientID)
+	if err != nil {
+		return err
+	}
+
+	data, err := s.BuildDigest(db, *sub, client, reportDay)
+	if err != nil {
+		return err
+	}
+
+	msg, err := RenderDigest(data)
+	if err != nil {
+		return err
+	}
+	msg.To = (&mail.Address{Name: sub.Name, Address: sub.Email}).String()
+
+	entry := models.EmailLog{
+		SubscriptionID: &sub.ID,
+		Recipient:      sub.Email,
+		Subject:        msg.Subject,
+		Kind:           "digest",
+		Status:         "sent",
+		SentAt:         now,
+	}
+
+	sendErr := s.Mailer.Send(msg)
+	if sendErr != nil {
+		entry.Status = "failed"
+		entry.Error = sendErr.Error()
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		log.Error("Failed to write email log", "email", sub.Email, "error", err)
+	}
+	if sendErr != nil {
+		// Retried later rather than on every run while the server is down
+		sub.Failures++
+		retryAt := now.Add(retryDelay(sub.Failures))
+		sub.RetryAt = &retryAt
+		if err := db.Model(sub).Select("failures", "retry_at").Updates(sub).Error; err != nil {
+			log.Error("Failed to record digest failure", "email", sub.Email, "error", err)
+		}
+		return sendErr
+	}
+
+	sub.LastSentAt = &now
+	sub.Failures = 0
+	sub.RetryAt = nil
+	return db.Model(sub).Select("last_sent_at", "failures", "retry_at").Updates(sub).Error
+}
+
+// retryDelay returns the delay before retrying a digest after the given number of failures
+func retryDelay(failures int) time.Duration {
+	delay := retryBackoff
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if delay >= maxRetryBackoff {
+			return maxRetryBackoff
+		}
+	}
+	return delay
+}
+
+// isDue reports whether a subscription should receive its digest at the given time
+func isDue(sub models.DigestSubscription, now time.Time) bool {
+	loc, err := time.LoadLocation(sub.Timezone)
+	if err != nil {
+		return false
+	}
+	local := now.In(loc)
+	if local.Hour() < sub.SendHour {
+		return false
+	}
+	if sub.RetryAt != nil && now.Before(*sub.RetryAt) {
+		return false
+	}
+	if sub.LastSentAt == nil {
+		return true
+	}
+
+	// At most one digest per local calendar day
+	last := sub.LastSentAt.In(loc)
+	return last.Format("2006-01-02") != local.Format("2006-01-02")
+}
+
+// SendDueDigests sends digests to every active subscription whose send hour
+// has passed. It stops early once ctx is done; the remaining digests are
+// sent on the next run. Digests that fail are logged and counted in the
+// returned error, the others are still sent. A failed digest is retried
+// after 5 minutes, doubling with every failure up to an hour.
+func (s *Sender) SendDueDigests(ctx context.Context, db *gorm.DB, now time.Time) error {
+	var subs []models.DigestSubscription
+	if err := db.Where("active = ?", true).Find(&subs).Error; err != nil {
+		return fmt.Errorf("loading digest subscriptions: %w", err)
+	}
+
+	failed := 0
+	for i := range subs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !isDue(subs[i], now) {
+			continue
+		}
+		if err := s.SendDigest(db, &subs[i], now); err != nil {
+			log.Error("Failed to send digest", "email", subs[i].Email, "error", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d digests failed to send", failed)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package notifications
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"utility-backend/config"
+)
+
+// Message is a single email with plaintext and HTML alternatives
+type Message struct {
+	To      string // an address, optionally with a display name
+	Subject string
+	Text    string
+	HTML    string
+
+	// UnsubscribeURL is offered in the List-Unsubscribe headers, for
+	// one-click unsubscribing with a POST (RFC 8058), when set
+	UnsubscribeURL string
+}
+
+// errHeaderBreak is returned for header values containing line breaks,
+// which would start new headers
+var errHeaderBreak = errors.New("header value contains a line break")
+
+// Mailer delivers email messages
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTPMailer sends email through an SMTP server
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// NewSMTPMailer builds an SMTPMailer from the SMTP settings.
+// It returns nil when no host is configured.
+func NewSMTPMailer(cfg config.SMTP) *SMTPMailer {
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	return &SMTPMailer{
+		Host:     cfg.Host,
+		Port:     strconv.Itoa(cfg.Port),
+		Username: cfg.Username,
+		Password: cfg.Password,
+		From:     cfg.From,
+	}
+}
+
+// Send delivers the message as a multipart/alternative email
+func (m *SMTPMailer) Send(msg Message) error {
+	from, err := parseAddress("From", m.From)
+	if err != nil {
+		return err
+	}
+	to, err := parseAddress("To", msg.To)
+	if err != nil {
+		return err
+	}
+	body, err := buildMIME(from, to, msg)
+	if err != nil {
+		return err
+	}
+
+	// Only authenticate when credentials are configured; local sinks accept anonymous mail
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	return smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, from.Address, []string{to.Address}, body)
+}
+
+// parseAddress parses the address of a header, refusing line breaks
+func parseAddress(header, value string) (*mail.Address, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return nil, fmt.Errorf("%s: %w", header, errHeaderBreak)
+	}
+	addr, err := mail.ParseAddress(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", header, err)
+	}
+	return addr, nil
+}
+
+// buildMIME renders the message headers and both body parts. The subject
+// is encoded as UTF-8, so that site names in any script arrive intact.
+func buildMIME(from, to *mail.Address, msg Message) ([]byte, error) {
+	if strings.ContainsAny(msg.Subject+msg.UnsubscribeURL, "\r\n") {
+		return nil, errHeaderBreak
+	}
+	boundary, err := randomToken(12)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	if msg.UnsubscribeURL != "" {
+		fmt.Fprintf(&buf, "List-Unsubscribe: <%s>\r\n", msg.UnsubscribeURL)
+		buf.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=UTF-8", msg.Text},
+		{"text/html; charset=UTF-8", msg.HTML},
+	}
+
+	for _, part := range parts {
+		if part.body == "" {
+			continue
+		}
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		fmt.Fprintf(&buf, "Content-Type: %s\r\n", part.contentType)
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+		w := quotedprintable.NewWriter(&buf)
+		if _, err := w.Write([]byte(strings.ReplaceAll(part.body, "\n", "\r\n"))); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	return buf.Bytes(), nil
+}
+
+// randomToken returns n random bytes encoded as hex
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewUnsubscribeToken generates a token for a digest unsubscribe link
+func NewUnsubscribeToken() (string, error) {
+	return randomToken(24)
+}
@@ -0,0 +1,157 @@
+package notifications
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// sinkMessage is a message received by the SMTP sink
+type sinkMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// startSink runs an SMTP server on a local port accepting any mail, and
+// returns its address and a channel receiving each message
+func startSink(t *testing.T) (host, port string, messages <-chan sinkMessage) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan sinkMessage, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSink(conn, received)
+		}
+	}()
+
+	host, port, _ = net.SplitHostPort(ln.Addr().String())
+	return host, port, received
+}
+
+// serveSink answers one SMTP session
+func serveSink(conn net.Conn, received chan<- sinkMessage) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 sink ready")
+	var msg sinkMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 sink")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			msg = sinkMessage{from: strings.Trim(strings.TrimSpace(line)[10:], "<>")}
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			msg.to = append(msg.to, strings.Trim(strings.TrimSpace(line)[8:], "<>"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			msg.data = data.String()
+			received <- msg
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPMailerSendsToSink(t *testing.T) {
+	host, port, messages := startSink(t)
+	m := &SMTPMailer{Host: host, Port: port, From: "Utility Monitor <digest@example.com>"}
+
+	msg := Message{
+		To:             (&mail.Address{Name: "สมชาย", Address: "somchai@example.com"}).String(),
+		Subject:        "Daily usage digest: โรงงานบางนา (Mon, 5 Jan 2026)",
+		Text:           "Water: 120 m³",
+		HTML:           "<p>Water: 120 m³</p>",
+		UnsubscribeURL: "https://utility.example.com/api/unsubscribe/abc",
+	}
+	if err := m.Send(msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	got := <-messages
+	if got.from != "digest@example.com" {
+		t.Errorf("envelope sender = %q, want the bare address", got.from)
+	}
+	if len(got.to) != 1 || got.to[0] != "somchai@example.com" {
+		t.Errorf("envelope recipients = %q, want the bare address", got.to)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(got.data))
+	if err != nil {
+		t.Fatalf("reading message: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("decoding subject: %v", err)
+	}
+	if subject != msg.Subject {
+		t.Errorf("subject = %q, want %q", subject, msg.Subject)
+	}
+	to, err := parsed.Header.AddressList("To")
+	if err != nil || len(to) != 1 || to[0].Name != "สมชาย" || to[0].Address != "somchai@example.com" {
+		t.Errorf("To = %v (%v), want สมชาย <somchai@example.com>", to, err)
+	}
+	if got := parsed.Header.Get("List-Unsubscribe"); got != "<"+msg.UnsubscribeURL+">" {
+		t.Errorf("List-Unsubscribe = %q", got)
+	}
+	if got := parsed.Header.Get("List-Unsubscribe-Post"); got != "List-Unsubscribe=One-Click" {
+		t.Errorf("List-Unsubscribe-Post = %q", got)
+	}
+}
+
+func TestSMTPMailerRejectsHeaderInjection(t *testing.T) {
+	host, port, messages := startSink(t)
+	m := &SMTPMailer{Host: host, Port: port, From: "digest@example.com"}
+
+	for name, msg := range map[string]Message{
+		"subject": {To: "ops@example.com", Subject: "Digest: Site\r\nBcc: victim@example.com", Text: "x"},
+		"to":      {To: "ops@example.com\r\nBcc: victim@example.com", Subject: "Digest", Text: "x"},
+	} {
+		if err := m.Send(msg); !errors.Is(err, errHeaderBreak) {
+			t.Errorf("%s with a line break: err = %v, want errHeaderBreak", name, err)
+		}
+	}
+	select {
+	case got := <-messages:
+		t.Errorf("sink received a message: %q", got.data)
+	default:
+	}
+}
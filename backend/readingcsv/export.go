@@ -0,0 +1,140 @@
+package readingcsv
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/metrics"
+	"utility-backend/models"
+	"utility-backend/repository"
+	"utility-backend/validation"
+)
+
+// ExportOptions filter and shape an export. From and To are YYYY-MM-DD local
+// dates, inclusive, checked by the caller; monthly exports include the
+// whole months they fall in.
+type ExportOptions struct {
+	ClientID uint
+	From     string
+	To       string
+	Daily    bool              // one row per site and day instead of one per reading
+	Monthly  bool              // one row per site and calendar month instead of one per reading
+	Units    map[string]string // display unit per metric code, catalog units otherwise
+}
+
+// Export writes readings as CSV with one column per metric of the catalog.
+// Per-reading exports can be read back by Import.
+func Export(db *gorm.DB, out io.Writer, catalog []models.Metric, opts ExportOptions) error {
+	clients, err := repository.NewClients(db).List()
+	if err != nil {
+		return err
+	}
+	siteNames := map[uint]string{}
+	for _, client := range clients {
+		siteNames[client.ID] = client.Name
+	}
+
+	w := csv.NewWriter(out)
+
+	// Metrics a reading or day did not record are left blank
+	metricColumns := func(row []string, lookup func(string) (float64, bool)) []string {
+		for _, m := range catalog {
+			if v, ok := lookup(m.Code); ok {
+				row = append(row, strconv.FormatFloat(metrics.InDisplayUnit(m, v, opts.Units), 'f', m.Precision, 64))
+			} else {
+				row = append(row, "")
+			}
+		}
+		return row
+	}
+
+	var header []string
+	switch {
+	case opts.Monthly:
+		header = []string{"Month", "Site ID", "Site", "Days", "Complete"}
+	case opts.Daily:
+		header = []string{"Date", "Site ID", "Site", "Readings"}
+	default:
+		header = []string{"Date", "Time", "Timezone", "Site ID", "Site"}
+	}
+	for _, m := range catalog {
+		header = append(header, fmt.Sprintf("%s (%s)", m.Name, metrics.DisplayUnit(m, opts.Units)))
+	}
+	if !opts.Daily && !opts.Monthly {
+		header = append(header, "Notes")
+	}
+	w.Write(header)
+
+	switch {
+	case opts.Monthly:
+		for _, client := range clients {
+			if opts.ClientID > 0 && client.ID != opts.ClientID {
+				continue
+			}
+			// Months are complete as of the site's local date
+			today := models.DateOf(time.Now().In(validation.Location(client.Timezone)))
+			months, err := metrics.MonthlyRollup(db, client.ID, today)
+			if err != nil {
+				return err
+			}
+			for _, month := range months {
+				label := month.Month.Format("2006-01")
+				if (opts.From != "" && label < opts.From[:7]) || (opts.To != "" && label > opts.To[:7]) {
+					continue
+				}
+				row := []string{label, strconv.FormatUint(uint64(client.ID), 10), client.Name, strconv.Itoa(month.Days), strconv.FormatBool(month.Complete)}
+				w.Write(metricColumns(row, month.Lookup))
+			}
+		}
+	case opts.Daily:
+		for _, client := range clients {
+			if opts.ClientID > 0 && client.ID != opts.ClientID {
+				continue
+			}
+			days, err := metrics.DailyRollup(db, client.ID, 0)
+			if err != nil {
+				return err
+			}
+			for _, day := range days {
+				if (opts.From != "" && day.Date.String() < opts.From) || (opts.To != "" && day.Date.String() > opts.To) {
+					continue
+				}
+				row := []string{day.Date.String(), strconv.FormatUint(uint64(client.ID), 10), client.Name, strconv.Itoa(day.Readings)}
+				w.Write(metricColumns(row, day.Lookup))
+			}
+		}
+	default:
+		query := db.Preload("Values").Order("date, client_id, read_at, id")
+		if opts.ClientID > 0 {
+			query = query.Where("client_id = ?", opts.ClientID)
+		}
+		if opts.From != "" {
+			query = query.Where("date >= ?", opts.From)
+		}
+		if opts.To != "" {
+			query = query.Where("date <= ?", opts.To)
+		}
+
+		var readings []models.UtilityData
+		if err := query.Find(&readings).Error; err != nil {
+			return err
+		}
+
+		for _, data := range readings {
+			// Times are shown in the timezone the reading was taken in
+			loc := validation.Location(data.Timezone)
+			row := []string{data.Date.String(), data.ReadAt.In(loc).Format("15:04"), loc.String(), strconv.FormatUint(uint64(data.ClientID), 10), siteNames[data.ClientID]}
+			row = metricColumns(row, data.Lookup)
+			row = append(row, data.Notes)
+			w.Write(row)
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
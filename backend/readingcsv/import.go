@@ -0,0 +1,295 @@
+package readingcsv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/metrics"
+	"utility-backend/models"
+	"utility-backend/repository"
+	"utility-backend/units"
+	"utility-backend/validation"
+)
+
+// metricHeaderPattern matches a metric column heading such as "Water (m³)"
+var metricHeaderPattern = regexp.MustCompile(`^(.*?)\s*\(([^()]*)\)$`)
+
+// ImportOptions control an import
+type ImportOptions struct {
+	DryRun     bool  // check the file without writing anything
+	RecordedBy *uint // user the readings are recorded by
+}
+
+// RowError is a problem with one line of an imported file
+type RowError struct {
+	Line    int
+	Message string
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// ImportResult summarises an import
+type ImportResult struct {
+	Rows       int
+	Imported   int // or would be, in a dry run
+	Duplicates int // rows skipped because the site already has a reading at that time
+	Errors     []RowError
+}
+
+// importColumn is a metric column of an imported file
+type importColumn struct {
+	index  int
+	metric models.Metric
+	unit   string
+}
+
+// Import reads readings in the per-reading export format. Site ID, Date and
+// at least one metric column are required; Time defaults to the start of the
+// day and Timezone to the site's. Metric columns are matched to the catalog by
+// name or code and converted from the unit in their heading. Values are
+// checked against each site's validation ranges; if any row is invalid
+// nothing is imported and the problems are returned in the result.
+func Import(db *gorm.DB, in io.Reader, opts ImportOptions) (ImportResult, error) {
+	var result ImportResult
+
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return result, fmt.Errorf("reading header: %w", err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	catalog, err := metrics.All(db)
+	if err != nil {
+		return result, err
+	}
+
+	columns := map[string]int{}
+	var metricColumns []importColumn
+	for i, heading := range header {
+		heading = strings.TrimSpace(heading)
+		switch heading {
+		case "Date", "Time", "Timezone", "Site ID", "Site", "Notes":
+			columns[heading] = i
+			continue
+		case "Readings":
+			return result, errors.New("daily rollup exports cannot be imported, export per reading instead")
+		case "Month":
+			return result, errors.New("monthly rollup exports cannot be imported, export per reading instead")
+		}
+
+		name, unit := heading, ""
+		if match := metricHeaderPattern.FindStringSubmatch(heading); match != nil {
+			name, unit = match[1], match[2]
+		}
+		column, err := matchMetric(catalog, name, unit)
+		if err != nil {
+			return result, fmt.Errorf("column %q: %w", heading, err)
+		}
+		column.index = i
+		metricColumns = append(metricColumns, column)
+	}
+	// Validation reports values by request field, shown as the metric's name
+	columnNames := map[string]string{}
+	for _, column := range metricColumns {
+		columnNames[metrics.FieldName(column.metric.Code)] = column.metric.Name
+	}
+	for _, required := range []string{"Date", "Site ID"} {
+		if _, ok := columns[required]; !ok {
+			return result, fmt.Errorf("missing %s column", required)
+		}
+	}
+	if len(metricColumns) == 0 {
+		return result, errors.New("no metric columns")
+	}
+
+	clients, err := repository.NewClients(db).List()
+	if err != nil {
+		return result, err
+	}
+	clientsByID := map[uint]models.Client{}
+	for _, client := range clients {
+		clientsByID[client.ID] = client
+	}
+
+	now := time.Now()
+	seen := map[string]bool{}
+	var readings []models.UtilityData
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		fail := func(format string, args ...interface{}) {
+			result.Errors = append(result.Errors, RowError{Line: line, Message: fmt.Sprintf(format, args...)})
+		}
+		result.Rows++
+
+		id, err := strconv.ParseUint(field("Site ID"), 10, 64)
+		client, ok := clientsByID[uint(id)]
+		if err != nil || !ok {
+			fail("unknown site %q", field("Site ID"))
+			continue
+		}
+
+		date, err := models.ParseDate(field("Date"))
+		if err != nil {
+			fail("invalid date %q, use YYYY-MM-DD", field("Date"))
+			continue
+		}
+
+		loc := validation.Location(client.Timezone)
+		if name := field("Timezone"); name != "" {
+			if loc, err = time.LoadLocation(name); err != nil {
+				fail("unknown timezone %q", name)
+				continue
+			}
+		}
+
+		readAt := date.In(loc)
+		if clock := field("Time"); clock != "" {
+			t, err := time.Parse("15:04", clock)
+			if err != nil {
+				fail("invalid time %q, use HH:MM", clock)
+				continue
+			}
+			readAt = time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+		}
+		if readAt.After(now) {
+			fail("reading is in the future")
+			continue
+		}
+
+		reading := models.UtilityData{
+			ClientID:   client.ID,
+			Date:       date,
+			ReadAt:     readAt.UTC(),
+			Timezone:   loc.String(),
+			Notes:      field("Notes"),
+			RecordedBy: opts.RecordedBy,
+		}
+		values := map[string]float64{}
+		valid := true
+		for _, column := range metricColumns {
+			if column.index >= len(record) || strings.TrimSpace(record[column.index]) == "" {
+				continue
+			}
+			text := strings.TrimSpace(record[column.index])
+			input, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				fail("%s: invalid number %q", column.metric.Name, text)
+				valid = false
+				continue
+			}
+
+			row := models.ReadingValue{MetricCode: column.metric.Code, Value: input, Unit: column.metric.Unit}
+			if column.unit != column.metric.Unit {
+				if row.Value, err = metrics.ToCatalogUnit(column.metric, input, column.unit); err != nil {
+					fail("%s: %v", column.metric.Name, err)
+					valid = false
+					continue
+				}
+				row.InputValue = &input
+				row.InputUnit = column.unit
+			}
+			values[row.MetricCode] = row.Value
+			reading.Values = append(reading.Values, row)
+		}
+		if !valid {
+			continue
+		}
+		if len(values) == 0 {
+			fail("no values")
+			continue
+		}
+
+		errs, err := validation.ValidateValues(db, client.ID, values)
+		if err != nil {
+			return result, err
+		}
+		for _, e := range errs {
+			fail("%s: %s", columnNames[e.Field], e.Message)
+		}
+		if len(errs) > 0 {
+			continue
+		}
+
+		// A site has one reading a minute, the resolution of exported times;
+		// repeats in the file or of readings already recorded are skipped so
+		// a file can be imported again
+		key := fmt.Sprintf("%d %d", reading.ClientID, reading.ReadAt.Unix())
+		var existing int64
+		db.Model(&models.UtilityData{}).
+			Where("client_id = ? AND read_at >= ? AND read_at < ?", reading.ClientID, reading.ReadAt, reading.ReadAt.Add(time.Minute)).
+			Count(&existing)
+		if seen[key] || existing > 0 {
+			result.Duplicates++
+			continue
+		}
+		seen[key] = true
+		readings = append(readings, reading)
+	}
+
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+	result.Imported = len(readings)
+	if opts.DryRun || len(readings) == 0 {
+		return result, nil
+	}
+
+	err = repository.NewReadings(db).CreateInBatches(readings, 500)
+	if err != nil {
+		result.Imported = 0
+		return result, err
+	}
+	return result, nil
+}
+
+// matchMetric finds the catalog metric a column heading refers to
+func matchMetric(catalog []models.Metric, name, unit string) (importColumn, error) {
+	for _, m := range catalog {
+		if !strings.EqualFold(m.Name, name) && m.Code != metrics.NormalizeCode(name) {
+			continue
+		}
+		if !m.Active {
+			return importColumn{}, fmt.Errorf("metric %s is inactive", m.Code)
+		}
+
+		column := importColumn{metric: m, unit: m.Unit}
+		if unit != "" {
+			u, err := units.Lookup(unit)
+			if err != nil {
+				return importColumn{}, err
+			}
+			if !units.Compatible(m.Unit, u.Symbol, m.Density) {
+				return importColumn{}, fmt.Errorf("%s is measured in %s and cannot be read in %s", m.Name, m.Unit, u.Symbol)
+			}
+			column.unit = u.Symbol
+		}
+		return column, nil
+	}
+	return importColumn{}, fmt.Errorf("no metric named %q", name)
+}
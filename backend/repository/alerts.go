@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/models"
+)
+
+// Alert statuses
+const (
+	AlertOpen     = "open"
+	AlertResolved = "resolved"
+)
+
+// Alerts reads and writes site alerts
+type Alerts struct {
+	db *gorm.DB
+}
+
+// NewAlerts returns the alert repository working on db
+func NewAlerts(db *gorm.DB) *Alerts {
+	return &Alerts{db: db}
+}
+
+// List returns alerts newest first. A clientID of 0 or an empty status matches any.
+func (r *Alerts) List(clientID uint, status string) ([]models.Alert, error) {
+	query := r.db.Order("created_at desc")
+	if clientID != 0 {
+		query = query.Where("client_id = ?", clientID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var alerts []models.Alert
+	err := query.Find(&alerts).Error
+	return alerts, err
+}
+
+// Find returns the alert with the given ID
+func (r *Alerts) Find(id uint) (models.Alert, error) {
+	var alert models.Alert
+	err := r.db.First(&alert, id).Error
+	return alert, err
+}
+
+// OpenTypes returns the distinct types of a site's open alerts
+func (r *Alerts) OpenTypes(clientID uint) ([]string, error) {
+	var types []string
+	err := r.db.Model(&models.Alert{}).
+		Where("client_id = ? AND status = ?", clientID, AlertOpen).
+		Distinct().Pluck("type", &types).Error
+	return types, err
+}
+
+// CountOpenByType returns the number of open alerts across all sites, keyed by type
+func (r *Alerts) CountOpenByType() (map[string]int64, error) {
+	var rows []struct {
+		Type  string
+		Count int64
+	}
+	err := r.db.Model(&models.Alert{}).
+		Select("type, COUNT(*) AS count").
+		Where("status = ?", AlertOpen).
+		Group("type").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Type] = row.Count
+	}
+	return counts, nil
+}
+
+// ExistsForReading reports whether a reading has an open alert
+func (r *Alerts) ExistsForReading(readingID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Alert{}).
+		Where("utility_data_id = ? AND status = ?", readingID, AlertOpen).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ExistsForDate reports whether an alert was raised for any other of a site's
+// readings of a date, leaving out voided readings
+func (r *Alerts) ExistsForDate(clientID uint, date models.Date, readingID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Alert{}).
+		Joins("JOIN utility_data ON utility_data.id = alerts.utility_data_id").
+		Where("alerts.client_id = ? AND utility_data.date = ? AND utility_data.deleted_at IS NULL", clientID, date).
+		Where("alerts.utility_data_id <> ?", readingID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// OpenForReading returns the open alerts raised for a reading
+func (r *Alerts) OpenForReading(readingID uint) ([]models.Alert, error) {
+	var alerts []models.Alert
+	err := r.db.Where("utility_data_id = ? AND status = ?", readingID, AlertOpen).Find(&alerts).Error
+	return alerts, err
+}
+
+// Create saves a new alert
+func (r *Alerts) Create(alert *models.Alert) error {
+	return r.db.Create(alert).Error
+}
+
+// Resolve marks an alert as resolved at the given time
+func (r *Alerts) Resolve(alert *models.Alert, at time.Time) error {
+	alert.Status = AlertResolved
+	alert.ResolvedAt = &at
+	return r.db.Model(alert).Select("status", "resolved_at").Updates(alert).Error
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"utility-backend/models"
+)
+
+// Baselines reads and writes the learned baselines of the sites' metrics
+type Baselines struct {
+	db *gorm.DB
+}
+
+// NewBaselines returns the baseline repository working on db
+func NewBaselines(db *gorm.DB) *Baselines {
+	return &Baselines{db: db}
+}
+
+// ForClient returns a site's baselines by metric and weekday
+func (r *Baselines) ForClient(clientID uint) ([]models.Baseline, error) {
+	var baselines []models.Baseline
+	err := r.db.Where("client_id = ?", clientID).Order("metric_code, weekday").Find(&baselines).Error
+	return baselines, err
+}
+
+// ForWeekday returns a site's baselines for a day of the week, keyed by metric code
+func (r *Baselines) ForWeekday(clientID uint, weekday int) (map[string]models.Baseline, error) {
+	var baselines []models.Baseline
+	err := r.db.Where("client_id = ? AND weekday = ?", clientID, weekday).Find(&baselines).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byMetric := make(map[string]models.Baseline, len(baselines))
+	for _, b := range baselines {
+		byMetric[b.MetricCode] = b
+	}
+	return byMetric, nil
+}
+
+// Replace swaps a site's baselines for newly learned ones
+func (r *Baselines) Replace(clientID uint, baselines []models.Baseline) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("client_id = ?", clientID).Delete(&models.Baseline{}).Error; err != nil {
+			return err
+		}
+		if len(baselines) == 0 {
+			return nil
+		}
+		return tx.Create(&baselines).Error
+	})
+}
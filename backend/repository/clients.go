@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/models"
+)
+
+// Clients reads and writes sites
+type Clients struct {
+	db *gorm.DB
+}
+
+// NewClients returns the site repository working on db
+func NewClients(db *gorm.DB) *Clients {
+	return &Clients{db: db}
+}
+
+// Find returns the site with the given ID
+func (r *Clients) Find(id uint) (models.Client, error) {
+	var client models.Client
+	err := r.db.First(&client, id).Error
+	return client, err
+}
+
+// List returns every site in ID order
+func (r *Clients) List() ([]models.Client, error) {
+	var clients []models.Client
+	err := r.db.Order("id").Find(&clients).Error
+	return clients, err
+}
+
+// Count returns the number of sites
+func (r *Clients) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Client{}).Count(&count).Error
+	return count, err
+}
+
+// Create saves a new site
+func (r *Clients) Create(client *models.Client) error {
+	return r.db.Create(client).Error
+}
+
+// UpdateStatus stores a new status for the site
+func (r *Clients) UpdateStatus(client *models.Client, status string) error {
+	if err := r.db.Model(client).Update("status", status).Error; err != nil {
+		return err
+	}
+	client.Status = status
+	return nil
+}
+
+// HoldStatus keeps the site's status from being recomputed until the given time
+func (r *Clients) HoldStatus(client *models.Client, until time.Time) error {
+	if err := r.db.Model(client).Update("status_held_until", until).Error; err != nil {
+		return err
+	}
+	client.StatusHeldUntil = &until
+	return nil
+}
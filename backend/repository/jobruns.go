@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/models"
+)
+
+// Job run statuses
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// JobRuns reads and writes the history of scheduled jobs
+type JobRuns struct {
+	db *gorm.DB
+}
+
+// NewJobRuns returns the job run repository working on db
+func NewJobRuns(db *gorm.DB) *JobRuns {
+	return &JobRuns{db: db}
+}
+
+// List returns the most recent runs first, at most limit of them. An empty job matches any.
+func (r *JobRuns) List(job string, limit int) ([]models.JobRun, error) {
+	query := r.db.Order("started_at desc, id desc").Limit(limit)
+	if job != "" {
+		query = query.Where("job = ?", job)
+	}
+
+	var runs []models.JobRun
+	err := query.Find(&runs).Error
+	return runs, err
+}
+
+// Latest returns the most recent run of every job that has run, keyed by job
+func (r *JobRuns) Latest() (map[string]models.JobRun, error) {
+	var runs []models.JobRun
+	err := r.db.Where("id IN (?)", r.db.Model(&models.JobRun{}).Select("MAX(id)").Group("job")).
+		Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]models.JobRun, len(runs))
+	for _, run := range runs {
+		latest[run.Job] = run
+	}
+	return latest, nil
+}
+
+// HasSlot reports whether the job has already run for the scheduled time
+func (r *JobRuns) HasSlot(job string, scheduledAt time.Time) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.JobRun{}).
+		Where("job = ? AND scheduled_at = ?", job, scheduledAt).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Create saves a new run
+func (r *JobRuns) Create(run *models.JobRun) error {
+	return r.db.Create(run).Error
+}
+
+// Finish records the outcome of a run: failed with the error, if any, and succeeded otherwise
+func (r *JobRuns) Finish(run *models.JobRun, at time.Time, runErr error) error {
+	run.FinishedAt = &at
+	run.Status = JobSucceeded
+	run.Error = ""
+	if runErr != nil {
+		run.Status = JobFailed
+		run.Error = runErr.Error()
+	}
+	return r.db.Model(run).Select("finished_at", "status", "error").Updates(run).Error
+}
+
+// Abandon marks the job's runs still recorded as running as failed. It is
+// called while holding the job's lock, when no run can really be in progress.
+func (r *JobRuns) Abandon(job string, at time.Time) error {
+	return r.db.Model(&models.JobRun{}).
+		Where("job = ? AND status = ?", job, JobRunning).
+		Updates(map[string]interface{}{
+			"status":      JobFailed,
+			"error":       "interrupted before finishing",
+			"finished_at": at,
+		}).Error
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"utility-backend/models"
+)
+
+// Readings reads and writes utility readings together with their metric values
+type Readings struct {
+	db *gorm.DB
+}
+
+// NewReadings returns the reading repository working on db
+func NewReadings(db *gorm.DB) *Readings {
+	return &Readings{db: db}
+}
+
+// Find returns the reading with the given ID
+func (r *Readings) Find(id uint) (models.UtilityData, error) {
+	var reading models.UtilityData
+	err := r.db.Preload("Values").First(&reading, id).Error
+	return reading, err
+}
+
+// Exists reports whether a site has any readings
+func (r *Readings) Exists(clientID uint) (bool, error) {
+	var ids []uint
+	err := r.db.Model(&models.UtilityData{}).Where("client_id = ?", clientID).Limit(1).Pluck("id", &ids).Error
+	return len(ids) > 0, err
+}
+
+// Recent returns a site's latest n readings, oldest first
+func (r *Readings) Recent(clientID uint, n int) ([]models.UtilityData, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	var readings []models.UtilityData
+	err := r.db.Preload("Values").Where("client_id = ?", clientID).
+		Order("date desc, read_at desc, id desc").Limit(n).Find(&readings).Error
+	for i, j := 0, len(readings)-1; i < j; i, j = i+1, j-1 {
+		readings[i], readings[j] = readings[j], readings[i]
+	}
+	return readings, err
+}
+
+// ReadingPage selects a page of a site's readings, which are ordered by
+// date, time and ID
+type ReadingPage struct {
+	From, To   *models.Date // local dates, inclusive; nil leaves the range open
+	Metrics    []string     // only readings recording one of these metrics, with only their values; empty means all
+	Descending bool         // newest first
+	After      uint         // start after this reading in the page order; 0 starts at the beginning
+	Limit      int
+}
+
+// Page returns a page of a site's readings. A reading given as After is
+// found even if it has since been voided, so that a page can always be
+// continued.
+func (r *Readings) Page(clientID uint, page ReadingPage) ([]models.UtilityData, error) {
+	query := r.db.Where("client_id = ?", clientID).Limit(page.Limit)
+	if page.From != nil {
+		query = query.Where("date >= ?", *page.From)
+	}
+	if page.To != nil {
+		query = query.Where("date <= ?", *page.To)
+	}
+	if len(page.Metrics) > 0 {
+		query = query.Preload("Values", "metric_code IN ?", page.Metrics).
+			Where("EXISTS (SELECT 1 FROM reading_values WHERE reading_values.utility_data_id = utility_data.id AND reading_values.metric_code IN ?)", page.Metrics)
+	} else {
+		query = query.Preload("Values")
+	}
+
+	// Keyset pagination on the row value of the sort columns
+	direction, comparison := "", ">"
+	if page.Descending {
+		direction, comparison = " desc", "<"
+	}
+	if page.After != 0 {
+		query = query.Where("(date, read_at, id) "+comparison+" (SELECT date, read_at, id FROM utility_data WHERE id = ?)", page.After)
+	}
+	query = query.Order("date" + direction + ", read_at" + direction + ", id" + direction)
+
+	var readings []models.UtilityData
+	err := query.Find(&readings).Error
+	return readings, err
+}
+
+// InRange returns a site's readings with a local date from from up to, but not including, to
+func (r *Readings) InRange(clientID uint, from, to models.Date) ([]models.UtilityData, error) {
+	var readings []models.UtilityData
+	err := r.db.Preload("Values").
+		Where("client_id = ? AND date >= ? AND date < ?", clientID, from, to).
+		Order("date, read_at, id").Find(&readings).Error
+	return readings, err
+}
+
+// Flagged returns readings saved despite plausibility warnings, newest first.
+// A clientID of 0 matches every site.
+func (r *Readings) Flagged(clientID uint) ([]models.UtilityData, error) {
+	query := r.db.Preload("Values").Where("confirmed = ?", true).Order("read_at desc, id desc")
+	if clientID != 0 {
+		query = query.Where("client_id = ?", clientID)
+	}
+
+	var readings []models.UtilityData
+	err := query.Find(&readings).Error
+	return readings, err
+}
+
+// CountFlaggedSince counts a site's readings with unusual values from the given local date on
+func (r *Readings) CountFlaggedSince(clientID uint, since models.Date) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.UtilityData{}).
+		Where("client_id = ? AND date >= ? AND suspicious_fields <> ''", clientID, since).
+		Count(&count).Error
+	return count, err
+}
+
+// WithPendingCorrection returns which of the given readings have a correction awaiting review
+func (r *Readings) WithPendingCorrection(ids []uint) ([]uint, error) {
+	var pending []uint
+	err := r.db.Model(&models.ReadingCorrection{}).
+		Where("status = ? AND utility_data_id IN ?", "pending", ids).
+		Pluck("utility_data_id", &pending).Error
+	return pending, err
+}
+
+// SaveScores stores the anomaly scores of a reading's values
+func (r *Readings) SaveScores(reading models.UtilityData) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, v := range reading.Values {
+			err := tx.Model(&models.ReadingValue{}).Where("id = ?", v.ID).
+				Updates(map[string]interface{}{"score": v.Score, "expected": v.Expected}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Create saves a new reading with its values and rolls it up
+func (r *Readings) Create(reading *models.UtilityData) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(reading).Error; err != nil {
+			return err
+		}
+		return NewRollups(tx).Refresh(reading.ClientID, reading.Date, reading.Date)
+	})
+}
+
+// CreateInBatches saves new readings with their values in batches of the
+// given size and rolls them up
+func (r *Readings) CreateInBatches(readings []models.UtilityData, size int) error {
+	if len(readings) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(readings, size).Error; err != nil {
+			return err
+		}
+
+		// Each site is rolled up once over the dates of its new readings
+		spans := map[uint][2]models.Date{}
+		for _, reading := range readings {
+			span, ok := spans[reading.ClientID]
+			if !ok {
+				span = [2]models.Date{reading.Date, reading.Date}
+			}
+			if reading.Date.Before(span[0].Time) {
+				span[0] = reading.Date
+			}
+			if reading.Date.After(span[1].Time) {
+				span[1] = reading.Date
+			}
+			spans[reading.ClientID] = span
+		}
+		for clientID, span := range spans {
+			if err := NewRollups(tx).Refresh(clientID, span[0], span[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/config"
+	"utility-backend/database"
+	"utility-backend/models"
+)
+
+// openTestDB returns a migrated in-memory SQLite database of the test's own
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	cfg, err := config.Load("", map[string]string{
+		"DB_TYPE": "sqlite",
+		"DB_PATH": "file:" + url.PathEscape(t.Name()) + "?mode=memory&cache=shared",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// createSite saves a site in Bangkok time
+func createSite(t *testing.T, db *gorm.DB, name string) models.Client {
+	t.Helper()
+	client := models.Client{Name: name, PlotNumber: "P-" + name, Timezone: "Asia/Bangkok"}
+	if err := db.Create(&client).Error; err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// createReading saves and rolls up a reading taken at the given Bangkok time
+func createReading(t *testing.T, db *gorm.DB, clientID uint, at string, values map[string]float64) models.UtilityData {
+	t.Helper()
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readAt, err := time.ParseInLocation("2006-01-02 15:04", at, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reading := models.UtilityData{
+		ClientID: clientID,
+		Date:     models.DateOf(readAt),
+		ReadAt:   readAt.UTC(),
+		Timezone: loc.String(),
+	}
+	for code, value := range values {
+		reading.Values = append(reading.Values, models.ReadingValue{MetricCode: code, Value: value})
+	}
+	if err := NewReadings(db).Create(&reading); err != nil {
+		t.Fatal(err)
+	}
+	return reading
+}
+
+// readingIDs returns the IDs of readings in order
+func readingIDs(readings []models.UtilityData) []uint {
+	ids := []uint{}
+	for _, r := range readings {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func equalIDs(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReadingsPageFollowsCursor(t *testing.T) {
+	db := openTestDB(t)
+	site := createSite(t, db, "Bang Na")
+	other := createSite(t, db, "Lat Krabang")
+
+	// Two readings share a date and time, so only their IDs order them
+	r1 := createReading(t, db, site.ID, "2026-10-01 08:00", map[string]float64{"water": 100})
+	r2 := createReading(t, db, site.ID, "2026-10-01 17:00", map[string]float64{"water": 110})
+	r3 := createReading(t, db, site.ID, "2026-10-02 08:00", map[string]float64{"water": 120})
+	r4 := createReading(t, db, site.ID, "2026-10-02 08:00", map[string]float64{"pac": 5})
+	r5 := createReading(t, db, site.ID, "2026-10-03 08:00", map[string]float64{"water": 130})
+	createReading(t, db, other.ID, "2026-10-02 09:00", map[string]float64{"water": 999})
+
+	tests := []struct {
+		name  string
+		page  ReadingPage
+		pages [][]uint
+	}{
+		{
+			name:  "oldest first",
+			page:  ReadingPage{Limit: 2},
+			pages: [][]uint{{r1.ID, r2.ID}, {r3.ID, r4.ID}, {r5.ID}, {}},
+		},
+		{
+			name:  "newest first",
+			page:  ReadingPage{Limit: 2, Descending: true},
+			pages: [][]uint{{r5.ID, r4.ID}, {r3.ID, r2.ID}, {r1.ID}, {}},
+		},
+		{
+			name:  "metric filter",
+			page:  ReadingPage{Limit: 2, Metrics: []string{"water"}},
+			pages: [][]uint{{r1.ID, r2.ID}, {r3.ID, r5.ID}, {}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := tt.page
+			for i, want := range tt.pages {
+				readings, err := NewReadings(db).Page(site.ID, page)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := readingIDs(readings); !equalIDs(got, want) {
+					t.Fatalf("page %d = %v, want %v", i+1, got, want)
+				}
+				if len(readings) > 0 {
+					page.After = readings[len(readings)-1].ID
+				}
+			}
+		})
+	}
+}
+
+func TestReadingsPageContinuesAfterVoidedReading(t *testing.T) {
+	db := openTestDB(t)
+	site := createSite(t, db, "Bang Na")
+
+	r1 := createReading(t, db, site.ID, "2026-10-01 08:00", map[string]float64{"water": 100})
+	r2 := createReading(t, db, site.ID, "2026-10-02 08:00", map[string]float64{"water": 110})
+	r3 := createReading(t, db, site.ID, "2026-10-03 08:00", map[string]float64{"water": 120})
+	r4 := createReading(t, db, site.ID, "2026-10-04 08:00", map[string]float64{"water": 130})
+
+	readings := NewReadings(db)
+	first, err := readings.Page(site.ID, ReadingPage{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readingIDs(first); !equalIDs(got, []uint{r1.ID, r2.ID}) {
+		t.Fatalf("first page = %v", got)
+	}
+
+	// The last reading of the page and the next one are voided before the
+	// client asks for the following page
+	if err := db.Delete(&r2).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete(&r3).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := readings.Page(site.ID, ReadingPage{Limit: 2, After: r2.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readingIDs(next); !equalIDs(got, []uint{r4.ID}) {
+		t.Errorf("page after voided reading %d = %v, want [%d]", r2.ID, got, r4.ID)
+	}
+
+	back, err := readings.Page(site.ID, ReadingPage{Limit: 2, After: r3.ID, Descending: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readingIDs(back); !equalIDs(got, []uint{r1.ID}) {
+		t.Errorf("page before voided reading %d = %v, want [%d]", r3.ID, got, r1.ID)
+	}
+}
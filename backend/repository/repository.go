@@ -0,0 +1,29 @@
+// Package repository holds the queries on sites, readings and their rollups
+// and baselines, users, alerts and job runs.
+// Each repository is constructed with the database handle it works on, so
+// callers can be given any connection, e.g. an in-memory SQLite database.
+package repository
+
+import "gorm.io/gorm"
+
+// Repositories bundles the repositories sharing one database handle
+type Repositories struct {
+	Clients   *Clients
+	Readings  *Readings
+	Users     *Users
+	Alerts    *Alerts
+	Rollups   *Rollups
+	Baselines *Baselines
+}
+
+// New returns the repositories working on db
+func New(db *gorm.DB) Repositories {
+	return Repositories{
+		Clients:   NewClients(db),
+		Readings:  NewReadings(db),
+		Users:     NewUsers(db),
+		Alerts:    NewAlerts(db),
+		Rollups:   NewRollups(db),
+		Baselines: NewBaselines(db),
+	}
+}
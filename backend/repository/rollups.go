@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"utility-backend/models"
+)
+
+// Rollups maintains the daily and monthly totals of the readings
+type Rollups struct {
+	db *gorm.DB
+}
+
+// NewRollups returns the rollup repository working on db
+func NewRollups(db *gorm.DB) *Rollups {
+	return &Rollups{db: db}
+}
+
+// Refresh recomputes a site's rollups for the local dates from from to to,
+// inclusive, and for the months they fall in. It is called after readings
+// on those dates are written, changed or voided. Rows are upserted, so that
+// a concurrent refresh of the same dates does not fail.
+func (r *Rollups) Refresh(clientID uint, from, to models.Date) error {
+	if to.Before(from.Time) {
+		from, to = to, from
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("client_id = ? AND date >= ? AND date <= ?", clientID, from, to).
+			Delete(&models.DailyRollup{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec(`INSERT INTO daily_rollups (client_id, date, metric_code, total, readings)
+			SELECT utility_data.client_id, utility_data.date, reading_values.metric_code, SUM(reading_values.value), COUNT(*)
+			FROM utility_data
+			JOIN reading_values ON reading_values.utility_data_id = utility_data.id
+			WHERE utility_data.deleted_at IS NULL AND utility_data.client_id = ? AND utility_data.date >= ? AND utility_data.date <= ?
+			GROUP BY utility_data.client_id, utility_data.date, reading_values.metric_code
+			ON CONFLICT (client_id, date, metric_code) DO UPDATE SET total = excluded.total, readings = excluded.readings`,
+			clientID, from, to).Error
+		if err != nil {
+			return err
+		}
+
+		for month := monthOf(from); !to.Before(month.Time); month = nextMonth(month) {
+			if err := refreshMonth(tx, clientID, month); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// refreshMonth recomputes a site's monthly rollups from its daily ones
+func refreshMonth(tx *gorm.DB, clientID uint, month models.Date) error {
+	err := tx.Where("client_id = ? AND month = ?", clientID, month).Delete(&models.MonthlyRollup{}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Exec(`INSERT INTO monthly_rollups (client_id, month, metric_code, total, readings, days)
+		SELECT client_id, ?, metric_code, SUM(total), SUM(readings), COUNT(*)
+		FROM daily_rollups
+		WHERE client_id = ? AND date >= ? AND date < ?
+		GROUP BY client_id, metric_code
+		ON CONFLICT (client_id, month, metric_code) DO UPDATE
+		SET total = excluded.total, readings = excluded.readings, days = excluded.days`,
+		month, clientID, month, nextMonth(month)).Error
+}
+
+// Rebuild recomputes every rollup of a site, or of all sites with a clientID
+// of 0, from the readings. It returns how many sites were rolled up.
+func (r *Rollups) Rebuild(clientID uint) (int, error) {
+	var spans []struct {
+		ClientID uint
+		First    models.Date
+		Last     models.Date
+	}
+	sites := 0
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		remove := func(model interface{}) error {
+			query := tx.Session(&gorm.Session{AllowGlobalUpdate: true})
+			if clientID != 0 {
+				query = query.Where("client_id = ?", clientID)
+			}
+			return query.Delete(model).Error
+		}
+		if err := remove(&models.DailyRollup{}); err != nil {
+			return err
+		}
+		if err := remove(&models.MonthlyRollup{}); err != nil {
+			return err
+		}
+
+		query := tx.Model(&models.UtilityData{}).
+			Select("client_id, MIN(date) AS first, MAX(date) AS last").
+			Group("client_id").Order("client_id")
+		if clientID != 0 {
+			query = query.Where("client_id = ?", clientID)
+		}
+		if err := query.Scan(&spans).Error; err != nil {
+			return err
+		}
+		for _, span := range spans {
+			if err := NewRollups(tx).Refresh(span.ClientID, span.First, span.Last); err != nil {
+				return err
+			}
+			sites++
+		}
+		return nil
+	})
+	return sites, err
+}
+
+// monthOf returns the first day of the month of d
+func monthOf(d models.Date) models.Date {
+	return models.NewDate(d.Year(), d.Month(), 1)
+}
+
+// nextMonth returns the first day of the month after the one starting on month
+func nextMonth(month models.Date) models.Date {
+	return models.NewDate(month.Year(), month.Month()+1, 1)
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"utility-backend/models"
+)
+
+// rollupKey identifies a rollup row of a site
+type rollupKey struct {
+	date   string
+	metric string
+}
+
+// rollupRow is the total and counts of a rollup row
+type rollupRow struct {
+	total    float64
+	readings int
+	days     int // monthly rollups only
+}
+
+// dailyRollups returns a site's daily rollups
+func dailyRollups(t *testing.T, db *gorm.DB, clientID uint) map[rollupKey]rollupRow {
+	t.Helper()
+	var rows []models.DailyRollup
+	if err := db.Where("client_id = ?", clientID).Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	got := map[rollupKey]rollupRow{}
+	for _, r := range rows {
+		got[rollupKey{r.Date.String(), r.MetricCode}] = rollupRow{total: r.Total, readings: r.Readings}
+	}
+	return got
+}
+
+// monthlyRollups returns a site's monthly rollups
+func monthlyRollups(t *testing.T, db *gorm.DB, clientID uint) map[rollupKey]rollupRow {
+	t.Helper()
+	var rows []models.MonthlyRollup
+	if err := db.Where("client_id = ?", clientID).Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	got := map[rollupKey]rollupRow{}
+	for _, r := range rows {
+		got[rollupKey{r.Month.String(), r.MetricCode}] = rollupRow{total: r.Total, readings: r.Readings, days: r.Days}
+	}
+	return got
+}
+
+func checkRollups(t *testing.T, kind string, got, want map[rollupKey]rollupRow) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s rollups = %v, want %v", kind, got, want)
+		return
+	}
+	for key, w := range want {
+		if g, ok := got[key]; !ok || g != w {
+			t.Errorf("%s rollup %s %s = %+v, want %+v", kind, key.date, key.metric, g, w)
+		}
+	}
+}
+
+func TestRollupsRefresh(t *testing.T) {
+	db := openTestDB(t)
+	site := createSite(t, db, "Bang Na")
+
+	createReading(t, db, site.ID, "2026-09-30 08:00", map[string]float64{"water": 50})
+	morning := createReading(t, db, site.ID, "2026-10-01 08:00", map[string]float64{"water": 100, "pac": 4})
+	createReading(t, db, site.ID, "2026-10-01 17:00", map[string]float64{"water": 25.5})
+	late := createReading(t, db, site.ID, "2026-10-03 23:30", map[string]float64{"water": 80})
+
+	// Creating readings rolls them up
+	checkRollups(t, "daily", dailyRollups(t, db, site.ID), map[rollupKey]rollupRow{
+		{"2026-09-30", "water"}: {total: 50, readings: 1},
+		{"2026-10-01", "water"}: {total: 125.5, readings: 2},
+		{"2026-10-01", "pac"}:   {total: 4, readings: 1},
+		{"2026-10-03", "water"}: {total: 80, readings: 1},
+	})
+	checkRollups(t, "monthly", monthlyRollups(t, db, site.ID), map[rollupKey]rollupRow{
+		{"2026-09-01", "water"}: {total: 50, readings: 1, days: 1},
+		{"2026-10-01", "water"}: {total: 205.5, readings: 3, days: 2},
+		{"2026-10-01", "pac"}:   {total: 4, readings: 1, days: 1},
+	})
+
+	// Voiding readings and refreshing their dates drops them from the
+	// totals, and a date left without readings from the rollups
+	if err := db.Delete(&morning).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete(&late).Error; err != nil {
+		t.Fatal(err)
+	}
+	rollups := NewRollups(db)
+	if err := rollups.Refresh(site.ID, morning.Date, morning.Date); err != nil {
+		t.Fatal(err)
+	}
+	// The dates may be given in either order
+	if err := rollups.Refresh(site.ID, late.Date, morning.Date); err != nil {
+		t.Fatal(err)
+	}
+
+	checkRollups(t, "daily", dailyRollups(t, db, site.ID), map[rollupKey]rollupRow{
+		{"2026-09-30", "water"}: {total: 50, readings: 1},
+		{"2026-10-01", "water"}: {total: 25.5, readings: 1},
+	})
+	checkRollups(t, "monthly", monthlyRollups(t, db, site.ID), map[rollupKey]rollupRow{
+		{"2026-09-01", "water"}: {total: 50, readings: 1, days: 1},
+		{"2026-10-01", "water"}: {total: 25.5, readings: 1, days: 1},
+	})
+}
+
+func TestRollupsRebuild(t *testing.T) {
+	db := openTestDB(t)
+	site := createSite(t, db, "Bang Na")
+	other := createSite(t, db, "Lat Krabang")
+
+	createReading(t, db, site.ID, "2026-09-30 08:00", map[string]float64{"water": 50})
+	createReading(t, db, site.ID, "2026-10-01 08:00", map[string]float64{"water": 100})
+	createReading(t, db, other.ID, "2026-10-01 08:00", map[string]float64{"electricity": 1200})
+
+	want := dailyRollups(t, db, site.ID)
+	wantMonthly := monthlyRollups(t, db, site.ID)
+	wantOther := dailyRollups(t, db, other.ID)
+
+	// Rollups left wrong, e.g. by a reading written without rolling it up
+	corrupt := func() {
+		t.Helper()
+		err := db.Model(&models.DailyRollup{}).Where("1 = 1").Update("total", -1).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Create(&models.MonthlyRollup{ClientID: site.ID, Month: models.NewDate(2026, 8, 1), MetricCode: "water", Total: 7, Readings: 1, Days: 1}).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Rebuilding one site leaves the others as they are
+	corrupt()
+	sites, err := NewRollups(db).Rebuild(site.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sites != 1 {
+		t.Errorf("Rebuild(%d) rolled up %d sites, want 1", site.ID, sites)
+	}
+	checkRollups(t, "daily", dailyRollups(t, db, site.ID), want)
+	checkRollups(t, "monthly", monthlyRollups(t, db, site.ID), wantMonthly)
+	if got := dailyRollups(t, db, other.ID)[rollupKey{"2026-10-01", "electricity"}]; got.total != -1 {
+		t.Errorf("the other site's rollup was rebuilt too: %+v", got)
+	}
+
+	// Rebuilding every site
+	corrupt()
+	if sites, err = NewRollups(db).Rebuild(0); err != nil {
+		t.Fatal(err)
+	}
+	if sites != 2 {
+		t.Errorf("Rebuild(0) rolled up %d sites, want 2", sites)
+	}
+	checkRollups(t, "daily", dailyRollups(t, db, site.ID), want)
+	checkRollups(t, "monthly", monthlyRollups(t, db, site.ID), wantMonthly)
+	checkRollups(t, "daily", dailyRollups(t, db, other.ID), wantOther)
+}
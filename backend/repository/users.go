@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"utility-backend/models"
+)
+
+// Users reads and writes user accounts
+type Users struct {
+	db *gorm.DB
+}
+
+// NewUsers returns the user repository working on db
+func NewUsers(db *gorm.DB) *Users {
+	return &Users{db: db}
+}
+
+// FindByUsername returns the user with the given username
+func (r *Users) FindByUsername(username string) (models.User, error) {
+	var user models.User
+	err := r.db.Where("username = ?", username).First(&user).Error
+	return user, err
+}
+
+// FirstWithRole returns the oldest user with the given role
+func (r *Users) FirstWithRole(role string) (models.User, error) {
+	var user models.User
+	err := r.db.Where("role = ?", role).Order("id").First(&user).Error
+	return user, err
+}
+
+// Exists reports whether a user with the given username exists
+func (r *Users) Exists(username string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Where("username = ?", username).Count(&count).Error
+	return count > 0, err
+}
+
+// Count returns the number of users
+func (r *Users) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Count(&count).Error
+	return count, err
+}
+
+// Create saves a new user. The password must already be hashed.
+func (r *Users) Create(user *models.User) error {
+	return r.db.Create(user).Error
+}
+
+// UpdatePassword stores the user's password, which must already be hashed
+func (r *Users) UpdatePassword(user *models.User) error {
+	return r.db.Model(user).Update("password", user.Password).Error
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"utility-backend/config"
+	"utility-backend/database"
+	"utility-backend/repository"
+)
+
+// runRebuildRollups handles the rebuild-rollups subcommand:
+//
+//	rebuild-rollups [-client id]
+func runRebuildRollups(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("rebuild-rollups", flag.ContinueOnError)
+	clientID := flags.Uint("client", 0, "only rebuild this site's rollups")
+	flags.Usage = usageFunc(flags, "rebuild-rollups [-client id]")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+	if *clientID > 0 {
+		if _, err := repository.NewClients(db).Find(*clientID); err != nil {
+			return fmt.Errorf("site %d not found", *clientID)
+		}
+	}
+
+	sites, err := repository.NewRollups(db).Rebuild(*clientID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Rebuilt the rollups of %d sites\n", sites)
+	return nil
+}
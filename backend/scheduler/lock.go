@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+
+	"gorm.io/gorm"
+
+	"utility-backend/config"
+)
+
+// Locker hands out the lock a job holds while it runs, so that replicas
+// sharing a database never run the same job at once
+type Locker interface {
+	// TryLock takes the job's lock without waiting. It reports false if
+	// another run holds it; otherwise unlock must be called once the run ends.
+	TryLock(ctx context.Context, job string) (unlock func(), ok bool, err error)
+}
+
+// NewLocker returns the locker suited to the database: PostgreSQL advisory
+// locks, which every replica connected to the database sees, or file locks
+// next to the SQLite database file
+func NewLocker(db *gorm.DB, cfg config.Database) (Locker, error) {
+	switch cfg.Type {
+	case "postgres":
+		return advisoryLocker{db: db}, nil
+	case "sqlite":
+		return newFileLocker(cfg.Path), nil
+	}
+	return nil, fmt.Errorf("unsupported database type: %s", cfg.Type)
+}
+
+// advisoryLocker takes session-level PostgreSQL advisory locks. Each lock is
+// held on a connection of its own, which is returned to the pool on unlock;
+// if the process dies the server releases the lock with the connection.
+type advisoryLocker struct {
+	db *gorm.DB
+}
+
+// TryLock implements Locker
+func (l advisoryLocker) TryLock(ctx context.Context, job string) (func(), bool, error) {
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key := lockKey(job)
+	var ok bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	if !ok {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		// The run's context may be cancelled by now; the lock must still go
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			log.Error("Failed to release job lock", "job", job, "error", err)
+		}
+		conn.Close()
+	}
+	return unlock, true, nil
+}
+
+// lockKey maps a job name to the 64-bit key of its advisory lock
+func lockKey(job string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("utility-backend job " + job))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,36 @@
+//go:build !unix
+
+package scheduler
+
+import (
+	"context"
+	"sync"
+)
+
+// fileLocker only keeps a job from running twice within this process on
+// systems without flock. SQLite deployments run a single instance there.
+type fileLocker struct {
+	mu   sync.Mutex
+	held map[string]bool
+}
+
+func newFileLocker(string) Locker {
+	return &fileLocker{held: map[string]bool{}}
+}
+
+// TryLock implements Locker
+func (l *fileLocker) TryLock(_ context.Context, job string) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held[job] {
+		return nil, false, nil
+	}
+	l.held[job] = true
+
+	unlock := func() {
+		l.mu.Lock()
+		delete(l.held, job)
+		l.mu.Unlock()
+	}
+	return unlock, true, nil
+}
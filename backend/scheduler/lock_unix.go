@@ -0,0 +1,42 @@
+//go:build unix
+
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+)
+
+// fileLocker takes flock locks on a file per job next to the SQLite
+// database, which every process using the database file can see. The
+// kernel releases a lock when its holder exits. The files are left in place.
+type fileLocker struct {
+	dbPath string
+}
+
+func newFileLocker(dbPath string) Locker {
+	return fileLocker{dbPath: dbPath}
+}
+
+// TryLock implements Locker
+func (l fileLocker) TryLock(_ context.Context, job string) (func(), bool, error) {
+	f, err := os.OpenFile(l.dbPath+"."+job+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	unlock := func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}
+	return unlock, true, nil
+}
@@ -0,0 +1,39 @@
+//go:build unix
+
+package scheduler
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileLocker(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "utility.db")
+
+	// Two lockers on the same database stand for two processes using it
+	a, b := newFileLocker(path), newFileLocker(path)
+
+	unlock, ok, err := a.TryLock(ctx, "count")
+	if err != nil || !ok {
+		t.Fatalf("first lock = %v, %v, want it taken", ok, err)
+	}
+	if _, ok, err := b.TryLock(ctx, "count"); err != nil || ok {
+		t.Errorf("second lock while held = %v, %v, want it refused", ok, err)
+	}
+
+	// Jobs are locked apart
+	other, ok, err := b.TryLock(ctx, "other")
+	if err != nil || !ok {
+		t.Fatalf("lock of another job = %v, %v, want it taken", ok, err)
+	}
+	other()
+
+	unlock()
+	unlock, ok, err = b.TryLock(ctx, "count")
+	if err != nil || !ok {
+		t.Fatalf("lock after unlock = %v, %v, want it taken", ok, err)
+	}
+	unlock()
+}
@@ -0,0 +1,374 @@
+// Package scheduler runs background jobs on cron schedules. Every run is
+// recorded in the job run history, and a lock held for the length of a run
+// keeps replicas sharing the database from running a job twice.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+
+	"utility-backend/config"
+	"utility-backend/logging"
+	"utility-backend/models"
+	"utility-backend/monitoring"
+	"utility-backend/repository"
+)
+
+// Run triggers
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// maxSleep bounds the wait between checks for due jobs, so that the
+// heartbeat keeps beating while no job is due
+const maxSleep = time.Minute
+
+var (
+	// ErrUnknownJob is returned when triggering a job that is not registered
+	ErrUnknownJob = errors.New("no such job")
+	// ErrRunning is returned when triggering a job that is already running
+	ErrRunning = errors.New("job is already running")
+	// ErrStopped is returned when triggering a job during shutdown
+	ErrStopped = errors.New("scheduler is shutting down")
+
+	// errDone skips a scheduled run another replica already made
+	errDone = errors.New("run already made")
+
+	log = logging.For("scheduler")
+)
+
+// Job is a task run on a schedule
+type Job struct {
+	Name string
+	// Schedule is a five-field cron expression, such as "*/15 * * * *", or
+	// a descriptor such as @hourly, read in the scheduler's timezone
+	Schedule    string
+	Description string
+	// Run does the work. It should return early once ctx is done.
+	Run func(ctx context.Context, db *gorm.DB) error
+}
+
+// Status describes a registered job and its latest run
+type Status struct {
+	Name        string         `json:"name"`
+	Schedule    string         `json:"schedule"`
+	Description string         `json:"description"`
+	NextRunAt   *time.Time     `json:"nextRunAt"`
+	LastRun     *models.JobRun `json:"lastRun"`
+}
+
+// entry is a registered job with its parsed schedule
+type entry struct {
+	job      Job
+	schedule cron.Schedule
+	next     time.Time
+}
+
+// Scheduler runs registered jobs when they are due and on demand
+type Scheduler struct {
+	db        *gorm.DB
+	locker    Locker
+	location  *time.Location
+	enabled   bool
+	instance  string
+	heartbeat *monitoring.Heartbeat
+	// now tells the time, replaced in tests
+	now func() time.Time
+
+	// ctx is the context of every run, cancelled on shutdown
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	entries []*entry
+	stopped bool
+	running sync.WaitGroup
+}
+
+// New returns a scheduler running jobs on db, locked with locker. Without
+// cfg.Enabled jobs only run when triggered. The heartbeat, which may be
+// nil, is beaten while the scheduler runs.
+func New(db *gorm.DB, cfg config.Scheduler, locker Locker, heartbeat *monitoring.Heartbeat) (*Scheduler, error) {
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("loading scheduler timezone: %w", err)
+	}
+	instance, _ := os.Hostname()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		db:        db,
+		locker:    locker,
+		location:  location,
+		enabled:   cfg.Enabled,
+		instance:  instance,
+		heartbeat: heartbeat,
+		now:       time.Now,
+		ctx:       ctx,
+		cancel:    cancel,
+	}, nil
+}
+
+// Register adds a job. Names must be unique.
+func (s *Scheduler) Register(job Job) error {
+	schedule, err := cron.ParseStandard(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("job %s: invalid schedule %q: %w", job.Name, job.Schedule, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.find(job.Name) != nil {
+		return fmt.Errorf("job %s is already registered", job.Name)
+	}
+	s.entries = append(s.entries, &entry{
+		job:      job,
+		schedule: schedule,
+		next:     schedule.Next(s.now().In(s.location)),
+	})
+	return nil
+}
+
+// find returns the entry of the named job, or nil. s.mu must be held.
+func (s *Scheduler) find(name string) *entry {
+	for _, e := range s.entries {
+		if e.job.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// Run starts jobs as they fall due until ctx is done, then waits for the
+// runs in progress, which are cancelled, to finish. A job is run once for
+// its latest due time however many were missed, e.g. while the process
+// was down.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		s.heartbeat.Beat()
+		if s.enabled {
+			s.startDue(s.now())
+		}
+
+		timer := time.NewTimer(s.sleep(s.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// sleep returns how long to wait from now for the next due job
+func (s *Scheduler) sleep(now time.Time) time.Duration {
+	wait := maxSleep
+	if !s.enabled {
+		return wait
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if d := e.next.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// startDue starts every job whose next run time has passed
+func (s *Scheduler) startDue(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.next.After(now) {
+			continue
+		}
+		// The run fills the latest slot due, which every replica agrees on
+		// whenever each of them last checked
+		slot := e.next
+		for next := e.schedule.Next(slot); !next.After(now); next = e.schedule.Next(next) {
+			slot = next
+		}
+		e.next = e.schedule.Next(now.In(s.location))
+
+		job := e.job
+		s.running.Add(1)
+		go func() {
+			defer s.running.Done()
+			run, unlock, err := s.begin(job, TriggerSchedule, &slot, "")
+			switch {
+			case errors.Is(err, ErrRunning), errors.Is(err, errDone):
+				log.Debug("Skipping job run", "job", job.Name, "scheduledAt", slot, "reason", err.Error())
+				return
+			case err != nil:
+				log.Error("Failed to start job", "job", job.Name, "error", err)
+				return
+			}
+			s.execute(job, run, unlock)
+		}()
+	}
+}
+
+// stop cancels the runs in progress and waits for them to finish
+func (s *Scheduler) stop() {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+
+	s.cancel()
+	s.running.Wait()
+}
+
+// Trigger starts a run of the named job now, whether or not the scheduler
+// is enabled, and returns its record. The run carries on in the background
+// and is recorded as triggered by by, the name of who asked for it.
+func (s *Scheduler) Trigger(name, by string) (models.JobRun, error) {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return models.JobRun{}, ErrStopped
+	}
+	e := s.find(name)
+	if e == nil {
+		s.mu.Unlock()
+		return models.JobRun{}, ErrUnknownJob
+	}
+	job := e.job
+	s.running.Add(1)
+	s.mu.Unlock()
+
+	run, unlock, err := s.begin(job, TriggerManual, nil, by)
+	if err != nil {
+		s.running.Done()
+		return models.JobRun{}, err
+	}
+	started := *run
+	go func() {
+		defer s.running.Done()
+		s.execute(job, run, unlock)
+	}()
+	return started, nil
+}
+
+// begin takes the job's lock and records the start of a run. Scheduled
+// runs are given the time they were due, and a run already recorded for
+// that time, by this or another replica, is not made again.
+func (s *Scheduler) begin(job Job, trigger string, slot *time.Time, by string) (*models.JobRun, func(), error) {
+	unlock, ok, err := s.locker.TryLock(s.ctx, job.Name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("taking the job lock: %w", err)
+	}
+	if !ok {
+		return nil, nil, ErrRunning
+	}
+
+	runs := repository.NewJobRuns(s.db.WithContext(s.ctx))
+	now := s.now()
+	if slot != nil {
+		utc := slot.UTC()
+		slot = &utc
+		done, err := runs.HasSlot(job.Name, utc)
+		if err == nil && done {
+			err = errDone
+		}
+		if err != nil {
+			unlock()
+			return nil, nil, err
+		}
+	}
+	// No run is in progress while the lock is free, so runs still recorded
+	// as running were cut short, e.g. by a crash
+	if err := runs.Abandon(job.Name, now); err != nil {
+		unlock()
+		return nil, nil, err
+	}
+
+	run := &models.JobRun{
+		Job:         job.Name,
+		Trigger:     trigger,
+		ScheduledAt: slot,
+		TriggeredBy: by,
+		Instance:    s.instance,
+		Status:      repository.JobRunning,
+		StartedAt:   now,
+	}
+	if err := runs.Create(run); err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	return run, unlock, nil
+}
+
+// execute runs the job, records the outcome and releases the lock
+func (s *Scheduler) execute(job Job, run *models.JobRun, unlock func()) {
+	defer unlock()
+	log.Info("Job started", "job", job.Name, "runId", run.ID, "trigger", run.Trigger)
+
+	err := s.call(job)
+	finished := s.now()
+	duration := logging.Duration(finished.Sub(run.StartedAt))
+	if err != nil {
+		log.Error("Job failed", "job", job.Name, "runId", run.ID, duration, "error", err)
+	} else {
+		log.Info("Job finished", "job", job.Name, "runId", run.ID, duration)
+	}
+
+	// The run's context may be cancelled; its outcome is still recorded
+	if err := repository.NewJobRuns(s.db).Finish(run, finished, err); err != nil {
+		log.Error("Failed to record job run", "job", job.Name, "runId", run.ID, "error", err)
+	}
+}
+
+// call runs the job, turning a panic into an error
+func (s *Scheduler) call(job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(s.ctx, s.db.WithContext(s.ctx))
+}
+
+// Jobs describes the registered jobs in name order with their latest runs.
+// Next run times are left out while the scheduler is disabled.
+func (s *Scheduler) Jobs(db *gorm.DB) ([]Status, error) {
+	latest, err := repository.NewJobRuns(db).Latest()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]Status, 0, len(s.entries))
+	for _, e := range s.entries {
+		status := Status{
+			Name:        e.job.Name,
+			Schedule:    e.job.Schedule,
+			Description: e.job.Description,
+		}
+		if s.enabled {
+			next := e.next
+			status.NextRunAt = &next
+		}
+		if run, ok := latest[e.job.Name]; ok {
+			status.LastRun = &run
+		}
+		jobs = append(jobs, status)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs, nil
+}
@@ -0,0 +1,295 @@
+package scheduler
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/config"
+	"utility-backend/database"
+	"utility-backend/models"
+	"utility-backend/repository"
+)
+
+// openTestDB returns a migrated in-memory SQLite database of the test's own
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	cfg, err := config.Load("", map[string]string{
+		"DB_TYPE": "sqlite",
+		"DB_PATH": "file:" + url.PathEscape(t.Name()) + "?mode=memory&cache=shared",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// clock is a fake clock, moved on by hand
+type clock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *clock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *clock) set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = t
+}
+
+// memLocker is a Locker shared by the schedulers of a test, as replicas
+// share the locks of their database
+type memLocker struct {
+	mu   sync.Mutex
+	held map[string]bool
+}
+
+func newMemLocker() *memLocker {
+	return &memLocker{held: map[string]bool{}}
+}
+
+// TryLock implements Locker
+func (l *memLocker) TryLock(_ context.Context, job string) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held[job] {
+		return nil, false, nil
+	}
+	l.held[job] = true
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.held, job)
+	}, true, nil
+}
+
+// bangkok returns a time on 19 October 2026 in Bangkok
+func bangkok(t *testing.T, hour, min, sec int) time.Time {
+	t.Helper()
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return time.Date(2026, 10, 19, hour, min, sec, 0, loc)
+}
+
+// newTestScheduler returns an enabled scheduler on db telling the time by clock
+func newTestScheduler(t *testing.T, db *gorm.DB, locker Locker, clock *clock) *Scheduler {
+	t.Helper()
+	s, err := New(db, config.Scheduler{Enabled: true, Timezone: "Asia/Bangkok"}, locker, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = clock.now
+	t.Cleanup(s.stop)
+	return s
+}
+
+// countingJob returns a job run every 15 minutes that counts its runs
+func countingJob(calls *int32) Job {
+	return Job{
+		Name:     "count",
+		Schedule: "*/15 * * * *",
+		Run: func(context.Context, *gorm.DB) error {
+			atomic.AddInt32(calls, 1)
+			return nil
+		},
+	}
+}
+
+// jobRuns returns the recorded runs of a job, newest first
+func jobRuns(t *testing.T, db *gorm.DB, job string) []models.JobRun {
+	t.Helper()
+	runs, err := repository.NewJobRuns(db).List(job, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runs
+}
+
+func TestSchedulerRunsEachSlotOnce(t *testing.T) {
+	db := openTestDB(t)
+	locker := newMemLocker()
+	clock := &clock{t: bangkok(t, 10, 7, 0)}
+
+	// Two replicas of the same job on one database
+	var calls int32
+	a := newTestScheduler(t, db, locker, clock)
+	b := newTestScheduler(t, db, locker, clock)
+	for _, s := range []*Scheduler{a, b} {
+		if err := s.Register(countingJob(&calls)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	startDue := func(schedulers ...*Scheduler) {
+		t.Helper()
+		for _, s := range schedulers {
+			s.startDue(clock.now())
+		}
+		for _, s := range schedulers {
+			s.running.Wait()
+		}
+	}
+
+	// Nothing is due before the first slot
+	clock.set(bangkok(t, 10, 14, 59))
+	startDue(a, b)
+	if calls != 0 {
+		t.Fatalf("ran %d times before 10:15, want 0", calls)
+	}
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		schedulers []*Scheduler
+		slot       time.Time
+	}{
+		// One replica fills the slot and the other finds it filled
+		{"one after the other", bangkok(t, 10, 15, 30), []*Scheduler{a, b}, bangkok(t, 10, 15, 0)},
+		// Whichever takes the lock first runs the job
+		{"at once", bangkok(t, 10, 30, 5), []*Scheduler{a, b}, bangkok(t, 10, 30, 0)},
+		// Slots missed while the process was down are run once, for the
+		// latest of them
+		{"missed slots", bangkok(t, 11, 20, 0), []*Scheduler{b, a}, bangkok(t, 11, 15, 0)},
+	}
+	for i, tt := range tests {
+		clock.set(tt.now)
+		startDue(tt.schedulers...)
+
+		if got := atomic.LoadInt32(&calls); got != int32(i+1) {
+			t.Fatalf("%s: job ran %d times in all, want %d", tt.name, got, i+1)
+		}
+		runs := jobRuns(t, db, "count")
+		if len(runs) != i+1 {
+			t.Fatalf("%s: %d runs recorded, want %d", tt.name, len(runs), i+1)
+		}
+		run := runs[0]
+		if run.ScheduledAt == nil || !run.ScheduledAt.Equal(tt.slot) {
+			t.Errorf("%s: run scheduled at %v, want %v", tt.name, run.ScheduledAt, tt.slot)
+		}
+		if run.Trigger != TriggerSchedule || run.Status != repository.JobSucceeded || run.FinishedAt == nil || !run.FinishedAt.Equal(tt.now) {
+			t.Errorf("%s: run = %s %s finished at %v, want a scheduled run succeeded at %v", tt.name, run.Trigger, run.Status, run.FinishedAt, tt.now)
+		}
+	}
+}
+
+func TestSchedulerSkipsLockedJob(t *testing.T) {
+	db := openTestDB(t)
+	locker := newMemLocker()
+	clock := &clock{t: bangkok(t, 10, 7, 0)}
+	s := newTestScheduler(t, db, locker, clock)
+
+	var calls int32
+	if err := s.Register(countingJob(&calls)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another runner holds the job's lock
+	unlock, ok, err := locker.TryLock(context.Background(), "count")
+	if err != nil || !ok {
+		t.Fatalf("taking the lock: %v, %v", ok, err)
+	}
+
+	clock.set(bangkok(t, 10, 15, 0))
+	s.startDue(clock.now())
+	s.running.Wait()
+	if _, err := s.Trigger("count", "admin"); err != ErrRunning {
+		t.Errorf("Trigger while locked = %v, want ErrRunning", err)
+	}
+	if calls != 0 {
+		t.Errorf("ran %d times while locked, want 0", calls)
+	}
+	if runs := jobRuns(t, db, "count"); len(runs) != 0 {
+		t.Errorf("%d runs recorded while locked, want 0", len(runs))
+	}
+
+	// Once it is released the job can be triggered, but not while that
+	// run is still going
+	unlock()
+	release := make(chan struct{})
+	if err := s.Register(Job{Name: "slow", Schedule: "@daily", Run: func(ctx context.Context, _ *gorm.DB) error {
+		<-release
+		return nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Trigger("slow", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Trigger("slow", "admin"); err != ErrRunning {
+		t.Errorf("Trigger while running = %v, want ErrRunning", err)
+	}
+	close(release)
+	s.running.Wait()
+
+	run, err := s.Trigger("count", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.running.Wait()
+	if run.Trigger != TriggerManual || run.TriggeredBy != "admin" || run.ScheduledAt != nil {
+		t.Errorf("run = %s by %q scheduled at %v, want a manual run by admin", run.Trigger, run.TriggeredBy, run.ScheduledAt)
+	}
+	if calls != 1 {
+		t.Errorf("ran %d times once unlocked, want 1", calls)
+	}
+}
+
+func TestSchedulerAbandonsInterruptedRuns(t *testing.T) {
+	db := openTestDB(t)
+	clock := &clock{t: bangkok(t, 10, 7, 0)}
+	s := newTestScheduler(t, db, newMemLocker(), clock)
+
+	var calls int32
+	if err := s.Register(countingJob(&calls)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A run cut short by a crash is still recorded as running
+	slot := bangkok(t, 9, 45, 0).UTC()
+	crashed := models.JobRun{Job: "count", Trigger: TriggerSchedule, ScheduledAt: &slot, Status: repository.JobRunning, StartedAt: slot}
+	if err := repository.NewJobRuns(db).Create(&crashed); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Trigger("count", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	s.running.Wait()
+
+	runs := jobRuns(t, db, "count")
+	if len(runs) != 2 {
+		t.Fatalf("%d runs recorded, want 2", len(runs))
+	}
+	for _, run := range runs {
+		switch run.ID {
+		case crashed.ID:
+			if run.Status != repository.JobFailed || run.Error != "interrupted before finishing" || run.FinishedAt == nil || !run.FinishedAt.Equal(clock.now()) {
+				t.Errorf("crashed run = %s (%q) finished at %v, want failed as interrupted at %v", run.Status, run.Error, run.FinishedAt, clock.now())
+			}
+		default:
+			if run.Status != repository.JobSucceeded {
+				t.Errorf("new run = %s (%q), want succeeded", run.Status, run.Error)
+			}
+		}
+	}
+}
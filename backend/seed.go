@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"utility-backend/config"
+	"utility-backend/database"
+	"utility-backend/models"
+	"utility-backend/seed"
+)
+
+// runSeed handles the seed subcommand:
+//
+//	seed [-profile demo] [-sites n] [-months n] [-seed n] [-end YYYY-MM-DD] [-admin-password p]
+func runSeed(cfg *config.Config, args []string) error {
+	var names []string
+	for _, p := range seed.Profiles {
+		names = append(names, p.Name)
+	}
+
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	profileName := flags.String("profile", "demo", "seed profile: "+strings.Join(names, ", "))
+	sites := flags.Int("sites", 0, "number of sites, overriding the profile")
+	months := flags.Int("months", 0, "months of readings, overriding the profile")
+	randomSeed := flags.Int64("seed", 1, "random seed; the same seed generates the same data")
+	end := flags.String("end", "", "last day with readings, YYYY-MM-DD (default yesterday)")
+	adminPassword := flags.String("admin-password", "", "admin password for the minimal profile (default ADMIN_PASSWORD, or generated)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: seed [flags]\n\nprofiles:")
+		for _, p := range seed.Profiles {
+			fmt.Fprintf(flags.Output(), "  %-10s %s\n", p.Name, p.Description)
+		}
+		fmt.Fprintln(flags.Output(), "\nflags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	profile, err := seed.FindProfile(*profileName)
+	if err != nil {
+		return err
+	}
+	opts := seed.Options{
+		Sites:         *sites,
+		Months:        *months,
+		Seed:          *randomSeed,
+		AdminPassword: *adminPassword,
+	}
+	if opts.AdminPassword == "" {
+		opts.AdminPassword = cfg.AdminPassword
+	}
+	if *end != "" {
+		if opts.End, err = models.ParseDate(*end); err != nil {
+			return fmt.Errorf("invalid end date %q, expected YYYY-MM-DD", *end)
+		}
+	}
+
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+	result, err := seed.Run(db, profile, opts)
+	if err != nil {
+		return err
+	}
+
+	if len(result.Users) > 0 {
+		fmt.Printf("Created users: %s\n", strings.Join(result.Users, ", "))
+	}
+	if result.AdminPassword != "" {
+		fmt.Printf("Generated admin password: %s\n", result.AdminPassword)
+	}
+	if result.Sites > 0 {
+		fmt.Printf("Seeded %d sites with %d readings\n", result.Sites, result.Readings)
+	}
+	return nil
+}
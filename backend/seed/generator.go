@@ -0,0 +1,195 @@
+package seed
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"utility-backend/models"
+	"utility-backend/validation"
+)
+
+// industry describes typical usage of a kind of site. Chemical, energy and
+// steam usage are rates per m³ of water; effluent values are concentrations.
+type industry struct {
+	name        string
+	water       float64 // m³ a day
+	pac         float64
+	polymer     float64
+	chlorine    float64
+	causticSoda float64
+	electricity float64
+	steam       float64
+	bod         float64
+	cod         float64
+}
+
+var industries = []industry{
+	{name: "Manufacturing", water: 250, pac: 0.03, polymer: 0.012, chlorine: 0.005, electricity: 3.5, bod: 180, cod: 420},
+	{name: "Logistics", water: 80, pac: 0.02, polymer: 0.01, chlorine: 0.006, electricity: 1.5},
+	{name: "Electronics", water: 400, pac: 0.025, polymer: 0.01, chlorine: 0.004, causticSoda: 0.02, electricity: 5, bod: 60, cod: 150},
+	{name: "Services", water: 40, chlorine: 0.008, electricity: 8},
+	{name: "Food Processing", water: 600, pac: 0.04, polymer: 0.015, chlorine: 0.006, causticSoda: 0.015, electricity: 2.5, steam: 0.01, bod: 900, cod: 1800},
+	{name: "Textiles", water: 500, pac: 0.05, polymer: 0.02, chlorine: 0.003, causticSoda: 0.03, electricity: 3, steam: 0.008, bod: 300, cod: 800},
+	{name: "Chemicals", water: 300, pac: 0.035, polymer: 0.015, chlorine: 0.004, causticSoda: 0.04, electricity: 4, steam: 0.006, bod: 400, cod: 1200},
+}
+
+// classicSites are the sites the demo has always shown, kept as the first sites generated
+var classicSites = []models.Client{
+	{Name: "Site A (Manufacturing)", PlotNumber: "A-101", Industry: "Manufacturing", Status: "good", Latitude: 13.736717, Longitude: 100.523186, ContactName: "John Smith", Email: "john.smith@example.com", Phone: "+66 2 123 4567"},
+	{Name: "Site B (Storage)", PlotNumber: "B-201", Industry: "Logistics", Status: "warning", Latitude: 13.740061, Longitude: 100.529794, ContactName: "Jane Doe", Email: "jane.doe@example.com", Phone: "+66 2 234 5678"},
+	{Name: "Site C (Assembly)", PlotNumber: "C-301", Industry: "Electronics", Status: "good", Latitude: 13.731690, Longitude: 100.521126, ContactName: "Bob Johnson", Email: "bob.johnson@example.com", Phone: "+66 2 345 6789"},
+	{Name: "Site D (Office)", PlotNumber: "D-401", Industry: "Services", Status: "danger", Latitude: 13.746262, Longitude: 100.535211, ContactName: "Sarah Williams", Email: "sarah.williams@example.com", Phone: "+66 2 456 7890"},
+}
+
+var firstNames = []string{"Somchai", "Anong", "Krit", "Malee", "Niran", "Pimchanok", "Somsak", "Suda", "Thanawat", "Wanida", "Anan", "Kanya"}
+var lastNames = []string{"Srisuk", "Chaiyaporn", "Wongsawat", "Rattanakul", "Boonmee", "Thongdee", "Saetang", "Kittisak", "Phromma", "Jaidee"}
+
+// site is a generated client and the usage its readings vary around
+type site struct {
+	client   models.Client
+	industry industry
+	scale    float64 // multiplies the industry's water usage
+	growth   float64 // change in usage over a year
+}
+
+// generator produces the same sites and readings for the same seed
+type generator struct {
+	rng     *rand.Rand
+	catalog map[string]models.Metric
+	loc     *time.Location
+}
+
+func newGenerator(seed int64, catalog []models.Metric) *generator {
+	g := &generator{
+		rng:     rand.New(rand.NewSource(seed)),
+		catalog: map[string]models.Metric{},
+		loc:     validation.Location(validation.Timezone),
+	}
+	for _, metric := range catalog {
+		if metric.Active {
+			g.catalog[metric.Code] = metric
+		}
+	}
+	return g
+}
+
+// site generates the i-th site. The first sites are the classic demo sites.
+func (g *generator) site(i int) site {
+	var s site
+	if i < len(classicSites) {
+		s.client = classicSites[i]
+		for _, ind := range industries {
+			if ind.name == s.client.Industry {
+				s.industry = ind
+			}
+		}
+		if s.industry.name == "" {
+			s.industry = industries[0]
+		}
+	} else {
+		s.industry = industries[g.rng.Intn(len(industries))]
+		first := firstNames[g.rng.Intn(len(firstNames))]
+		last := lastNames[g.rng.Intn(len(lastNames))]
+		s.client = models.Client{
+			Name:        fmt.Sprintf("Site %03d (%s)", i+1, s.industry.name),
+			PlotNumber:  fmt.Sprintf("%c-%03d", 'A'+rune(i%8), 101+i),
+			Industry:    s.industry.name,
+			Status:      g.status(),
+			Latitude:    13.7387 + (g.rng.Float64()-0.5)*0.06,
+			Longitude:   100.5282 + (g.rng.Float64()-0.5)*0.06,
+			ContactName: first + " " + last,
+			Email:       fmt.Sprintf("%s.%s@example.com", strings.ToLower(first), strings.ToLower(last)),
+			Phone:       fmt.Sprintf("+66 2 %03d %04d", g.rng.Intn(1000), g.rng.Intn(10000)),
+		}
+	}
+	s.client.Timezone = g.loc.String()
+	s.scale = 0.6 + g.rng.Float64()*0.8
+	s.growth = -0.05 + g.rng.Float64()*0.13
+	return s
+}
+
+// status picks a site status, most sites being in good standing
+func (g *generator) status() string {
+	switch r := g.rng.Float64(); {
+	case r < 0.7:
+		return "good"
+	case r < 0.9:
+		return "warning"
+	default:
+		return "danger"
+	}
+}
+
+// readings generates perDay readings a day for a site from start to end
+// inclusive. Daily totals follow the season, the day of the week and the
+// site's growth with some noise and the odd spike; they are split evenly
+// across readings taken from 08:00 site time.
+func (g *generator) readings(s site, start, end models.Date, perDay int) []models.UtilityData {
+	var readings []models.UtilityData
+	interval := 12 * time.Hour / time.Duration(perDay)
+	total := end.Sub(start.Time).Hours() / 24
+
+	for day, n := start, 0; !day.After(end.Time); day, n = day.AddDays(1), n+1 {
+		// Usage peaks in the hot season around mid April
+		doy := float64(day.YearDay())
+		water := s.industry.water * s.scale *
+			(1 + 0.15*math.Cos(2*math.Pi*(doy-105)/365)) *
+			(1 + s.growth*(float64(n)-total)/365)
+		switch day.Weekday() {
+		case time.Sunday:
+			water *= 0.7
+		case time.Saturday:
+			water *= 0.85
+		}
+
+		for k := 0; k < perDay; k++ {
+			w := water / float64(perDay) * g.noise(0.06)
+			if g.rng.Float64() < 0.01 {
+				w *= 1.8
+			}
+
+			readAt := day.In(g.loc).Add(8*time.Hour + time.Duration(k)*interval +
+				time.Duration(g.rng.Intn(21)-10)*time.Minute)
+
+			reading := models.UtilityData{
+				ClientID: s.client.ID,
+				Date:     day,
+				ReadAt:   readAt.UTC(),
+				Timezone: g.loc.String(),
+			}
+			g.add(&reading, "water", w)
+			g.add(&reading, "pac", w*s.industry.pac*g.noise(0.1))
+			g.add(&reading, "polymer", w*s.industry.polymer*g.noise(0.1))
+			g.add(&reading, "chlorine", w*s.industry.chlorine*g.noise(0.1))
+			g.add(&reading, "caustic_soda", w*s.industry.causticSoda*g.noise(0.1))
+			g.add(&reading, "electricity", w*s.industry.electricity*g.noise(0.08))
+			g.add(&reading, "steam", w*s.industry.steam*g.noise(0.1))
+			g.add(&reading, "bod", s.industry.bod*g.noise(0.15))
+			g.add(&reading, "cod", s.industry.cod*g.noise(0.15))
+			readings = append(readings, reading)
+		}
+	}
+	return readings
+}
+
+// add records a positive value for a metric in the catalog, rounded to its precision
+func (g *generator) add(reading *models.UtilityData, code string, value float64) {
+	metric, ok := g.catalog[code]
+	if !ok || value <= 0 {
+		return
+	}
+	scale := math.Pow(10, float64(metric.Precision))
+	reading.Values = append(reading.Values, models.ReadingValue{
+		MetricCode: code,
+		Value:      math.Round(value*scale) / scale,
+		Unit:       metric.Unit,
+	})
+}
+
+// noise returns a factor around 1 with the given relative spread
+func (g *generator) noise(spread float64) float64 {
+	return math.Max(0, 1+g.rng.NormFloat64()*spread)
+}
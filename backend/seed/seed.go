@@ -0,0 +1,189 @@
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/anomaly"
+	"utility-backend/metrics"
+	"utility-backend/models"
+	"utility-backend/repository"
+	"utility-backend/validation"
+)
+
+// ErrNotEmpty is returned when seeding sites into a database that already has some
+var ErrNotEmpty = errors.New("database already has sites")
+
+// Profile describes a set of seed data
+type Profile struct {
+	Name           string
+	Description    string
+	Sites          int
+	Months         int
+	ReadingsPerDay int
+	DemoUsers      bool // demo accounts with well-known passwords instead of a single admin
+}
+
+// Profiles lists the available seed profiles
+var Profiles = []Profile{
+	{Name: "minimal", Description: "an admin account and the metric catalog, nothing else"},
+	{Name: "demo", Description: "demo accounts and four sites with six months of daily readings", Sites: 4, Months: 6, ReadingsPerDay: 1, DemoUsers: true},
+	{Name: "load-test", Description: "demo accounts and 100 sites with a year of readings four times a day", Sites: 100, Months: 12, ReadingsPerDay: 4, DemoUsers: true},
+}
+
+// FindProfile returns the profile with the given name
+func FindProfile(name string) (Profile, error) {
+	for _, p := range Profiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return Profile{}, fmt.Errorf("unknown seed profile %q", name)
+}
+
+// Options adjust a profile. Zero values keep the profile's defaults.
+type Options struct {
+	Sites         int
+	Months        int
+	Seed          int64       // random seed; the same seed, options and End give the same data
+	End           models.Date // last day with readings, yesterday at the default site timezone if zero
+	AdminPassword string      // password of the minimal profile's admin, generated if empty
+}
+
+// Result summarises what was seeded
+type Result struct {
+	Users         []string
+	AdminPassword string // set when a password was generated
+	Sites         int
+	Readings      int
+}
+
+// Run seeds the database with a profile
+func Run(db *gorm.DB, profile Profile, opts Options) (Result, error) {
+	var result Result
+	repos := repository.New(db)
+
+	if opts.Sites > 0 {
+		profile.Sites = opts.Sites
+	}
+	if opts.Months > 0 {
+		profile.Months = opts.Months
+	}
+	if profile.Sites > 0 && profile.ReadingsPerDay == 0 {
+		profile.ReadingsPerDay = 1
+	}
+	if opts.End.IsZero() {
+		loc := validation.Location(validation.Timezone)
+		opts.End = models.DateOf(time.Now().In(loc)).AddDays(-1)
+	}
+
+	if profile.Sites > 0 {
+		count, err := repos.Clients.Count()
+		if err != nil {
+			return result, err
+		}
+		if count > 0 {
+			return result, ErrNotEmpty
+		}
+	}
+
+	// Accounts
+	var users []models.User
+	if profile.DemoUsers {
+		users = []models.User{
+			{Username: "admin", Password: "admin", Role: "admin"},
+			{Username: "operator", Password: "operator", Role: "operator"},
+			{Username: "line_user", Password: "line_password", Role: "operator"},
+		}
+	} else {
+		password := opts.AdminPassword
+		if password == "" {
+			generated, err := models.GeneratePassword()
+			if err != nil {
+				return result, err
+			}
+			password = generated
+			result.AdminPassword = password
+		}
+		users = []models.User{{Username: "admin", Password: password, Role: "admin"}}
+	}
+	for _, user := range users {
+		exists, err := repos.Users.Exists(user.Username)
+		if err != nil {
+			return result, err
+		}
+		if exists {
+			continue
+		}
+		user.HashPassword()
+		if err := repos.Users.Create(&user); err != nil {
+			return result, err
+		}
+		result.Users = append(result.Users, user.Username)
+	}
+	if len(result.Users) == 0 {
+		result.AdminPassword = ""
+	}
+
+	if profile.Sites == 0 {
+		return result, nil
+	}
+
+	catalog, err := metrics.All(db)
+	if err != nil {
+		return result, err
+	}
+	var recorder *uint
+	if operator, err := repos.Users.FirstWithRole("operator"); err == nil {
+		recorder = &operator.ID
+	}
+
+	g := newGenerator(opts.Seed, catalog)
+	start := opts.End.AddDate(0, -profile.Months, 0)
+	for i := 0; i < profile.Sites; i++ {
+		site := g.site(i)
+		if err := repos.Clients.Create(&site.client); err != nil {
+			return result, err
+		}
+
+		readings := g.readings(site, models.DateOf(start).AddDays(1), opts.End, profile.ReadingsPerDay)
+		for j := range readings {
+			readings[j].RecordedBy = recorder
+		}
+		if err := repos.Readings.CreateInBatches(readings, 500); err != nil {
+			return result, err
+		}
+		// Baselines are learned as of the day after the last reading
+		next := opts.End.AddDays(1).In(validation.Location(site.client.Timezone))
+		if _, err := anomaly.Learn(db, site.client.ID, next); err != nil {
+			return result, err
+		}
+
+		result.Sites++
+		result.Readings += len(readings)
+	}
+
+	return result, nil
+}
+
+// IfEmpty seeds the demo profile into a database without any users, for
+// development. It must not be used in production.
+func IfEmpty(db *gorm.DB) error {
+	count, err := repository.NewUsers(db).Count()
+	if err != nil || count > 0 {
+		return err
+	}
+
+	slog.Info("Seeding demo data")
+	profile, _ := FindProfile("demo")
+	result, err := Run(db, profile, Options{Seed: 1})
+	if err != nil {
+		return err
+	}
+	slog.Info("Seeded demo data", "sites", result.Sites, "readings", result.Readings)
+	return nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"utility-backend/cache"
+	"utility-backend/config"
+	"utility-backend/handlers"
+	"utility-backend/middlewares"
+	"utility-backend/monitoring"
+	"utility-backend/notifications"
+	"utility-backend/scheduler"
+	"utility-backend/webhooks"
+)
+
+// server is the API app and the background workers started with it
+type server struct {
+	app     *fiber.App
+	workers sync.WaitGroup
+}
+
+// newServer sets up the API routes on a new app and starts the background
+// workers, which stop once ctx is done. The app is left to be listened on.
+func newServer(ctx context.Context, cfg *config.Config, db *gorm.DB) (*server, error) {
+	mon, err := monitoring.New(db)
+	if err != nil {
+		return nil, fmt.Errorf("setting up metrics: %w", err)
+	}
+
+	srv := &server{}
+	startWorker := func(run func(ctx context.Context)) {
+		srv.workers.Add(1)
+		go func() {
+			defer srv.workers.Done()
+			run(ctx)
+		}()
+	}
+
+	// Start the background jobs and webhook delivery. Each worker beats a
+	// heartbeat that the readiness check watches.
+	digests := notifications.NewSender(cfg.SMTP, cfg.AppBaseURL)
+	if !digests.Enabled() {
+		slog.Info("SMTP_HOST not set, email notifications are disabled")
+	}
+	locker, err := scheduler.NewLocker(db, cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+	sched, err := scheduler.New(db, cfg.Scheduler, locker, mon.Worker("scheduler", 3*time.Minute))
+	if err != nil {
+		return nil, err
+	}
+	// Dashboard, map and site responses are cached until their sites' data changes
+	var responses cache.Store
+	if cfg.Cache.TTL > 0 {
+		responses = cache.NewMemory(cfg.Cache.MaxEntries)
+	}
+	hooks := webhooks.NewDispatcher(db)
+	if err := registerJobs(sched, digests, hooks, responses); err != nil {
+		return nil, err
+	}
+	if !cfg.Scheduler.Enabled {
+		slog.Info("SCHEDULER_ENABLED is false, jobs only run when triggered")
+	}
+	startWorker(sched.Run)
+
+	hb := mon.Worker("webhook dispatcher", 2*time.Minute)
+	startWorker(func(ctx context.Context) { hooks.Run(ctx, hb) })
+
+	// Create Fiber app
+	app := fiber.New(fiber.Config{
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			code := fiber.StatusInternalServerError
+
+			if e, ok := err.(*fiber.Error); ok {
+				code = e.Code
+			}
+
+			return c.Status(code).JSON(fiber.Map{
+				"success": false,
+				"message": err.Error(),
+				"data":    nil,
+			})
+		},
+	})
+
+	// Middleware; every request gets an ID that its log records share
+	app.Use(middlewares.RequestID())
+	app.Use(middlewares.RequestLog())
+	app.Use(mon.Middleware())
+
+	// Setup CORS
+	handlers.SetupCORS(app)
+
+	// API routes
+	secret := []byte(cfg.JWTSecret)
+	h := handlers.New(db, secret, mon, sched, responses, digests, hooks)
+
+	// Probes and metrics live outside /api and need no login
+	app.Get("/healthz", h.Live)
+	app.Get("/readyz", h.Ready)
+	app.Get("/metrics", mon.Handler(cfg.MetricsToken))
+
+	api := app.Group("/api")
+
+	// Public routes; /api/health is kept as an alias of /readyz
+	api.Get("/health", h.Ready)
+	api.Post("/login", h.Login)
+	api.Get("/unsubscribe/:token", h.UnsubscribePage)
+	api.Post("/unsubscribe/:token", middlewares.Audit(db), h.Unsubscribe)
+
+	// Protected routes
+	api.Use(middlewares.AuthRequired(secret))
+	api.Use(middlewares.Audit(db))
+	api.Get("/dashboard", middlewares.Cache(responses, cfg.Cache.TTL, middlewares.AllSites), h.GetDashboardData)
+	api.Post("/submit-data", h.SubmitData)
+	api.Get("/map-data", middlewares.Cache(responses, cfg.Cache.TTL, middlewares.AllSites), h.GetMapData)
+	api.Get("/export", h.ExportReadings)
+	api.Get("/utility-data/flagged", h.ListFlaggedReadings)
+	api.Put("/utility-data/:id", middlewares.OperatorOrAdmin, h.UpdateReading)
+	api.Post("/utility-data/:id/void", middlewares.OperatorOrAdmin, h.VoidReading)
+	api.Get("/corrections", h.ListCorrections)
+	api.Post("/corrections/:id/approve", middlewares.AdminOnly, h.ApproveCorrection)
+	api.Post("/corrections/:id/reject", middlewares.AdminOnly, h.RejectCorrection)
+	api.Get("/clients/:id", middlewares.Cache(responses, cfg.Cache.TTL, middlewares.SiteParam), h.GetClient)
+	api.Get("/clients/:id/readings", middlewares.Cache(responses, cfg.Cache.TTL, middlewares.SiteParam), h.ListClientReadings)
+	api.Get("/clients/:id/baselines", h.ListBaselines)
+	api.Put("/clients/:id/status", middlewares.AdminOnly, h.UpdateClientStatus)
+	api.Get("/alerts", h.ListAlerts)
+	api.Post("/alerts", middlewares.OperatorOrAdmin, h.CreateAlert)
+	api.Post("/alerts/:id/resolve", middlewares.OperatorOrAdmin, h.ResolveAlert)
+
+	// Metric catalog
+	api.Get("/metrics", h.ListMetrics)
+	api.Post("/metrics", middlewares.AdminOnly, h.CreateMetric)
+	api.Put("/metrics/:code", middlewares.AdminOnly, h.UpdateMetric)
+	api.Get("/units", h.ListUnits)
+
+	// Reading validation rules
+	api.Get("/validation-rules", h.ListValidationRules)
+	api.Put("/validation-rules", middlewares.AdminOnly, h.SaveValidationRule)
+	api.Delete("/validation-rules/:id", middlewares.AdminOnly, h.DeleteValidationRule)
+
+	// Audit log
+	api.Get("/audit", middlewares.AdminOnly, h.ListAuditEntries)
+
+	// Email digest administration
+	api.Get("/digests/subscriptions", middlewares.AdminOnly, h.ListDigestSubscriptions)
+	api.Post("/digests/subscriptions", middlewares.AdminOnly, h.CreateDigestSubscription)
+	api.Delete("/digests/subscriptions/:id", middlewares.AdminOnly, h.DeleteDigestSubscription)
+	api.Post("/digests/subscriptions/:id/send", middlewares.AdminOnly, h.SendDigestNow)
+	api.Get("/digests/log", middlewares.AdminOnly, h.ListEmailLog)
+
+	// Background jobs
+	api.Get("/jobs", middlewares.AdminOnly, h.ListJobs)
+	api.Get("/jobs/runs", middlewares.AdminOnly, h.ListJobRuns)
+	api.Post("/jobs/:name/run", middlewares.AdminOnly, h.RunJob)
+
+	// Outbound webhook administration
+	api.Get("/webhooks", middlewares.AdminOnly, h.ListWebhooks)
+	api.Post("/webhooks", middlewares.AdminOnly, h.CreateWebhook)
+	api.Put("/webhooks/:id", middlewares.AdminOnly, h.UpdateWebhook)
+	api.Delete("/webhooks/:id", middlewares.AdminOnly, h.DeleteWebhook)
+	api.Get("/webhook-deliveries", middlewares.AdminOnly, h.ListWebhookDeliveries)
+	api.Get("/webhook-deliveries/dead-letters", middlewares.AdminOnly, h.ListDeadLetters)
+	api.Post("/webhook-deliveries/:id/redeliver", middlewares.AdminOnly, h.RedeliverWebhook)
+
+	srv.app = app
+	return srv, nil
+}
+
+// shutdown stops accepting connections and waits for in-flight requests and
+// the background workers to finish, then closes the database pool. Requests
+// still running after timeout are cut off, and workers are no longer waited for.
+func shutdown(app *fiber.App, db *gorm.DB, workers *sync.WaitGroup, timeout time.Duration) error {
+	slog.Info("Shutting down, draining in-flight requests", "timeout", timeout.String())
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	// The workers were told to stop with the signal and finish meanwhile
+	stopped := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(stopped)
+	}()
+
+	if err := app.ShutdownWithTimeout(timeout); err != nil {
+		slog.Warn("Requests were still running at the shutdown timeout", "error", err)
+	}
+
+	select {
+	case <-stopped:
+	case <-deadline.C:
+		select {
+		case <-stopped:
+		default:
+			slog.Warn("Background workers were still running at the shutdown timeout")
+		}
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err := sqlDB.Close(); err != nil {
+		return fmt.Errorf("closing the database: %w", err)
+	}
+	slog.Info("Shutdown complete")
+	return nil
+}
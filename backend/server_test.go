@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"utility-backend/config"
+	"utility-backend/database"
+	"utility-backend/models"
+)
+
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	cfg, err := config.Load("", map[string]string{
+		"DB_TYPE":           "sqlite",
+		"DB_PATH":           filepath.Join(t.TempDir(), "utility.db"),
+		"SCHEDULER_ENABLED": "false",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv, err := newServer(ctx, cfg, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The slow request queries the database once it has been kept waiting,
+	// which fails if the pool was closed under it
+	started := make(chan struct{})
+	finished := make(chan time.Time, 1)
+	srv.app.Get("/slow", func(c *fiber.Ctx) error {
+		close(started)
+		time.Sleep(500 * time.Millisecond)
+		var users int64
+		if err := db.Model(&models.User{}).Count(&users).Error; err != nil {
+			return err
+		}
+		finished <- time.Now()
+		return c.SendString("done")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.app.Listener(ln)
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{resp.StatusCode, string(body), err}
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the slow request never started")
+	}
+
+	// As on SIGTERM: the workers are told to stop, then the server shuts down
+	cancel()
+	if err := shutdown(srv.app, db, &srv.workers, 5*time.Second); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	closedAt := time.Now()
+
+	res := <-responses
+	if res.err != nil {
+		t.Fatalf("in-flight request failed: %v", res.err)
+	}
+	if res.status != http.StatusOK || res.body != "done" {
+		t.Fatalf("in-flight request got %d %q, want 200 \"done\"", res.status, res.body)
+	}
+	select {
+	case at := <-finished:
+		if at.After(closedAt) {
+			t.Errorf("the request finished at %s, after shutdown returned at %s", at, closedAt)
+		}
+	default:
+		t.Fatal("the request did not finish its database query")
+	}
+	if err := sqlDB.Ping(); err == nil {
+		t.Error("the database is still open after shutdown")
+	}
+}
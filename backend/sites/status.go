@@ -0,0 +1,134 @@
+package sites
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/models"
+	"utility-backend/repository"
+	"utility-backend/validation"
+	"utility-backend/webhooks"
+)
+
+// Site statuses, from best to worst
+const (
+	StatusGood    = "good"
+	StatusWarning = "warning"
+	StatusDanger  = "danger"
+)
+
+// FlaggedDays is how many recent days of readings flagged as unusual put a site on warning
+const FlaggedDays = 7
+
+// ManualHold is how long a status set by hand is kept before it is
+// recomputed from the site's alerts and readings again
+const ManualHold = 24 * time.Hour
+
+// IsStatus reports whether s is a valid site status
+func IsStatus(s string) bool {
+	return s == StatusGood || s == StatusWarning || s == StatusDanger
+}
+
+// ComputeStatus works out a site's status from its open alerts and recent
+// readings: danger with an open danger alert, warning with an open warning
+// alert or a reading flagged as unusual in the last FlaggedDays days, and
+// good otherwise.
+func ComputeStatus(db *gorm.DB, client models.Client, now time.Time) (string, error) {
+	types, err := repository.NewAlerts(db).OpenTypes(client.ID)
+	if err != nil {
+		return "", err
+	}
+	status := StatusGood
+	for _, t := range types {
+		switch t {
+		case StatusDanger:
+			return StatusDanger, nil
+		case StatusWarning:
+			status = StatusWarning
+		}
+	}
+	if status == StatusWarning {
+		return status, nil
+	}
+
+	since := models.DateOf(now.In(validation.Location(client.Timezone))).AddDays(-FlaggedDays + 1)
+	flagged, err := repository.NewReadings(db).CountFlaggedSince(client.ID, since)
+	if err != nil {
+		return "", err
+	}
+	if flagged > 0 {
+		return StatusWarning, nil
+	}
+	return StatusGood, nil
+}
+
+// SetManualStatus changes a site's status as SetStatus does and holds it
+// for ManualHold from now, even if it is unchanged
+func SetManualStatus(db *gorm.DB, hooks *webhooks.Dispatcher, client *models.Client, status string, now time.Time) error {
+	if err := SetStatus(db, hooks, client, status); err != nil {
+		return err
+	}
+	return repository.NewClients(db).HoldStatus(client, now.Add(ManualHold))
+}
+
+// SetStatus changes a site's status and notifies webhook subscribers through
+// hooks. It does nothing if the status is unchanged.
+func SetStatus(db *gorm.DB, hooks *webhooks.Dispatcher, client *models.Client, status string) error {
+	previous := client.Status
+	if previous == status {
+		return nil
+	}
+	if err := repository.NewClients(db).UpdateStatus(client, status); err != nil {
+		return err
+	}
+
+	hooks.Publish(db, webhooks.EventClientStatusChanged, map[string]interface{}{
+		"clientId":       client.ID,
+		"name":           client.Name,
+		"previousStatus": previous,
+		"status":         status,
+	})
+	return nil
+}
+
+// Change is a site status change made, or that would be made, by Recompute
+type Change struct {
+	Client   models.Client
+	Previous string
+	Status   string
+}
+
+// Recompute works out the status of each of clients and updates those that
+// changed, leaving out sites whose status set by hand is still held, calling record with each site before and after its change so the
+// caller can audit it. With dryRun nothing is updated or recorded. The
+// changes are returned either way.
+func Recompute(db *gorm.DB, hooks *webhooks.Dispatcher, clients []models.Client, now time.Time, dryRun bool,
+	record func(before, after models.Client) error) ([]Change, error) {
+	var changes []Change
+	for _, client := range clients {
+		if client.StatusHeldUntil != nil && now.Before(*client.StatusHeldUntil) {
+			continue
+		}
+		status, err := ComputeStatus(db, client, now)
+		if err != nil {
+			return changes, err
+		}
+		if status == client.Status {
+			continue
+		}
+
+		changes = append(changes, Change{Client: client, Previous: client.Status, Status: status})
+		if dryRun {
+			continue
+		}
+		before := client
+		if err := SetStatus(db, hooks, &client, status); err != nil {
+			return changes, err
+		}
+		if err := record(before, client); err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"utility-backend/audit"
+	"utility-backend/config"
+	"utility-backend/database"
+	"utility-backend/models"
+	"utility-backend/repository"
+	"utility-backend/sites"
+	"utility-backend/webhooks"
+)
+
+// runRecomputeStatus handles the recompute-status subcommand:
+//
+//	recompute-status [-client id] [-dry-run]
+func runRecomputeStatus(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("recompute-status", flag.ContinueOnError)
+	clientID := flags.Uint("client", 0, "only recompute this site")
+	dryRun := flags.Bool("dry-run", false, "list the changes without making them")
+	flags.Usage = usageFunc(flags, "recompute-status [-client id] [-dry-run]")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+
+	repo := repository.NewClients(db)
+	var clients []models.Client
+	if *clientID > 0 {
+		client, err := repo.Find(*clientID)
+		if err != nil {
+			return fmt.Errorf("site %d not found", *clientID)
+		}
+		clients = append(clients, client)
+	} else if clients, err = repo.List(); err != nil {
+		return err
+	}
+
+	// Status changes are queued for the server's dispatcher to deliver
+	hooks := webhooks.NewDispatcher(db)
+	changes, err := sites.Recompute(db, hooks, clients, time.Now(), *dryRun, func(before, after models.Client) error {
+		return audit.RecordCommand(db, "recompute-status", "client.status_change", "client", after.ID, before, after)
+	})
+	for _, change := range changes {
+		fmt.Printf("%-5d %-30s %s -> %s\n", change.Client.ID, change.Client.Name, change.Previous, change.Status)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		fmt.Printf("%d of %d sites would change\n", len(changes), len(clients))
+	} else {
+		fmt.Printf("%d of %d sites changed\n", len(changes), len(clients))
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package units
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Dimensions a unit can measure
+const (
+	Volume        = "volume"
+	Mass          = "mass"
+	Energy        = "energy"
+	Concentration = "concentration"
+)
+
+// ErrUnknownUnit is returned for a unit symbol that is not supported
+var ErrUnknownUnit = errors.New("unknown unit")
+
+// Unit is a supported unit of measure
+type Unit struct {
+	Symbol    string   `json:"symbol"`
+	Name      string   `json:"name"`
+	Dimension string   `json:"dimension"`
+	Factor    float64  `json:"-"` // size of the unit in the dimension's base unit
+	Aliases   []string `json:"aliases,omitempty"`
+}
+
+// All lists the supported units. Factors are relative to m³ for volume, kg for
+// mass, kWh for energy and mg/L for concentration.
+var All = []Unit{
+	{Symbol: "m³", Name: "cubic metre", Dimension: Volume, Factor: 1, Aliases: []string{"m3", "cbm"}},
+	{Symbol: "L", Name: "litre", Dimension: Volume, Factor: 0.001, Aliases: []string{"l", "litre", "liter", "litres", "liters"}},
+	{Symbol: "kL", Name: "kilolitre", Dimension: Volume, Factor: 1, Aliases: []string{"kl"}},
+	{Symbol: "gal", Name: "US gallon", Dimension: Volume, Factor: 0.003785411784, Aliases: []string{"gallon", "gallons", "usgal"}},
+	{Symbol: "kg", Name: "kilogram", Dimension: Mass, Factor: 1},
+	{Symbol: "g", Name: "gram", Dimension: Mass, Factor: 0.001},
+	{Symbol: "t", Name: "tonne", Dimension: Mass, Factor: 1000, Aliases: []string{"tonne", "tonnes", "ton"}},
+	{Symbol: "lb", Name: "pound", Dimension: Mass, Factor: 0.45359237, Aliases: []string{"lbs", "pound", "pounds"}},
+	{Symbol: "kWh", Name: "kilowatt hour", Dimension: Energy, Factor: 1, Aliases: []string{"kwh"}},
+	{Symbol: "MWh", Name: "megawatt hour", Dimension: Energy, Factor: 1000, Aliases: []string{"mwh"}},
+	{Symbol: "MJ", Name: "megajoule", Dimension: Energy, Factor: 1 / 3.6, Aliases: []string{"mj"}},
+	{Symbol: "GJ", Name: "gigajoule", Dimension: Energy, Factor: 1000 / 3.6, Aliases: []string{"gj"}},
+	{Symbol: "mg/L", Name: "milligram per litre", Dimension: Concentration, Factor: 1, Aliases: []string{"mg/l", "ppm"}},
+	{Symbol: "g/L", Name: "gram per litre", Dimension: Concentration, Factor: 1000, Aliases: []string{"g/l"}},
+}
+
+// Lookup finds a unit by symbol or alias
+func Lookup(symbol string) (Unit, error) {
+	s := strings.TrimSpace(symbol)
+	for _, u := range All {
+		if u.Symbol == s {
+			return u, nil
+		}
+	}
+	for _, u := range All {
+		for _, alias := range u.Aliases {
+			if strings.EqualFold(alias, s) {
+				return u, nil
+			}
+		}
+	}
+	return Unit{}, fmt.Errorf("%w %q", ErrUnknownUnit, symbol)
+}
+
+// Convert converts value from one unit to another. Converting between volume
+// and mass needs the density of the liquid in kg/L; pass nil when there is none.
+func Convert(value float64, from, to string, density *float64) (float64, error) {
+	src, err := Lookup(from)
+	if err != nil {
+		return 0, err
+	}
+	dst, err := Lookup(to)
+	if err != nil {
+		return 0, err
+	}
+
+	if src.Dimension == dst.Dimension {
+		return value * src.Factor / dst.Factor, nil
+	}
+
+	// Volume and mass are related by density: 1 m³ at d kg/L weighs 1000·d kg
+	if density != nil && *density > 0 {
+		switch {
+		case src.Dimension == Volume && dst.Dimension == Mass:
+			return value * src.Factor * 1000 * *density / dst.Factor, nil
+		case src.Dimension == Mass && dst.Dimension == Volume:
+			return value * src.Factor / (1000 * *density) / dst.Factor, nil
+		}
+	}
+
+	return 0, fmt.Errorf("cannot convert %s to %s", src.Symbol, dst.Symbol)
+}
+
+// Compatible reports whether values in unit from can be converted to unit to
+func Compatible(from, to string, density *float64) bool {
+	_, err := Convert(1, from, to, density)
+	return err == nil
+}
@@ -0,0 +1,77 @@
+package units
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	pac := 1.2
+
+	tests := []struct {
+		name     string
+		value    float64
+		from, to string
+		density  *float64
+		want     float64
+	}{
+		{"same unit", 12.5, "m³", "m³", nil, 12.5},
+		{"litres to cubic metres", 1500, "L", "m³", nil, 1.5},
+		{"gallons to litres", 1, "gal", "L", nil, 3.785411784},
+		{"alias", 2, "m3", "kl", nil, 2},
+		{"grams to kilograms", 2500, "g", "kg", nil, 2.5},
+		{"tonnes to pounds", 1, "t", "lb", nil, 2204.6226218},
+		{"megajoules to kilowatt hours", 36, "MJ", "kWh", nil, 10},
+		{"grams per litre to ppm", 0.5, "g/L", "ppm", nil, 500},
+		{"litres to kilograms by density", 100, "L", "kg", &pac, 120},
+		{"cubic metres to tonnes by density", 2, "m³", "t", &pac, 2.4},
+		{"kilograms to litres by density", 120, "kg", "L", &pac, 100},
+		{"same dimension ignores density", 1000, "L", "m³", &pac, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Convert(tt.value, tt.from, tt.to, tt.density)
+			if err != nil {
+				t.Fatalf("Convert(%v, %q, %q) failed: %v", tt.value, tt.from, tt.to, err)
+			}
+			if math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("Convert(%v, %q, %q) = %v, want %v", tt.value, tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertFails(t *testing.T) {
+	pac := 1.2
+	zero := 0.0
+
+	tests := []struct {
+		name     string
+		from, to string
+		density  *float64
+		unknown  bool
+	}{
+		{"volume to mass without density", "L", "kg", nil, false},
+		{"mass to volume without density", "kg", "m³", nil, false},
+		{"zero density", "L", "kg", &zero, false},
+		{"energy to mass", "kWh", "kg", &pac, false},
+		{"concentration to volume", "mg/L", "L", &pac, false},
+		{"unknown source", "bbl", "m³", nil, true},
+		{"unknown target", "m³", "acre-ft", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Convert(1, tt.from, tt.to, tt.density)
+			if err == nil {
+				t.Fatalf("Convert(1, %q, %q) succeeded, want an error", tt.from, tt.to)
+			}
+			if errors.Is(err, ErrUnknownUnit) != tt.unknown {
+				t.Errorf("Convert(1, %q, %q) = %v, unknown unit: %v", tt.from, tt.to, err, tt.unknown)
+			}
+			if Compatible(tt.from, tt.to, tt.density) {
+				t.Errorf("Compatible(%q, %q) = true", tt.from, tt.to)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"utility-backend/audit"
+	"utility-backend/config"
+	"utility-backend/database"
+	"utility-backend/models"
+	"utility-backend/repository"
+)
+
+// userRoles are the roles an account can have
+var userRoles = []string{"admin", "operator"}
+
+// runUser handles the user subcommand:
+//
+//	user create <username> [-role operator] [-password p]
+//	user reset-password <username> [-password p]
+//
+// A password is generated and printed when none is given.
+func runUser(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("user", flag.ContinueOnError)
+	role := flags.String("role", "operator", "role of a new user: "+strings.Join(userRoles, ", "))
+	password := flags.String("password", "", "password to set (default generated and printed)")
+	flags.Usage = usageFunc(flags,
+		"user create <username> [-role operator] [-password p]",
+		"user reset-password <username> [-password p]")
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		flags.Usage()
+		return errors.New("expected a subcommand and a username")
+	}
+	action, username := positional[0], strings.TrimSpace(positional[1])
+	if action != "create" && action != "reset-password" {
+		flags.Usage()
+		return fmt.Errorf("unknown user subcommand %q", action)
+	}
+	if username == "" {
+		return errors.New("username cannot be empty")
+	}
+
+	generated := *password == ""
+	if generated {
+		if *password, err = models.GeneratePassword(); err != nil {
+			return err
+		}
+	}
+
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+
+	users := repository.NewUsers(db)
+	var user models.User
+	switch action {
+	case "create":
+		valid := false
+		for _, r := range userRoles {
+			valid = valid || r == *role
+		}
+		if !valid {
+			return fmt.Errorf("invalid role %q, use %s", *role, strings.Join(userRoles, " or "))
+		}
+
+		exists, err := users.Exists(username)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("user %s already exists", username)
+		}
+
+		user = models.User{Username: username, Password: *password, Role: *role}
+		user.HashPassword()
+		if err := users.Create(&user); err != nil {
+			return err
+		}
+		if err := audit.RecordCommand(db, "user create", "user.create", "user", user.ID, nil, user); err != nil {
+			return err
+		}
+		fmt.Printf("Created %s %s\n", user.Role, user.Username)
+
+	case "reset-password":
+		if user, err = users.FindByUsername(username); err != nil {
+			return fmt.Errorf("user %s not found", username)
+		}
+
+		user.Password = *password
+		user.HashPassword()
+		if err := users.UpdatePassword(&user); err != nil {
+			return err
+		}
+		// The password itself is never part of the audit snapshot
+		if err := audit.RecordCommand(db, "user reset-password", "user.password_reset", "user", user.ID, nil, nil); err != nil {
+			return err
+		}
+		fmt.Printf("Reset the password of %s\n", user.Username)
+	}
+
+	if generated {
+		fmt.Printf("Password: %s\n", *password)
+	}
+	return nil
+}
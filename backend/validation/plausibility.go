@@ -0,0 +1,102 @@
+package validation
+
+import (
+	"fmt"
+	"math"
+
+	"gorm.io/gorm"
+
+	"utility-backend/metrics"
+	"utility-backend/models"
+)
+
+// CodeOutlier marks a value that is valid but unusual for the site
+const CodeOutlier = "outlier"
+
+// Plausibility check settings
+const (
+	HistoryDays      = 30 // days of history the value is compared against
+	MinHistory       = 7  // fewer readings than this are not enough to judge
+	OutlierThreshold = 3  // standard deviations from the mean
+)
+
+// Warning describes a value that passed validation but is far from the site's recent history
+type Warning struct {
+	Field   string  `json:"field"`
+	Code    string  `json:"code"`
+	Message string  `json:"message"`
+	Value   float64 `json:"value"`
+	Mean    float64 `json:"mean"`
+	StdDev  float64 `json:"stdDev"`
+}
+
+// meanStdDev returns the mean and population standard deviation of values
+func meanStdDev(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)))
+}
+
+// CheckPlausibility compares each value with the site's readings of the same
+// metric in the HistoryDays before date and warns about values more than
+// OutlierThreshold standard deviations from the mean. Metrics with too little
+// or constant history are not checked.
+func CheckPlausibility(db *gorm.DB, clientID uint, date models.Date, values map[string]float64) ([]Warning, error) {
+	from := date.AddDays(-HistoryDays)
+
+	catalog, err := metrics.Catalog(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		MetricCode string
+		Value      float64
+	}
+	err = db.Table("reading_values").
+		Select("reading_values.metric_code, reading_values.value").
+		Joins("JOIN utility_data ON utility_data.id = reading_values.utility_data_id").
+		Where("utility_data.client_id = ? AND utility_data.date >= ? AND utility_data.date < ? AND utility_data.deleted_at IS NULL",
+			clientID, from, date).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	history := map[string][]float64{}
+	for _, row := range rows {
+		history[row.MetricCode] = append(history[row.MetricCode], row.Value)
+	}
+
+	var warnings []Warning
+	for _, m := range catalog {
+		value, ok := values[m.Code]
+		if !ok || len(history[m.Code]) < MinHistory {
+			continue
+		}
+
+		mean, stdDev := meanStdDev(history[m.Code])
+		if stdDev == 0 || math.Abs(value-mean) <= OutlierThreshold*stdDev {
+			continue
+		}
+
+		warnings = append(warnings, Warning{
+			Field:   metrics.FieldName(m.Code),
+			Code:    CodeOutlier,
+			Message: fmt.Sprintf("Value is unusual for this site: the %d-day average is %.*f %s", HistoryDays, m.Precision, mean, m.Unit),
+			Value:   value,
+			Mean:    mean,
+			StdDev:  stdDev,
+		})
+	}
+
+	return warnings, nil
+}
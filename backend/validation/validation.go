@@ -0,0 +1,223 @@
+package validation
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/metrics"
+	"utility-backend/models"
+)
+
+// Timezone is the default local time of the sites
+const Timezone = "Asia/Bangkok"
+
+// maxClockSkew is how far in the future a reading time may be, to allow for device clocks
+const maxClockSkew = 5 * time.Minute
+
+// Error codes returned in field errors
+const (
+	CodeRequired     = "required"
+	CodeInvalidDate  = "invalid_date"
+	CodeFutureDate   = "future_date"
+	CodeInvalidTime  = "invalid_time"
+	CodeFutureTime   = "future_time"
+	CodeNegative     = "negative"
+	CodeOutOfRange   = "out_of_range"
+	CodeUnknown      = "unknown_metric"
+	CodeInvalidUnit  = "invalid_unit"
+	CodeInvalidEmail = "invalid_email"
+)
+
+// Range is the plausible range of a metric at a site
+type Range struct {
+	Min  float64  `json:"min"`
+	Max  *float64 `json:"max"` // nil means no upper limit
+	Unit string   `json:"unit"`
+}
+
+// FieldError describes why a single request field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Location returns the named time zone, falling back to the default site time zone
+func Location(name string) *time.Location {
+	if name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	loc, err := time.LoadLocation(Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ValidateReadAt parses an RFC 3339 reading time, which must carry a UTC
+// offset, and checks that it is not in the future
+func ValidateReadAt(field, value string, now time.Time) (time.Time, []FieldError) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, []FieldError{{Field: field, Code: CodeInvalidTime, Message: "Time must be an RFC 3339 timestamp with a UTC offset, e.g. 2023-03-01T08:00:00+07:00"}}
+	}
+	if t.After(now.Add(maxClockSkew)) {
+		return t, []FieldError{{Field: field, Code: CodeFutureTime, Message: "Time is in the future"}}
+	}
+	return t, nil
+}
+
+// ValidateDate parses a reading date and checks that it is present, well
+// formed and not in the future at the site's local time
+func ValidateDate(field, value string, now time.Time, loc *time.Location) (models.Date, []FieldError) {
+	if value == "" {
+		return models.Date{}, []FieldError{{Field: field, Code: CodeRequired, Message: "Date is required"}}
+	}
+
+	date, err := models.ParseDate(value)
+	if err != nil {
+		return date, []FieldError{{Field: field, Code: CodeInvalidDate, Message: "Date must be a valid date in YYYY-MM-DD format"}}
+	}
+
+	if date.After(models.DateOf(now.In(loc)).Time) {
+		return date, []FieldError{{
+			Field:   field,
+			Code:    CodeFutureDate,
+			Message: fmt.Sprintf("Date %s is in the future", date.Format("Jan 2, 2006")),
+		}}
+	}
+
+	return date, nil
+}
+
+// Ranges returns the plausible range of every active metric for a site. Site
+// rules take precedence over global rules, which take precedence over the
+// defaults in the metric catalog.
+func Ranges(db *gorm.DB, clientID uint) (map[string]Range, error) {
+	_, ranges, err := catalogRanges(db, clientID)
+	return ranges, err
+}
+
+// catalogRanges returns the active metrics and their ranges for a site
+func catalogRanges(db *gorm.DB, clientID uint) ([]models.Metric, map[string]Range, error) {
+	catalog, err := metrics.Catalog(db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ranges := map[string]Range{}
+	for _, m := range catalog {
+		r := Range{Max: m.Max, Unit: m.Unit}
+		if m.Min != nil {
+			r.Min = *m.Min
+		}
+		ranges[m.Code] = r
+	}
+
+	var rules []models.ValidationRule
+	err = db.Where("client_id IS NULL OR client_id = ?", clientID).
+		Order("client_id").Find(&rules).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Global rules are applied first, so site rules win
+	apply := func(rule models.ValidationRule) {
+		r, ok := ranges[rule.Metric]
+		if !ok {
+			return
+		}
+		if rule.Min != nil {
+			r.Min = *rule.Min
+		}
+		if rule.Max != nil {
+			r.Max = rule.Max
+		}
+		ranges[rule.Metric] = r
+	}
+	for _, rule := range rules {
+		if rule.ClientID == nil {
+			apply(rule)
+		}
+	}
+	for _, rule := range rules {
+		if rule.ClientID != nil {
+			apply(rule)
+		}
+	}
+
+	return catalog, ranges, nil
+}
+
+// ValidateValues checks that each value belongs to an active metric and lies
+// within the site's plausible range. Values are keyed by metric code.
+func ValidateValues(db *gorm.DB, clientID uint, values map[string]float64) ([]FieldError, error) {
+	catalog, ranges, err := catalogRanges(db, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Report fields in catalog order, followed by unknown metrics
+	var codes, unknown []string
+	for _, m := range catalog {
+		if _, ok := values[m.Code]; ok {
+			codes = append(codes, m.Code)
+		}
+	}
+	for code := range values {
+		if _, ok := ranges[code]; !ok {
+			unknown = append(unknown, code)
+		}
+	}
+	sort.Strings(unknown)
+	codes = append(codes, unknown...)
+
+	var errs []FieldError
+	for _, code := range codes {
+		value := values[code]
+		field := metrics.FieldName(code)
+
+		limit, ok := ranges[code]
+		switch {
+		case !ok:
+			errs = append(errs, FieldError{
+				Field:   field,
+				Code:    CodeUnknown,
+				Message: fmt.Sprintf("Unknown or inactive metric %q", code),
+			})
+		case value < 0:
+			errs = append(errs, FieldError{
+				Field:   field,
+				Code:    CodeNegative,
+				Message: "Value cannot be negative",
+			})
+		case value < limit.Min:
+			errs = append(errs, FieldError{
+				Field:   field,
+				Code:    CodeOutOfRange,
+				Message: outOfRangeMessage(limit),
+			})
+		case limit.Max != nil && value > *limit.Max:
+			errs = append(errs, FieldError{
+				Field:   field,
+				Code:    CodeOutOfRange,
+				Message: outOfRangeMessage(limit),
+			})
+		}
+	}
+
+	return errs, nil
+}
+
+// outOfRangeMessage describes a plausible range to the user
+func outOfRangeMessage(r Range) string {
+	if r.Max == nil {
+		return fmt.Sprintf("Value must be at least %g %s", r.Min, r.Unit)
+	}
+	return fmt.Sprintf("Value must be between %g and %g %s", r.Min, *r.Max, r.Unit)
+}
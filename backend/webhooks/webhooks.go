@@ -0,0 +1,370 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"utility-backend/logging"
+	"utility-backend/models"
+	"utility-backend/monitoring"
+)
+
+// Event names published to webhook subscribers
+const (
+	EventReadingCreated      = "reading.created"
+	EventAlertOpened         = "alert.opened"
+	EventAlertResolved       = "alert.resolved"
+	EventClientStatusChanged = "client.status_changed"
+)
+
+// Events lists every event a subscription may ask for
+var Events = []string{
+	EventReadingCreated,
+	EventAlertOpened,
+	EventAlertResolved,
+	EventClientStatusChanged,
+}
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+const (
+	// MaxAttempts is the number of failed attempts before a delivery is dead-lettered
+	MaxAttempts = 8
+	// baseBackoff is the delay before the first retry; it doubles on every attempt
+	baseBackoff = 30 * time.Second
+	// maxBackoff caps the delay between retries
+	maxBackoff = time.Hour
+	// batchSize is the number of due deliveries processed per dispatcher pass
+	batchSize = 50
+	// claimLease is how long a claimed delivery is held by its dispatcher;
+	// one left behind by a dispatcher that stopped is sent again after it
+	claimLease = time.Minute
+	// sendTimeout bounds each delivery request
+	sendTimeout = 10 * time.Second
+)
+
+var log = logging.For("webhooks")
+
+// Dispatcher queues events for webhook subscribers and delivers them. A
+// process shares one dispatcher between everything that publishes, so that
+// queued events wake its delivery loop.
+type Dispatcher struct {
+	DB     *gorm.DB     // where deliveries are queued and recorded
+	Client *http.Client // sends the deliveries
+
+	wake chan struct{}
+}
+
+// NewDispatcher returns a dispatcher delivering the deliveries queued in db
+func NewDispatcher(db *gorm.DB) *Dispatcher {
+	return &Dispatcher{
+		DB:     db,
+		Client: &http.Client{Timeout: sendTimeout},
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Envelope is the JSON body sent to subscribers
+type Envelope struct {
+	ID        uint        `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// IsValidEvent reports whether name is a known event or the * wildcard
+func IsValidEvent(name string) bool {
+	if name == "*" {
+		return true
+	}
+	for _, e := range Events {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribed reports whether a subscription's event list includes the event
+func subscribed(sub models.WebhookSubscription, event string) bool {
+	for _, e := range strings.Split(sub.Events, ",") {
+		e = strings.TrimSpace(e)
+		if e == "*" || e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSecret generates a signing secret for a subscription
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign computes the signature sent in the X-Webhook-Signature header.
+// Receivers verify it by computing HMAC-SHA256 over "<timestamp>.<body>" with the shared secret.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Publish queues the event for every active subscription that listens to it,
+// through db, which may carry a request's context or be a transaction.
+// Failures are logged rather than returned so that publishing never fails the caller's request.
+func (d *Dispatcher) Publish(db *gorm.DB, event string, data interface{}) {
+	var subs []models.WebhookSubscription
+	if err := db.Where("active = ?", true).Find(&subs).Error; err != nil {
+		log.ErrorContext(db.Statement.Context, "Failed to load webhook subscriptions", "event", event, "error", err)
+		return
+	}
+
+	queued := false
+	for _, sub := range subs {
+		if !subscribed(sub, event) {
+			continue
+		}
+
+		delivery := models.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			Event:          event,
+			Status:         StatusPending,
+			NextAttemptAt:  time.Now(),
+		}
+
+		// Create and fill in the payload in one transaction so the envelope can carry
+		// the delivery ID without the dispatcher seeing an empty payload
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&delivery).Error; err != nil {
+				return err
+			}
+
+			payload, err := json.Marshal(Envelope{
+				ID:        delivery.ID,
+				Event:     event,
+				CreatedAt: delivery.CreatedAt,
+				Data:      data,
+			})
+			if err != nil {
+				return err
+			}
+			return tx.Model(&delivery).Update("payload", string(payload)).Error
+		})
+		if err != nil {
+			log.ErrorContext(db.Statement.Context, "Failed to queue webhook", "event", event, "subscription", sub.ID, "error", err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		d.notify()
+	}
+}
+
+// Redeliver resets a delivery so the dispatcher sends it again
+func (d *Dispatcher) Redeliver(db *gorm.DB, id uint) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := db.First(&delivery, id).Error; err != nil {
+		return delivery, err
+	}
+
+	delivery.Status = StatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	delivery.LastError = ""
+
+	err := db.Model(&delivery).
+		Select("status", "attempts", "next_attempt_at", "last_error").
+		Updates(&delivery).Error
+	if err != nil {
+		return delivery, err
+	}
+
+	d.notify()
+	return delivery, nil
+}
+
+// notify wakes the delivery loop without blocking
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers due webhooks whenever new events are queued and
+// periodically for retries, beating hb on every pass. It returns once ctx is
+// done and the delivery in progress, if any, has finished; deliveries left
+// pending are sent by the next process to run a dispatcher.
+func (d *Dispatcher) Run(ctx context.Context, hb *monitoring.Heartbeat) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		d.DispatchDue(ctx, hb)
+		hb.Beat()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchDue attempts every pending delivery whose next attempt time has
+// passed, beating hb after each one. It stops early once ctx is done. Each
+// delivery is claimed before it is sent, so that dispatchers of other
+// replicas, or an overlapping pass, skip it rather than send it again.
+func (d *Dispatcher) DispatchDue(ctx context.Context, hb *monitoring.Heartbeat) {
+	var deliveries []models.WebhookDelivery
+	err := d.DB.Where("status = ? AND next_attempt_at <= ?", StatusPending, time.Now()).
+		Order("next_attempt_at").Limit(batchSize).Find(&deliveries).Error
+	if err != nil {
+		log.Error("Failed to load pending webhook deliveries", "error", err)
+		return
+	}
+
+	for i := range deliveries {
+		if ctx.Err() != nil {
+			return
+		}
+		claimed, err := claim(d.DB, &deliveries[i])
+		if err != nil {
+			log.Error("Failed to claim webhook delivery", "delivery", deliveries[i].ID, "error", err)
+			continue
+		}
+		if claimed {
+			d.attempt(&deliveries[i])
+		}
+		hb.Beat()
+	}
+}
+
+// claim holds a due delivery for claimLease by moving its next attempt
+// time past now, and reloads it. It reports false when another dispatcher
+// holds it, or it is no longer pending.
+func claim(db *gorm.DB, delivery *models.WebhookDelivery) (bool, error) {
+	now := time.Now()
+	lease := now.Add(claimLease)
+	result := db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", delivery.ID, StatusPending, now).
+		Update("next_attempt_at", lease)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	// Reload what another dispatcher may have recorded since it was listed
+	return true, db.First(delivery, delivery.ID).Error
+}
+
+// attempt sends a claimed delivery once and records the outcome
+func (d *Dispatcher) attempt(delivery *models.WebhookDelivery) {
+	db := d.DB
+	var sub models.WebhookSubscription
+	if err := db.First(&sub, delivery.SubscriptionID).Error; err != nil {
+		// The subscription was removed; nothing left to deliver to
+		db.Model(delivery).Updates(map[string]interface{}{
+			"status":     StatusDead,
+			"last_error": "subscription no longer exists",
+		})
+		return
+	}
+
+	status, err := d.send(sub, delivery)
+	now := time.Now()
+	delivery.Attempts++
+
+	updates := map[string]interface{}{
+		"attempts":        delivery.Attempts,
+		"response_status": status,
+	}
+
+	switch {
+	case err == nil:
+		updates["status"] = StatusDelivered
+		updates["delivered_at"] = now
+		updates["last_error"] = ""
+	case delivery.Attempts >= MaxAttempts:
+		updates["status"] = StatusDead
+		updates["last_error"] = err.Error()
+		log.Warn("Webhook delivery dead-lettered", "delivery", delivery.ID, "url", sub.URL, "attempts", delivery.Attempts, "error", err)
+	default:
+		updates["next_attempt_at"] = now.Add(backoff(delivery.Attempts))
+		updates["last_error"] = err.Error()
+	}
+
+	if err := db.Model(delivery).Updates(updates).Error; err != nil {
+		log.Error("Failed to record webhook delivery", "delivery", delivery.ID, "error", err)
+	}
+}
+
+// send posts the signed payload and returns the response status code
+func (d *Dispatcher) send(sub models.WebhookSubscription, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "utility-monitoring-webhooks/1.0")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", Sign(sub.Secret, timestamp, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt after the given number of failures
+func backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}